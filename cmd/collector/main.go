@@ -3,11 +3,13 @@ package main
 import (
 	"context"
 	"fmt"
+	"net/http"
 	"os"
 	"os/signal"
 	"strings"
 	"syscall"
 
+	"github.com/gorilla/mux"
 	"github.com/loghole/database"
 	"github.com/loghole/lhw/zap"
 	"github.com/loghole/lhw/zaplog"
@@ -15,13 +17,23 @@ import (
 	"github.com/loghole/tracing/tracehttp"
 	"github.com/loghole/tracing/tracelog"
 	"github.com/spf13/viper"
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
 	"golang.org/x/sync/errgroup"
+	"google.golang.org/grpc"
 
 	"github.com/loghole/collector/config"
+	elasticV1 "github.com/loghole/collector/internal/app/api/elastic/v1"
 	entryV1 "github.com/loghole/collector/internal/app/api/entry/v1"
+	"github.com/loghole/collector/internal/app/api/fluent"
+	"github.com/loghole/collector/internal/app/api/gelf"
+	"github.com/loghole/collector/internal/app/api/kafka"
+	lokiV1 "github.com/loghole/collector/internal/app/api/loki/v1"
 	"github.com/loghole/collector/internal/app/api/middleware"
+	otlpV1 "github.com/loghole/collector/internal/app/api/otlp/v1"
 	splunkV1 "github.com/loghole/collector/internal/app/api/splunk/v1"
-	"github.com/loghole/collector/internal/app/repositories/clickhouse"
+	"github.com/loghole/collector/internal/app/api/syslog"
+	"github.com/loghole/collector/internal/app/repositories/storage"
+	"github.com/loghole/collector/internal/app/services/ack"
 	"github.com/loghole/collector/internal/app/services/entry"
 	"github.com/loghole/collector/pkg/server"
 )
@@ -61,35 +73,78 @@ func main() {
 
 	traceLogger := tracelog.NewTraceLogger(logger.SugaredLogger)
 
-	// Init clients
-	clickhouseDB, err := database.New(
-		config.ClickhouseConfig(),
-		database.WithReconnectHook(),
-		clockhouseRetryFunc(logger),
+	// Init storage
+	var (
+		registry = storageRegistry(logger, traceLogger)
+		types    = storageTypes()
+		backends = make(map[string]storage.Backend, len(types))
 	)
-	if err != nil {
-		logger.Fatalf("can't connect to clickhouse db: %v", err)
+
+	for _, name := range types {
+		if backends[name], err = registry.Open(name); err != nil {
+			logger.Fatalf("can't open storage: %v", err)
+		}
 	}
 
-	// Init repository
-	repository := clickhouse.NewEntryRepository(
-		clickhouseDB,
-		traceLogger,
-		viper.GetInt("service.writer.capacity"),
-		viper.GetDuration("service.writer.period"),
-	)
+	if len(os.Args) > 1 && os.Args[1] == _replayCommand {
+		for _, name := range types {
+			if err := replay(context.Background(), logger, traceLogger, name, backends[name], os.Args[2:]); err != nil {
+				logger.Errorf("replay failed: %v", err)
+			}
+		}
+
+		closeStorages(logger, backends)
+
+		return
+	}
+
+	// Init writer
+	entryWriter, writeLogs, err := newStorageWriter(traceLogger, types, backends)
+	if err != nil {
+		logger.Fatalf("init entry writer failed: %v", err)
+	}
 
 	// Init service
-	entryService := entry.NewService(repository, traceLogger)
+	entryService := entry.NewService(entryWriter, traceLogger)
+
+	lineBreaker, err := splunkV1.NewLineBreaker(viper.GetString("service.splunk.raw.line_breaker"))
+	if err != nil {
+		logger.Fatalf("invalid splunk raw line breaker: %v", err)
+	}
+
+	var splunkAcks splunkV1.AckRegistry
+
+	if viper.GetBool("service.splunk.ack.enable") {
+		splunkAcks = ack.NewRegistry(
+			viper.GetInt("service.splunk.ack.max_pending"),
+			viper.GetDuration("service.splunk.ack.ttl"),
+		)
+	}
 
 	// Init handlers
 	var (
 		entryHandlers = entryV1.NewEntryHandlers(entryService, traceLogger, tracer)
-		splunkHandler = splunkV1.NewSplunkHandler(entryService, traceLogger, tracer)
-		infoHandlers  = entryV1.NewInfoHandlers(traceLogger)
+		splunkHandler = splunkV1.NewSplunkHandler(
+			entryService,
+			traceLogger,
+			tracer,
+			lineBreaker,
+			splunkAcks,
+			viper.GetInt("service.splunk.max_events"),
+		)
+		lokiHandler    = lokiV1.NewLokiHandler(entryService, traceLogger, tracer)
+		elasticHandler = elasticV1.NewElasticHandler(
+			entryService,
+			traceLogger,
+			tracer,
+			viper.GetString("service.elastic.version"),
+		)
+		otlpHandler  = otlpV1.NewLogsHandler(entryService, traceLogger, tracer)
+		infoHandlers = entryV1.NewInfoHandlers(traceLogger)
 
-		remoteIPMiddleware = middleware.NewRemoteIPMiddleware("service.ip.header")
-		authMiddleware     = middleware.NewAuthMiddleware(
+		remoteIPMiddleware   = middleware.NewRemoteIPMiddleware("service.ip.header")
+		decompressMiddleware = middleware.NewDecompressMiddleware(viper.GetInt64("service.decompress.max_size"))
+		authMiddleware       = middleware.NewAuthMiddleware(
 			viper.GetBool("service.auth.enable"),
 			viper.GetStringSlice("service.auth.tokens"),
 		)
@@ -97,25 +152,68 @@ func main() {
 
 	srv := server.NewHTTP(config.ServerConfig())
 
+	middlewares := []mux.MiddlewareFunc{
+		authMiddleware.Middleware,
+		remoteIPMiddleware.Middleware,
+		tracehttp.NewMiddleware(tracer).Middleware,
+		decompressMiddleware.Middleware,
+	}
+
 	r := srv.Router()
 	r.HandleFunc("/api/v1/info", infoHandlers.InfoHandler)
 
 	r1 := r.PathPrefix("/api/v1").Subrouter()
-	r1.Use(authMiddleware.Middleware, remoteIPMiddleware.Middleware, tracehttp.NewMiddleware(tracer).Middleware)
+	r1.Use(middlewares...)
 	r1.HandleFunc("/store", entryHandlers.StoreItemHandler)
 	r1.HandleFunc("/store/list", entryHandlers.StoreListHandler)
+	r1.HandleFunc("/store/ndjson", entryHandlers.StoreNDJSONHandler)
 	r1.HandleFunc("/ping", entryHandlers.PingHandler)
+	r1.HandleFunc("/stats", entryHandlers.StatsHandler)
+
+	r2 := r.PathPrefix("/services/collector").Subrouter()
+	r2.Use(middlewares...)
+	splunkHandler.RegisterRoutes(r2)
+
+	r3 := r.PathPrefix("/loki/api/v1").Subrouter()
+	r3.Use(middlewares...)
+	r3.HandleFunc("/push", lokiHandler.PushHandler)
+
+	r5 := r.PathPrefix("/v1").Subrouter()
+	r5.Use(middlewares...)
+	r5.HandleFunc("/logs", otlpHandler.ExportHandler).Methods(http.MethodPost)
+
+	r4 := r.NewRoute().Subrouter()
+	r4.Use(middlewares...)
+	r4.HandleFunc("/", elasticHandler.InfoHandler).Methods(http.MethodGet, http.MethodHead)
+	r4.HandleFunc("/_license", elasticHandler.LicenseHandler).Methods(http.MethodGet)
+	r4.HandleFunc("/_xpack/license", elasticHandler.LicenseHandler).Methods(http.MethodGet)
+	r4.HandleFunc("/_bulk", elasticHandler.BulkHandler).Methods(http.MethodPost, http.MethodPut)
+	r4.HandleFunc("/{index}/_bulk", elasticHandler.BulkHandler).Methods(http.MethodPost, http.MethodPut)
+
+	grpcSrv, err := server.NewGRPC(
+		config.GRPCServerConfig(),
+		grpc.ChainUnaryInterceptor(authMiddleware.UnaryServerInterceptor),
+	)
+	if err != nil {
+		logger.Fatalf("init grpc server failed: %v", err)
+	}
+
+	collogspb.RegisterLogsServiceServer(grpcSrv.Server(), otlpV1.NewLogsServer(entryService, traceLogger, tracer))
+
+	var (
+		syslogSrv = syslog.NewServer(config.SyslogServerConfig(), entryService, traceLogger)
+		gelfSrv   = gelf.NewServer(config.GELFServerConfig(), entryService, traceLogger)
+		fluentSrv = fluent.NewServer(config.FluentServerConfig(), entryService, traceLogger)
 
-	r2 := r.PathPrefix("/services/collector/event").Subrouter()
-	r2.Use(authMiddleware.Middleware, remoteIPMiddleware.Middleware, tracehttp.NewMiddleware(tracer).Middleware)
-	r2.HandleFunc("/1.0", splunkHandler.Handler)
+		kafkaConsumer = kafka.NewConsumer(config.KafkaConsumerConfig(), entryService, traceLogger)
+	)
 
 	errGroup, ctx := errgroup.WithContext(context.Background())
 
 	errGroup.Go(func() error {
 		logger.Info("start entry writer")
 
-		return repository.Run(ctx)
+		return entryWriter.Run(ctx)
 	})
 
 	errGroup.Go(func() error {
@@ -124,6 +222,46 @@ func main() {
 		return srv.ListenAndServe()
 	})
 
+	if addr := grpcSrv.Addr(); addr != "" {
+		errGroup.Go(func() error {
+			logger.Infof("start grpc server on: %s", addr)
+
+			return grpcSrv.ListenAndServe()
+		})
+	}
+
+	if addr := syslogSrv.Addr(); addr != "" {
+		errGroup.Go(func() error {
+			logger.Infof("start syslog server on: %s", addr)
+
+			return syslogSrv.ListenAndServe()
+		})
+	}
+
+	if addr := gelfSrv.Addr(); addr != "" {
+		errGroup.Go(func() error {
+			logger.Infof("start gelf server on: %s", addr)
+
+			return gelfSrv.ListenAndServe()
+		})
+	}
+
+	if addr := fluentSrv.Addr(); addr != "" {
+		errGroup.Go(func() error {
+			logger.Infof("start fluent forward server on: %s", addr)
+
+			return fluentSrv.ListenAndServe()
+		})
+	}
+
+	if addr := kafkaConsumer.Addr(); addr != "" {
+		errGroup.Go(func() error {
+			logger.Infof("start kafka consumer of: %s", addr)
+
+			return kafkaConsumer.Serve()
+		})
+	}
+
 	select {
 	case <-exit:
 		logger.Info("stopping application")
@@ -135,23 +273,55 @@ func main() {
 		logger.Errorf("error while stopping web server: %v", err)
 	}
 
-	repository.Stop()
+	if err = grpcSrv.Shutdown(context.Background()); err != nil {
+		logger.Errorf("error while stopping grpc server: %v", err)
+	}
+
+	if err = syslogSrv.Shutdown(); err != nil {
+		logger.Errorf("error while stopping syslog server: %v", err)
+	}
+
+	if err = gelfSrv.Shutdown(); err != nil {
+		logger.Errorf("error while stopping gelf server: %v", err)
+	}
+
+	if err = fluentSrv.Shutdown(); err != nil {
+		logger.Errorf("error while stopping fluent forward server: %v", err)
+	}
+
+	if err = kafkaConsumer.Shutdown(); err != nil {
+		logger.Errorf("error while stopping kafka consumer: %v", err)
+	}
+
+	entryWriter.Stop()
 
 	if err = errGroup.Wait(); err != nil {
 		logger.Errorf("error while waiting for goroutines: %v", err)
 	}
 
+	for _, writeLog := range writeLogs {
+		if err = writeLog.Close(); err != nil {
+			logger.Errorf("error while closing wal: %v", err)
+		}
+	}
+
 	if err = tracer.Close(); err != nil {
 		logger.Errorf("error while stopping tracer: %v", err)
 	}
 
-	if err = clickhouseDB.Close(); err != nil {
-		logger.Errorf("error while stopping clickhouse db: %v", err)
-	}
+	closeStorages(logger, backends)
 
 	logger.Info("application stopped")
 }
 
+func closeStorages(logger *zap.Logger, backends map[string]storage.Backend) {
+	for name, backend := range backends {
+		if err := backend.Close(); err != nil {
+			logger.Errorf("error while closing %s storage: %v", name, err)
+		}
+	}
+}
+
 func clockhouseRetryFunc(logger *zap.Logger) database.Option {
 	return database.WithRetryFunc(func(retryCount int, err error) bool {
 		if err == nil {
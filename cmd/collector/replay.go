@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/loghole/lhw/zap"
+	"github.com/loghole/tracing/tracelog"
+
+	"github.com/loghole/collector/internal/app/repositories/deadletter"
+	"github.com/loghole/collector/internal/app/repositories/storage"
+	"github.com/loghole/collector/internal/app/repositories/writer"
+)
+
+const _replayCommand = "replay"
+
+// replay writes files of the dead letter directory to the storage: `collector replay [dir]`.
+// Written files are removed, entries rejected by the storage again are moved to a new file.
+// Dead letters of the fan-out storages are replayed from subdirectories named by the storage type.
+func replay(
+	ctx context.Context,
+	logger *zap.Logger,
+	traceLogger tracelog.Logger,
+	name string,
+	backend storage.Backend,
+	args []string,
+) error {
+	writerConfig, _, path := storageWriterConfig(name)
+
+	if len(args) > 0 {
+		path = args[0]
+
+		if isFanout() {
+			path = filepath.Join(path, name)
+		}
+	}
+
+	dir, err := deadletter.NewDir(path)
+	if err != nil {
+		return err
+	}
+
+	entryWriter, err := writer.New(backend, traceLogger, writerConfig)
+	if err != nil {
+		return err
+	}
+
+	files, err := dir.Files()
+	if err != nil {
+		return fmt.Errorf("list files: %w", err)
+	}
+
+	var written, failed int
+
+	for _, file := range files {
+		list, err := deadletter.Read(file)
+		if err != nil {
+			logger.Errorf("read %s: %v", file, err)
+
+			continue
+		}
+
+		rejected, err := entryWriter.WriteEntryList(ctx, list)
+
+		written += len(list) - len(rejected)
+		failed += len(rejected)
+
+		switch {
+		case len(rejected) == len(list):
+			logger.Errorf("write %s: %v", file, err)
+
+			continue
+		case len(rejected) > 0:
+			next, writeErr := dir.Write(rejected)
+			if writeErr != nil {
+				return fmt.Errorf("write rejected entries of %s: %w", file, writeErr)
+			}
+
+			logger.Errorf("%d entries of %s were moved to %s: %v", len(rejected), file, next, err)
+		}
+
+		if err := os.Remove(file); err != nil {
+			return fmt.Errorf("remove %s: %w", file, err)
+		}
+	}
+
+	logger.Infof("replayed %d files: %d entries written, %d failed", len(files), written, failed)
+
+	return nil
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/loghole/database"
+	"github.com/loghole/lhw/zap"
+	"github.com/loghole/tracing/tracelog"
+	"github.com/spf13/viper"
+
+	"github.com/loghole/collector/config"
+	"github.com/loghole/collector/internal/app/repositories/clickhouse"
+	"github.com/loghole/collector/internal/app/repositories/deadletter"
+	"github.com/loghole/collector/internal/app/repositories/fanout"
+	"github.com/loghole/collector/internal/app/repositories/filesink"
+	"github.com/loghole/collector/internal/app/repositories/kafkasink"
+	"github.com/loghole/collector/internal/app/repositories/ndjson"
+	"github.com/loghole/collector/internal/app/repositories/s3sink"
+	"github.com/loghole/collector/internal/app/repositories/storage"
+	"github.com/loghole/collector/internal/app/repositories/writer"
+	"github.com/loghole/collector/internal/app/services/entry"
+	"github.com/loghole/collector/pkg/wal"
+)
+
+// storageWriter queues entries for writing, see writer.Writer and fanout.Storage.
+type storageWriter interface {
+	entry.Storage
+	Run(ctx context.Context) error
+	Stop()
+}
+
+// storageTypes returns `storage.type` or types of the fan-out storages.
+func storageTypes() []string {
+	if name := viper.GetString("storage.type"); name != storage.TypeFanout {
+		return []string{name}
+	}
+
+	return viper.GetStringSlice("storage.fanout.backends")
+}
+
+func isFanout() bool {
+	return viper.GetString("storage.type") == storage.TypeFanout
+}
+
+// writerConfig returns config of the storage writer. Writers of the fan-out storages keep the write-ahead log
+// and dead letters in subdirectories named by the storage type.
+func storageWriterConfig(name string) (*writer.Config, *wal.Config, string) {
+	var (
+		walConfig     = config.WALConfig()
+		deadLetterDir = viper.GetString("service.writer.dead_letter.dir")
+	)
+
+	if !isFanout() {
+		return config.WriterConfig(), walConfig, deadLetterDir
+	}
+
+	walConfig.Dir = filepath.Join(walConfig.Dir, name)
+
+	if deadLetterDir != "" {
+		deadLetterDir = filepath.Join(deadLetterDir, name)
+	}
+
+	return config.BackendWriterConfig(name), walConfig, deadLetterDir
+}
+
+// newStorageWriter returns writer of the storage or the fan-out writing to every storage with its own writer.
+func newStorageWriter(
+	logger tracelog.Logger,
+	types []string,
+	backends map[string]storage.Backend,
+) (storageWriter, []*wal.WAL, error) {
+	var (
+		targets = make([]*fanout.Target, 0, len(types))
+		logs    = make([]*wal.WAL, 0, len(types))
+	)
+
+	for _, name := range types {
+		entryWriter, writeLog, err := newWriter(logger, name, backends[name])
+		if err != nil {
+			return nil, logs, fmt.Errorf("%s: %w", name, err)
+		}
+
+		if writeLog != nil {
+			logs = append(logs, writeLog)
+		}
+
+		targets = append(targets, &fanout.Target{Name: name, Optional: config.IsOptionalBackend(name), Writer: entryWriter})
+	}
+
+	if !isFanout() {
+		return targets[0].Writer, logs, nil
+	}
+
+	storageWriter, err := fanout.NewStorage(targets, logger)
+	if err != nil {
+		return nil, logs, err
+	}
+
+	return storageWriter, logs, nil
+}
+
+func newWriter(logger tracelog.Logger, name string, backend storage.Backend) (*writer.Writer, *wal.WAL, error) {
+	var writeLog *wal.WAL
+
+	writerConfig, walConfig, deadLetterDir := storageWriterConfig(name)
+
+	if deadLetterDir != "" {
+		deadLetters, err := deadletter.NewDir(deadLetterDir)
+		if err != nil {
+			return nil, nil, fmt.Errorf("init dead letter dir: %w", err)
+		}
+
+		writerConfig.DeadLetters = deadLetters
+	}
+
+	if viper.GetBool("service.writer.wal.enable") {
+		log, err := wal.Open(walConfig)
+		if err != nil {
+			return nil, nil, fmt.Errorf("open wal: %w", err)
+		}
+
+		writeLog, writerConfig.WAL = log, log
+	}
+
+	entryWriter, err := writer.New(backend, logger, writerConfig)
+	if err != nil {
+		return nil, writeLog, fmt.Errorf("init entry writer: %w", err)
+	}
+
+	return entryWriter, writeLog, nil
+}
+
+// storageRegistry returns storage backends selectable by `storage.type`.
+func storageRegistry(logger *zap.Logger, traceLogger tracelog.Logger) *storage.Registry {
+	registry := storage.NewRegistry()
+
+	registry.Register(storage.TypeClickhouse, func() (storage.Backend, error) {
+		db, err := database.New(
+			config.ClickhouseConfig(),
+			database.WithReconnectHook(),
+			clockhouseRetryFunc(logger),
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		// Keep idle connections of all writer workers in the pool.
+		if workers := viper.GetInt("service.writer.workers"); workers > 1 {
+			db.SetMaxIdleConns(workers)
+		}
+
+		return clickhouse.NewEntryRepository(db, traceLogger), nil
+	})
+
+	registry.Register(storage.TypeFile, func() (storage.Backend, error) {
+		return ndjson.OpenFile(viper.GetString("storage.file.path"))
+	})
+
+	registry.Register(storage.TypeKafka, func() (storage.Backend, error) {
+		return kafkasink.Open(config.KafkaSinkConfig())
+	})
+
+	registry.Register(storage.TypeRotatingFile, func() (storage.Backend, error) {
+		return filesink.Open(config.FileSinkConfig(), traceLogger)
+	})
+
+	registry.Register(storage.TypeS3, func() (storage.Backend, error) {
+		return s3sink.Open(config.S3SinkConfig(), traceLogger)
+	})
+
+	registry.Register(storage.TypeStdout, func() (storage.Backend, error) {
+		return ndjson.NewBackend(os.Stdout), nil
+	})
+
+	return registry
+}
@@ -2,6 +2,7 @@ package config
 
 import (
 	"fmt"
+	"os"
 	"strings"
 	"time"
 
@@ -14,7 +15,17 @@ import (
 	"github.com/spf13/viper"
 	"github.com/uber/jaeger-client-go/config"
 
+	"github.com/loghole/collector/internal/app/api/fluent"
+	"github.com/loghole/collector/internal/app/api/gelf"
+	"github.com/loghole/collector/internal/app/api/kafka"
+	"github.com/loghole/collector/internal/app/api/syslog"
+	"github.com/loghole/collector/internal/app/repositories/filesink"
+	"github.com/loghole/collector/internal/app/repositories/kafkasink"
+	"github.com/loghole/collector/internal/app/repositories/s3sink"
+	"github.com/loghole/collector/internal/app/repositories/storage"
+	"github.com/loghole/collector/internal/app/repositories/writer"
 	"github.com/loghole/collector/pkg/server"
+	"github.com/loghole/collector/pkg/wal"
 )
 
 const (
@@ -22,10 +33,68 @@ const (
 
 	_defaultServerIdleTimeout = time.Minute * 10
 
+	_defaultServerGRPCPort           = 4317
+	_defaultServerGRPCMaxRecvMsgSize = 16 << 20
+
+	_defaultServerSyslogMaxMessageSize = 64 << 10
+
+	_defaultServerGELFMaxMessageSize   = 1 << 20
+	_defaultServerGELFChunkTimeout     = time.Second * 5
+	_defaultServerGELFChunkMaxMessages = 10000
+	_defaultServerGELFChunkMaxSize     = 64 << 20
+
+	_defaultServerFluentMaxMessageSize = 16 << 20
+
+	_defaultServerKafkaGroupID       = "collector"
+	_defaultServerKafkaBatchSize     = 1000
+	_defaultServerKafkaBatchTimeout  = time.Second
+	_defaultServerKafkaRetryInterval = time.Second * 5
+
+	_defaultStorageType     = storage.TypeClickhouse
+	_defaultStorageFilePath = "entries.ndjson"
+
+	_defaultStorageRotatingFileDir           = "archive"
+	_defaultStorageRotatingFileMaxSize       = 64 << 20
+	_defaultStorageRotatingFileMaxAge        = time.Hour
+	_defaultStorageRotatingFileRetentionSize = 10 << 30
+
+	_defaultStorageS3PartSize      = 16 << 20
+	_defaultStorageS3ObjectMaxSize = 128 << 20
+	_defaultStorageS3ObjectMaxAge  = time.Minute * 5
+	_defaultStorageS3SpoolDir      = "s3_spool"
+	_defaultStorageS3SpoolMaxSize  = 10 << 30
+	_defaultStorageS3RetryInterval = time.Second * 10
+
+	_defaultStorageKafkaTopic        = "logs"
+	_defaultStorageKafkaBatchSize    = 1000
+	_defaultStorageKafkaBatchTimeout = time.Millisecond * 10
+	_defaultStorageKafkaWriteTimeout = time.Second * 10
+
 	_defaultClickhouseReadTimeoutSeconds  = 10
 	_defaultClickhouseWriteTimeoutSeconds = 20
 
-	_defaultServerWriterCapacity = 1000
+	_defaultServerWriterCapacity        = 1000
+	_defaultServerWriterWorkers         = 1
+	_defaultServerWriterMaxBatchRows    = 100000
+	_defaultServerWriterMaxBatchBytes   = 64 << 20
+	_defaultServerWriterMinInterval     = time.Millisecond * 100
+	_defaultServerWriterOverflowTimeout = time.Second * 10
+	_defaultServerWriterWALDir          = "wal"
+	_defaultServerWriterWALSegmentSize  = 64 << 20
+	_defaultServerWriterWALMaxSize      = 1 << 30
+	_defaultServerWriterRetryAttempts   = 10
+	_defaultServerWriterRetryMaxAge     = time.Minute * 10
+	_defaultServerWriterRetryMaxDelay   = time.Minute
+	_defaultServerWriterDeadLetterDir   = "dead_letter"
+
+	_defaultSplunkMaxEvents      = 100000
+	_defaultSplunkRawLineBreaker = `([\r\n]+)`
+	_defaultSplunkAckMaxPending  = 10000
+	_defaultSplunkAckTTL         = time.Minute * 10
+
+	_defaultElasticVersion = "7.17.9"
+
+	_defaultDecompressMaxSize = 256 << 20
 )
 
 // nolint:gochecknoglobals // build args
@@ -50,11 +119,75 @@ func Init() {
 	viper.SetDefault("server.read.timeout", time.Minute)
 	viper.SetDefault("server.write.timeout", time.Minute)
 	viper.SetDefault("server.idle.timeout", _defaultServerIdleTimeout)
+	viper.SetDefault("server.grpc.enable", true)
+	viper.SetDefault("server.grpc.port", _defaultServerGRPCPort)
+	viper.SetDefault("server.grpc.max_recv_msg_size", _defaultServerGRPCMaxRecvMsgSize)
+	viper.SetDefault("server.syslog.max_message_size", _defaultServerSyslogMaxMessageSize)
+	viper.SetDefault("server.syslog.idle.timeout", _defaultServerIdleTimeout)
+	viper.SetDefault("server.gelf.max_message_size", _defaultServerGELFMaxMessageSize)
+	viper.SetDefault("server.gelf.chunk.timeout", _defaultServerGELFChunkTimeout)
+	viper.SetDefault("server.gelf.chunk.max_messages", _defaultServerGELFChunkMaxMessages)
+	viper.SetDefault("server.gelf.chunk.max_size", _defaultServerGELFChunkMaxSize)
+	viper.SetDefault("server.gelf.idle.timeout", _defaultServerIdleTimeout)
+	viper.SetDefault("server.fluent.max_message_size", _defaultServerFluentMaxMessageSize)
+	viper.SetDefault("server.fluent.idle.timeout", _defaultServerIdleTimeout)
+	viper.SetDefault("server.kafka.group_id", _defaultServerKafkaGroupID)
+	viper.SetDefault("server.kafka.batch_size", _defaultServerKafkaBatchSize)
+	viper.SetDefault("server.kafka.batch_timeout", _defaultServerKafkaBatchTimeout)
+	viper.SetDefault("server.kafka.retry.interval", _defaultServerKafkaRetryInterval)
 
+	if hostname, err := os.Hostname(); err == nil {
+		viper.SetDefault("server.fluent.hostname", hostname)
+	}
+
+	viper.SetDefault("storage.type", _defaultStorageType)
+	viper.SetDefault("storage.file.path", _defaultStorageFilePath)
+	viper.SetDefault("storage.rotating_file.dir", _defaultStorageRotatingFileDir)
+	viper.SetDefault("storage.rotating_file.max_size", _defaultStorageRotatingFileMaxSize)
+	viper.SetDefault("storage.rotating_file.max_age", _defaultStorageRotatingFileMaxAge)
+	viper.SetDefault("storage.rotating_file.compression", filesink.CompressionGzip)
+	viper.SetDefault("storage.rotating_file.retention_size", _defaultStorageRotatingFileRetentionSize)
+	viper.SetDefault("storage.s3.use_ssl", false)
+	viper.SetDefault("storage.s3.compression", s3sink.CompressionGzip)
+	viper.SetDefault("storage.s3.part_size", _defaultStorageS3PartSize)
+	viper.SetDefault("storage.s3.object.max_size", _defaultStorageS3ObjectMaxSize)
+	viper.SetDefault("storage.s3.object.max_age", _defaultStorageS3ObjectMaxAge)
+	viper.SetDefault("storage.s3.spool.dir", _defaultStorageS3SpoolDir)
+	viper.SetDefault("storage.s3.spool.max_size", _defaultStorageS3SpoolMaxSize)
+	viper.SetDefault("storage.s3.retry.interval", _defaultStorageS3RetryInterval)
+	viper.SetDefault("storage.kafka.topic", _defaultStorageKafkaTopic)
+	viper.SetDefault("storage.kafka.compression", kafkasink.CompressionNone)
+	viper.SetDefault("storage.kafka.required_acks", kafkasink.AcksAll)
+	viper.SetDefault("storage.kafka.batch_size", _defaultStorageKafkaBatchSize)
+	viper.SetDefault("storage.kafka.batch_timeout", _defaultStorageKafkaBatchTimeout)
+	viper.SetDefault("storage.kafka.write_timeout", _defaultStorageKafkaWriteTimeout)
+	viper.SetDefault("storage.fanout.backends", []string{storage.TypeClickhouse})
 	viper.SetDefault("clickhouse.read.timeout", _defaultClickhouseReadTimeoutSeconds)
 	viper.SetDefault("clickhouse.write.timeout", _defaultClickhouseWriteTimeoutSeconds)
 	viper.SetDefault("service.writer.capacity", _defaultServerWriterCapacity)
 	viper.SetDefault("service.writer.period", time.Second)
+	viper.SetDefault("service.writer.workers", _defaultServerWriterWorkers)
+	viper.SetDefault("service.writer.max_batch_rows", _defaultServerWriterMaxBatchRows)
+	viper.SetDefault("service.writer.max_batch_bytes", _defaultServerWriterMaxBatchBytes)
+	viper.SetDefault("service.writer.min_interval", _defaultServerWriterMinInterval)
+	viper.SetDefault("service.writer.overflow.policy", writer.PolicyBlock)
+	viper.SetDefault("service.writer.overflow.timeout", _defaultServerWriterOverflowTimeout)
+	viper.SetDefault("service.writer.wal.dir", _defaultServerWriterWALDir)
+	viper.SetDefault("service.writer.wal.segment_size", _defaultServerWriterWALSegmentSize)
+	viper.SetDefault("service.writer.wal.max_size", _defaultServerWriterWALMaxSize)
+	viper.SetDefault("service.writer.wal.sync.policy", wal.SyncInterval)
+	viper.SetDefault("service.writer.wal.sync.interval", time.Second)
+	viper.SetDefault("service.writer.retry.max_attempts", _defaultServerWriterRetryAttempts)
+	viper.SetDefault("service.writer.retry.max_age", _defaultServerWriterRetryMaxAge)
+	viper.SetDefault("service.writer.retry.initial_interval", time.Second)
+	viper.SetDefault("service.writer.retry.max_interval", _defaultServerWriterRetryMaxDelay)
+	viper.SetDefault("service.writer.dead_letter.dir", _defaultServerWriterDeadLetterDir)
+	viper.SetDefault("service.splunk.max_events", _defaultSplunkMaxEvents)
+	viper.SetDefault("service.splunk.raw.line_breaker", _defaultSplunkRawLineBreaker)
+	viper.SetDefault("service.splunk.ack.max_pending", _defaultSplunkAckMaxPending)
+	viper.SetDefault("service.splunk.ack.ttl", _defaultSplunkAckTTL)
+	viper.SetDefault("service.elastic.version", _defaultElasticVersion)
+	viper.SetDefault("service.decompress.max_size", _defaultDecompressMaxSize)
 }
 
 func ClickhouseConfig() *database.Config {
@@ -68,6 +201,112 @@ func ClickhouseConfig() *database.Config {
 	}
 }
 
+func WriterConfig() *writer.Config {
+	return writerConfig(func(key string) string { return "service.writer." + key })
+}
+
+// BackendWriterConfig returns writer config of the fan-out storage, `storage.<name>.writer.*`
+// keys override `service.writer.*` keys. Optional storages drop new entries of a full queue
+// unless their own policy is set, so they don't delay requests.
+func BackendWriterConfig(name string) *writer.Config {
+	override := func(key string) string { return "storage." + name + ".writer." + key }
+
+	backendConfig := writerConfig(func(key string) string {
+		if viper.IsSet(override(key)) {
+			return override(key)
+		}
+
+		return "service.writer." + key
+	})
+
+	if IsOptionalBackend(name) && !viper.IsSet(override("overflow.policy")) {
+		backendConfig.OverflowPolicy = writer.PolicyDropNewest
+	}
+
+	return backendConfig
+}
+
+// IsOptionalBackend reports whether failures of the fan-out storage don't fail requests.
+func IsOptionalBackend(name string) bool {
+	for _, optional := range viper.GetStringSlice("storage.fanout.optional") {
+		if optional == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+func writerConfig(key func(key string) string) *writer.Config {
+	return &writer.Config{
+		Capacity:        viper.GetInt(key("capacity")),
+		Period:          viper.GetDuration(key("period")),
+		Workers:         viper.GetInt(key("workers")),
+		MaxBatchRows:    viper.GetInt(key("max_batch_rows")),
+		MaxBatchBytes:   viper.GetInt(key("max_batch_bytes")),
+		MinInterval:     viper.GetDuration(key("min_interval")),
+		OverflowPolicy:  viper.GetString(key("overflow.policy")),
+		OverflowTimeout: viper.GetDuration(key("overflow.timeout")),
+		Retry: writer.RetryConfig{
+			MaxAttempts:     viper.GetInt(key("retry.max_attempts")),
+			MaxAge:          viper.GetDuration(key("retry.max_age")),
+			InitialInterval: viper.GetDuration(key("retry.initial_interval")),
+			MaxInterval:     viper.GetDuration(key("retry.max_interval")),
+		},
+	}
+}
+
+func FileSinkConfig() *filesink.Config {
+	return &filesink.Config{
+		Dir:           viper.GetString("storage.rotating_file.dir"),
+		MaxSize:       viper.GetInt64("storage.rotating_file.max_size"),
+		MaxAge:        viper.GetDuration("storage.rotating_file.max_age"),
+		Compression:   viper.GetString("storage.rotating_file.compression"),
+		RetentionSize: viper.GetInt64("storage.rotating_file.retention_size"),
+	}
+}
+
+func S3SinkConfig() *s3sink.Config {
+	return &s3sink.Config{
+		Endpoint:      viper.GetString("storage.s3.endpoint"),
+		Region:        viper.GetString("storage.s3.region"),
+		Bucket:        viper.GetString("storage.s3.bucket"),
+		AccessKey:     viper.GetString("storage.s3.access_key"),
+		SecretKey:     viper.GetString("storage.s3.secret_key"),
+		UseSSL:        viper.GetBool("storage.s3.use_ssl"),
+		Prefix:        viper.GetString("storage.s3.prefix"),
+		Compression:   viper.GetString("storage.s3.compression"),
+		PartSize:      viper.GetUint64("storage.s3.part_size"),
+		MaxObjectSize: viper.GetInt64("storage.s3.object.max_size"),
+		MaxObjectAge:  viper.GetDuration("storage.s3.object.max_age"),
+		SpoolDir:      viper.GetString("storage.s3.spool.dir"),
+		SpoolMaxSize:  viper.GetInt64("storage.s3.spool.max_size"),
+		RetryInterval: viper.GetDuration("storage.s3.retry.interval"),
+	}
+}
+
+func KafkaSinkConfig() *kafkasink.Config {
+	return &kafkasink.Config{
+		Brokers:      viper.GetStringSlice("storage.kafka.brokers"),
+		Topic:        viper.GetString("storage.kafka.topic"),
+		Compression:  viper.GetString("storage.kafka.compression"),
+		RequiredAcks: viper.GetString("storage.kafka.required_acks"),
+		BatchSize:    viper.GetInt("storage.kafka.batch_size"),
+		BatchTimeout: viper.GetDuration("storage.kafka.batch_timeout"),
+		WriteTimeout: viper.GetDuration("storage.kafka.write_timeout"),
+	}
+}
+
+func WALConfig() *wal.Config {
+	return &wal.Config{
+		Dir:          viper.GetString("service.writer.wal.dir"),
+		SegmentSize:  viper.GetInt64("service.writer.wal.segment_size"),
+		MaxSize:      viper.GetInt64("service.writer.wal.max_size"),
+		SyncPolicy:   viper.GetString("service.writer.wal.sync.policy"),
+		SyncInterval: viper.GetDuration("service.writer.wal.sync.interval"),
+	}
+}
+
 func TracerConfig() *config.Configuration {
 	return tracing.DefaultConfiguration(serviceName(), viper.GetString("jaeger.uri"))
 }
@@ -83,6 +322,71 @@ func ServerConfig() *server.Config {
 	}
 }
 
+// GRPCServerConfig returns the OTLP/gRPC server config, the address is empty if the server is disabled.
+// An empty environment variable doesn't override the default port, so the server is disabled by the flag.
+func GRPCServerConfig() *server.GRPCConfig {
+	var addr string
+
+	if viper.GetBool("server.grpc.enable") {
+		addr = listenAddr(viper.GetString("server.grpc.port"))
+	}
+
+	return &server.GRPCConfig{
+		Addr:           addr,
+		MaxRecvMsgSize: viper.GetInt("server.grpc.max_recv_msg_size"),
+		TLSCertFile:    viper.GetString("server.grpc.tls.cert"),
+		TLSKeyFile:     viper.GetString("server.grpc.tls.key"),
+	}
+}
+
+func SyslogServerConfig() *syslog.Config {
+	return &syslog.Config{
+		UDPAddr:        listenAddr(viper.GetString("server.syslog.udp.port")),
+		TCPAddr:        listenAddr(viper.GetString("server.syslog.tcp.port")),
+		TLSAddr:        listenAddr(viper.GetString("server.syslog.tls.port")),
+		TLSCertFile:    viper.GetString("server.syslog.tls.cert"),
+		TLSKeyFile:     viper.GetString("server.syslog.tls.key"),
+		MaxMessageSize: viper.GetInt("server.syslog.max_message_size"),
+		IdleTimeout:    viper.GetDuration("server.syslog.idle.timeout"),
+	}
+}
+
+func GELFServerConfig() *gelf.Config {
+	return &gelf.Config{
+		UDPAddr:          listenAddr(viper.GetString("server.gelf.udp.port")),
+		TCPAddr:          listenAddr(viper.GetString("server.gelf.tcp.port")),
+		MaxMessageSize:   viper.GetInt("server.gelf.max_message_size"),
+		ChunkTimeout:     viper.GetDuration("server.gelf.chunk.timeout"),
+		ChunkMaxMessages: viper.GetInt("server.gelf.chunk.max_messages"),
+		ChunkMaxSize:     viper.GetInt("server.gelf.chunk.max_size"),
+		IdleTimeout:      viper.GetDuration("server.gelf.idle.timeout"),
+	}
+}
+
+func FluentServerConfig() *fluent.Config {
+	return &fluent.Config{
+		TCPAddr:        listenAddr(viper.GetString("server.fluent.tcp.port")),
+		TLSAddr:        listenAddr(viper.GetString("server.fluent.tls.port")),
+		TLSCertFile:    viper.GetString("server.fluent.tls.cert"),
+		TLSKeyFile:     viper.GetString("server.fluent.tls.key"),
+		SharedKey:      viper.GetString("server.fluent.shared_key"),
+		Hostname:       viper.GetString("server.fluent.hostname"),
+		MaxMessageSize: viper.GetInt("server.fluent.max_message_size"),
+		IdleTimeout:    viper.GetDuration("server.fluent.idle.timeout"),
+	}
+}
+
+func KafkaConsumerConfig() *kafka.Config {
+	return &kafka.Config{
+		Brokers:       viper.GetStringSlice("server.kafka.brokers"),
+		Topics:        viper.GetStringSlice("server.kafka.topics"),
+		GroupID:       viper.GetString("server.kafka.group_id"),
+		BatchSize:     viper.GetInt("server.kafka.batch_size"),
+		BatchTimeout:  viper.GetDuration("server.kafka.batch_timeout"),
+		RetryInterval: viper.GetDuration("server.kafka.retry.interval"),
+	}
+}
+
 func LoggerConfig() *zap.Config {
 	return &zap.Config{
 		Level:         viper.GetString("logger.level"),
@@ -104,3 +408,12 @@ func serviceName() string {
 		return _defaultServiceName
 	}
 }
+
+// listenAddr returns address on all interfaces, empty port disables the listener.
+func listenAddr(port string) string {
+	if port == "" {
+		return ""
+	}
+
+	return fmt.Sprintf("0.0.0.0:%s", port)
+}
@@ -0,0 +1,49 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGRPCServerConfig(t *testing.T) {
+	tests := []struct {
+		name         string
+		env          map[string]string
+		expectedAddr string
+	}{
+		{
+			name:         "Default",
+			expectedAddr: "0.0.0.0:4317",
+		},
+		{
+			name:         "Port",
+			env:          map[string]string{"SERVER_GRPC_PORT": "14317"},
+			expectedAddr: "0.0.0.0:14317",
+		},
+		{
+			name: "Disabled",
+			env:  map[string]string{"SERVER_GRPC_ENABLE": "false"},
+		},
+		{
+			name:         "EmptyPortEnv",
+			env:          map[string]string{"SERVER_GRPC_PORT": ""},
+			expectedAddr: "0.0.0.0:4317",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			viper.Reset()
+			t.Cleanup(viper.Reset)
+
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+
+			Init()
+
+			assert.Equal(t, tt.expectedAddr, GRPCServerConfig().Addr)
+		})
+	}
+}
@@ -0,0 +1,124 @@
+package v1
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+const (
+	ActionIndex  = "index"
+	ActionCreate = "create"
+	ActionUpdate = "update"
+	ActionDelete = "delete"
+)
+
+var (
+	ErrInvalidAction     = errors.New("malformed action/metadata line")
+	ErrUnsupportedAction = errors.New("action is not supported")
+	ErrMissingDocument   = errors.New("document line is missing")
+	ErrMissingIndex      = errors.New("index is missing")
+)
+
+// Action is an action/metadata line of the bulk request.
+type Action struct {
+	Type  string
+	Index string `json:"_index"`
+	ID    string `json:"_id"`
+}
+
+// parseAction parses action line like {"index": {"_index": "logs", "_id": "1"}}.
+func parseAction(data []byte) (*Action, error) {
+	var line map[string]*Action
+
+	if err := json.Unmarshal(data, &line); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidAction, err)
+	}
+
+	if len(line) != 1 {
+		return nil, fmt.Errorf("%w: expected one action", ErrInvalidAction)
+	}
+
+	for typ, action := range line {
+		if action == nil {
+			action = &Action{}
+		}
+
+		action.Type = typ
+
+		switch typ {
+		case ActionIndex, ActionCreate, ActionUpdate, ActionDelete:
+			return action, nil
+		default:
+			return nil, fmt.Errorf("%w: unknown action %q", ErrInvalidAction, typ)
+		}
+	}
+
+	return nil, ErrInvalidAction
+}
+
+// HasDocument reports whether action is followed by a document line.
+func (a *Action) HasDocument() bool {
+	return a.Type != ActionDelete
+}
+
+type BulkResponse struct {
+	Took   int64                  `json:"took"`
+	Errors bool                   `json:"errors"`
+	Items  []map[string]*BulkItem `json:"items"`
+}
+
+type BulkItem struct {
+	Index       string      `json:"_index"`
+	Type        string      `json:"_type"`
+	ID          string      `json:"_id"`
+	Version     int         `json:"_version,omitempty"`
+	Result      string      `json:"result,omitempty"`
+	Shards      *Shards     `json:"_shards,omitempty"`
+	SeqNo       *int        `json:"_seq_no,omitempty"`
+	PrimaryTerm int         `json:"_primary_term,omitempty"`
+	Status      int         `json:"status"`
+	Error       *ErrorCause `json:"error,omitempty"`
+}
+
+type Shards struct {
+	Total      int `json:"total"`
+	Successful int `json:"successful"`
+	Failed     int `json:"failed"`
+}
+
+type ErrorCause struct {
+	Type   string `json:"type"`
+	Reason string `json:"reason"`
+}
+
+type ErrorResponse struct {
+	Error  ErrorCause `json:"error"`
+	Status int        `json:"status"`
+}
+
+func (r *BulkResponse) AddCreated(action *Action, seqNo int) {
+	r.Items = append(r.Items, map[string]*BulkItem{action.Type: {
+		Index:       action.Index,
+		Type:        "_doc",
+		ID:          action.ID,
+		Version:     1,
+		Result:      "created",
+		Shards:      &Shards{Total: 1, Successful: 1},
+		SeqNo:       &seqNo,
+		PrimaryTerm: 1,
+		Status:      http.StatusCreated,
+	}})
+}
+
+func (r *BulkResponse) AddError(action *Action, status int, errType string, err error) {
+	r.Errors = true
+	r.Items = append(r.Items, map[string]*BulkItem{action.Type: {
+		Index:  action.Index,
+		Type:   "_doc",
+		ID:     action.ID,
+		Status: status,
+		Error:  &ErrorCause{Type: errType, Reason: err.Error()},
+	}})
+}
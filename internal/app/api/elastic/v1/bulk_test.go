@@ -0,0 +1,69 @@
+package v1
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseAction(t *testing.T) {
+	tests := []struct {
+		name        string
+		data        string
+		wantErr     error
+		expectedRes *Action
+	}{
+		{
+			name:        "IndexPass",
+			data:        `{"index":{"_index":"logs","_id":"1"}}`,
+			expectedRes: &Action{Type: ActionIndex, Index: "logs", ID: "1"},
+		},
+		{
+			name:        "CreateWithoutMetadataPass",
+			data:        `{"create":{}}`,
+			expectedRes: &Action{Type: ActionCreate},
+		},
+		{
+			name:        "NullMetadataPass",
+			data:        `{"update":null}`,
+			expectedRes: &Action{Type: ActionUpdate},
+		},
+		{
+			name:        "DeletePass",
+			data:        `{"delete":{"_index":"logs","_id":"1"}}`,
+			expectedRes: &Action{Type: ActionDelete, Index: "logs", ID: "1"},
+		},
+		{
+			name:    "UnknownActionError",
+			data:    `{"upsert":{"_index":"logs"}}`,
+			wantErr: ErrInvalidAction,
+		},
+		{
+			name:    "SeveralActionsError",
+			data:    `{"index":{},"create":{}}`,
+			wantErr: ErrInvalidAction,
+		},
+		{
+			name:    "EmptyError",
+			data:    `{}`,
+			wantErr: ErrInvalidAction,
+		},
+		{
+			name:    "DocumentError",
+			data:    `{"message":"document instead of action"}`,
+			wantErr: ErrInvalidAction,
+		},
+		{
+			name:    "InvalidJSONError",
+			data:    `{"index":`,
+			wantErr: ErrInvalidAction,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := parseAction([]byte(tt.data))
+			assert.ErrorIs(t, err, tt.wantErr)
+			assert.Equal(t, tt.expectedRes, res)
+		})
+	}
+}
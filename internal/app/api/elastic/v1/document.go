@@ -0,0 +1,130 @@
+package v1
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/loghole/collector/internal/app/domain"
+)
+
+var ErrInvalidTimestamp = errors.New("invalid @timestamp")
+
+// parseDocument converts document source to entry. The index name is used as namespace,
+// @timestamp as time and common ECS objects are mapped onto the entry fields.
+func parseDocument(action *Action, data []byte) (*domain.Entry, error) {
+	fields, err := decodeDocument(action, data)
+	if err != nil {
+		return nil, err
+	}
+
+	if val, ok := fields["@timestamp"]; ok {
+		timestamp, err := parseTimestamp(val)
+		if err != nil {
+			return nil, err
+		}
+
+		delete(fields, "@timestamp")
+		setDefault(fields, "time", timestamp)
+	}
+
+	mapECS(fields)
+	setDefault(fields, "namespace", action.Index)
+
+	return domain.NewEntry(fields)
+}
+
+// decodeDocument returns document fields, update action keeps document in the "doc" key.
+func decodeDocument(action *Action, data []byte) (map[string]interface{}, error) {
+	var fields map[string]interface{}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	if err := decoder.Decode(&fields); err != nil {
+		return nil, err
+	}
+
+	if action.Type != ActionUpdate {
+		return fields, nil
+	}
+
+	doc, ok := fields["doc"].(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%w: update without doc", ErrUnsupportedAction)
+	}
+
+	return doc, nil
+}
+
+// parseTimestamp parses date in the RFC3339 format or epoch milliseconds.
+func parseTimestamp(val interface{}) (time.Time, error) {
+	switch val := val.(type) {
+	case string:
+		if t, err := time.Parse(time.RFC3339Nano, val); err == nil {
+			return t, nil
+		}
+
+		if ms, err := strconv.ParseInt(val, 10, 64); err == nil {
+			return time.Unix(0, ms*int64(time.Millisecond)), nil
+		}
+	case json.Number:
+		if ms, err := val.Int64(); err == nil {
+			return time.Unix(0, ms*int64(time.Millisecond)), nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("%w: %v", ErrInvalidTimestamp, val)
+}
+
+// mapECS maps Elastic Common Schema objects sent by beats onto the entry fields.
+func mapECS(fields map[string]interface{}) {
+	ecs := []struct {
+		object, key, field string
+	}{
+		{object: "host", key: "name", field: "host"},
+		{object: "log", key: "level", field: "level"},
+		{object: "service", key: "name", field: "source"},
+		{object: "trace", key: "id", field: "trace_id"},
+	}
+
+	for _, item := range ecs {
+		object, ok := fields[item.object].(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		val, ok := object[item.key].(string)
+		if !ok {
+			continue
+		}
+
+		if item.object != item.field {
+			setDefault(fields, item.field, val)
+
+			continue
+		}
+
+		// Object replaced by its value, keep the rest of it in dotted keys.
+		for key, nested := range object {
+			if key != item.key {
+				fields[item.object+"."+key] = nested
+			}
+		}
+
+		fields[item.field] = val
+	}
+}
+
+func setDefault(fields map[string]interface{}, key string, val interface{}) {
+	if str, ok := val.(string); ok && str == "" {
+		return
+	}
+
+	if _, ok := fields[key]; !ok {
+		fields[key] = val
+	}
+}
@@ -0,0 +1,174 @@
+package v1
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseDocument(t *testing.T) {
+	type expected struct {
+		Time      time.Time
+		Namespace string
+		Source    string
+		Host      string
+		Level     string
+		TraceID   string
+		Message   string
+	}
+
+	tests := []struct {
+		name        string
+		action      *Action
+		data        string
+		wantErr     error
+		expectedRes expected
+	}{
+		{
+			name:   "RFC3339Pass",
+			action: &Action{Type: ActionIndex, Index: "logs"},
+			data:   `{"@timestamp":"2022-10-18T09:30:00.5Z","message":"first"}`,
+			expectedRes: expected{
+				Time:      time.Date(2022, 10, 18, 9, 30, 0, 500000000, time.UTC),
+				Namespace: "logs",
+				Message:   "first",
+			},
+		},
+		{
+			name:   "EpochMillisPass",
+			action: &Action{Type: ActionCreate, Index: "logs"},
+			data:   `{"@timestamp":1666085400500,"message":"first"}`,
+			expectedRes: expected{
+				Time:      time.Date(2022, 10, 18, 9, 30, 0, 500000000, time.UTC),
+				Namespace: "logs",
+				Message:   "first",
+			},
+		},
+		{
+			name:   "EpochMillisStringPass",
+			action: &Action{Type: ActionIndex, Index: "logs"},
+			data:   `{"@timestamp":"1666085400500","message":"first"}`,
+			expectedRes: expected{
+				Time:      time.Date(2022, 10, 18, 9, 30, 0, 500000000, time.UTC),
+				Namespace: "logs",
+				Message:   "first",
+			},
+		},
+		{
+			name:   "ECSPass",
+			action: &Action{Type: ActionIndex, Index: "filebeat"},
+			data: `{"message":"first","host":{"name":"web-1","os":"linux"},"log":{"level":"warn"},
+				"service":{"name":"api"},"trace":{"id":"abc"}}`,
+			expectedRes: expected{
+				Namespace: "filebeat",
+				Source:    "api",
+				Host:      "web-1",
+				Level:     "warn",
+				TraceID:   "abc",
+				Message:   "first",
+			},
+		},
+		{
+			name:   "DocumentFieldsPriorityPass",
+			action: &Action{Type: ActionIndex, Index: "logs"},
+			data:   `{"message":"first","namespace":"app","level":"error","log":{"level":"warn"}}`,
+			expectedRes: expected{
+				Namespace: "app",
+				Level:     "error",
+				Message:   "first",
+			},
+		},
+		{
+			name:   "UpdatePass",
+			action: &Action{Type: ActionUpdate, Index: "logs"},
+			data:   `{"doc":{"message":"updated"},"doc_as_upsert":true}`,
+			expectedRes: expected{
+				Namespace: "logs",
+				Message:   "updated",
+			},
+		},
+		{
+			name:    "UpdateWithoutDocError",
+			action:  &Action{Type: ActionUpdate, Index: "logs"},
+			data:    `{"script":{"source":"ctx._source.counter += 1"}}`,
+			wantErr: ErrUnsupportedAction,
+		},
+		{
+			name:    "InvalidTimestampError",
+			action:  &Action{Type: ActionIndex, Index: "logs"},
+			data:    `{"@timestamp":"yesterday","message":"first"}`,
+			wantErr: ErrInvalidTimestamp,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entry, err := parseDocument(tt.action, []byte(tt.data))
+			assert.ErrorIs(t, err, tt.wantErr)
+
+			if tt.wantErr != nil {
+				return
+			}
+
+			assert.Equal(t, tt.expectedRes, expected{
+				Time:      entry.Time.UTC(),
+				Namespace: entry.Namespace,
+				Source:    entry.Source,
+				Host:      entry.Host,
+				Level:     entry.Level,
+				TraceID:   entry.TraceID,
+				Message:   entry.Message,
+			})
+		})
+	}
+}
+
+func TestMapECS(t *testing.T) {
+	tests := []struct {
+		name           string
+		fields         map[string]interface{}
+		expectedFields map[string]interface{}
+	}{
+		{
+			name: "ObjectReplacedPass",
+			fields: map[string]interface{}{
+				"host": map[string]interface{}{"name": "web-1", "os": "linux"},
+			},
+			expectedFields: map[string]interface{}{
+				"host":    "web-1",
+				"host.os": "linux",
+			},
+		},
+		{
+			name: "ObjectKeptPass",
+			fields: map[string]interface{}{
+				"log":     map[string]interface{}{"level": "warn", "file": "app.log"},
+				"service": map[string]interface{}{"name": "api"},
+			},
+			expectedFields: map[string]interface{}{
+				"log":     map[string]interface{}{"level": "warn", "file": "app.log"},
+				"level":   "warn",
+				"service": map[string]interface{}{"name": "api"},
+				"source":  "api",
+			},
+		},
+		{
+			name: "NotStringPass",
+			fields: map[string]interface{}{
+				"host":  "web-1",
+				"trace": map[string]interface{}{"id": 42},
+			},
+			expectedFields: map[string]interface{}{
+				"host":  "web-1",
+				"trace": map[string]interface{}{"id": 42},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mapECS(tt.fields)
+
+			assert.Equal(t, tt.expectedFields, tt.fields)
+		})
+	}
+}
@@ -0,0 +1,216 @@
+package v1
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/loghole/tracing"
+	"github.com/loghole/tracing/tracelog"
+
+	"github.com/loghole/collector/config"
+	"github.com/loghole/collector/internal/app/domain"
+)
+
+const (
+	_maxLineSize = 10 << 20
+
+	_productHeader = "X-Elastic-Product"
+	_product       = "Elasticsearch"
+)
+
+type EntryService interface {
+	StoreEntryList(ctx context.Context, remoteIP string, list domain.EntryList) (err error)
+}
+
+type Logger interface {
+	Errorf(ctx context.Context, template string, args ...interface{})
+}
+
+type ElasticHandler struct {
+	service EntryService
+	logger  tracelog.Logger
+	tracer  *tracing.Tracer
+	version string
+}
+
+// NewElasticHandler creates handler, version is reported to clients as the elasticsearch version.
+func NewElasticHandler(
+	service EntryService,
+	logger tracelog.Logger,
+	tracer *tracing.Tracer,
+	version string,
+) *ElasticHandler {
+	return &ElasticHandler{
+		service: service,
+		logger:  logger,
+		tracer:  tracer,
+		version: version,
+	}
+}
+
+// InfoHandler responds to the cluster info request that clients send on connect.
+func (h *ElasticHandler) InfoHandler(w http.ResponseWriter, r *http.Request) {
+	writeJSON(r.Context(), w, h.logger, http.StatusOK, map[string]interface{}{
+		"name":         config.ServiceName,
+		"cluster_name": config.AppName,
+		"cluster_uuid": config.InstanceUUID.String(),
+		"version": map[string]interface{}{
+			"number":                              h.version,
+			"build_flavor":                        "default",
+			"build_type":                          "docker",
+			"build_hash":                          config.GitHash,
+			"build_date":                          config.BuildAt,
+			"build_snapshot":                      false,
+			"lucene_version":                      "8.11.1",
+			"minimum_wire_compatibility_version":  "6.8.0",
+			"minimum_index_compatibility_version": "6.0.0-beta1",
+		},
+		"tagline": "You Know, for Search",
+	})
+}
+
+// LicenseHandler responds with active basic license.
+func (h *ElasticHandler) LicenseHandler(w http.ResponseWriter, r *http.Request) {
+	writeJSON(r.Context(), w, h.logger, http.StatusOK, map[string]interface{}{
+		"license": map[string]interface{}{
+			"status":               "active",
+			"uid":                  config.InstanceUUID.String(),
+			"type":                 "basic",
+			"issue_date_in_millis": 0,
+			"max_nodes":            1000,
+			"issued_to":            config.AppName,
+			"issuer":               "elasticsearch",
+			"start_date_in_millis": -1,
+		},
+	})
+}
+
+// BulkHandler receives documents in the bulk api format, each action line is followed by a document line.
+// https://www.elastic.co/guide/en/elasticsearch/reference/current/docs-bulk.html
+func (h *ElasticHandler) BulkHandler(w http.ResponseWriter, r *http.Request) {
+	var (
+		ctx     = r.Context()
+		start   = time.Now()
+		index   = mux.Vars(r)["index"]
+		resp    = &BulkResponse{Items: make([]map[string]*BulkItem, 0)}
+		list    = make(domain.EntryList, 0)
+		scanner = bufio.NewScanner(r.Body)
+	)
+
+	scanner.Buffer(make([]byte, 0, bufio.MaxScanTokenSize), _maxLineSize)
+
+	for scanLine(scanner) {
+		action, err := parseAction(scanner.Bytes())
+		if err != nil {
+			h.writeError(ctx, w, http.StatusBadRequest, "illegal_argument_exception", err)
+
+			return
+		}
+
+		if action.Index == "" {
+			action.Index = index
+		}
+
+		if action.ID == "" {
+			action.ID = uuid.NewString()
+		}
+
+		if !action.HasDocument() {
+			resp.AddError(action, http.StatusBadRequest, "illegal_argument_exception", ErrUnsupportedAction)
+
+			continue
+		}
+
+		if !scanLine(scanner) {
+			h.writeError(ctx, w, http.StatusBadRequest, "illegal_argument_exception", ErrMissingDocument)
+
+			return
+		}
+
+		entry, err := h.parseItem(action, scanner.Bytes())
+		if err != nil {
+			resp.AddError(action, http.StatusBadRequest, "mapper_parsing_exception", err)
+
+			continue
+		}
+
+		list = append(list, entry)
+		resp.AddCreated(action, len(list)-1)
+	}
+
+	if err := scanner.Err(); err != nil {
+		h.logger.Errorf(ctx, "read body failed: %v", err)
+		h.writeError(ctx, w, http.StatusBadRequest, "parse_exception", err)
+
+		return
+	}
+
+	if err := h.service.StoreEntryList(ctx, r.RemoteAddr, list); err != nil {
+		h.logger.Errorf(ctx, "store entry list failed: %v", err)
+
+		if retryAfter, ok := domain.RetryAfter(err); ok {
+			w.Header().Set("Retry-After", retryAfter)
+			h.writeError(ctx, w, http.StatusServiceUnavailable, "es_rejected_execution_exception", domain.ErrQueueFull)
+
+			return
+		}
+
+		if errors.Is(err, domain.ErrListTooLarge) {
+			h.writeError(ctx, w, http.StatusRequestEntityTooLarge, "illegal_argument_exception", domain.ErrListTooLarge)
+
+			return
+		}
+
+		h.writeError(ctx, w, http.StatusInternalServerError, "exception", errors.New("store failed"))
+
+		return
+	}
+
+	resp.Took = time.Since(start).Milliseconds()
+
+	writeJSON(ctx, w, h.logger, http.StatusOK, resp)
+}
+
+// scanLine advances scanner to the next non-empty line.
+func scanLine(scanner *bufio.Scanner) bool {
+	for scanner.Scan() {
+		if len(bytes.TrimSpace(scanner.Bytes())) > 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (h *ElasticHandler) parseItem(action *Action, data []byte) (*domain.Entry, error) {
+	if action.Index == "" {
+		return nil, ErrMissingIndex
+	}
+
+	return parseDocument(action, data)
+}
+
+func (h *ElasticHandler) writeError(ctx context.Context, w http.ResponseWriter, status int, errType string, err error) {
+	writeJSON(ctx, w, h.logger, status, &ErrorResponse{
+		Error:  ErrorCause{Type: errType, Reason: err.Error()},
+		Status: status,
+	})
+}
+
+func writeJSON(ctx context.Context, w http.ResponseWriter, log Logger, status int, v interface{}) {
+	w.Header().Add("Content-Type", "application/json")
+	w.Header().Set(_productHeader, _product)
+
+	w.WriteHeader(status)
+
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Errorf(ctx, "write response failed: %v", err)
+	}
+}
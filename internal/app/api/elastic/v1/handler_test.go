@@ -0,0 +1,188 @@
+package v1
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/loghole/tracing/tracelog"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+
+	"github.com/loghole/collector/internal/app/domain"
+)
+
+type serviceMock struct {
+	err  error
+	list domain.EntryList
+}
+
+func (s *serviceMock) StoreEntryList(ctx context.Context, remoteIP string, list domain.EntryList) error {
+	if s.err != nil {
+		return s.err
+	}
+
+	s.list = append(s.list, list...)
+
+	return nil
+}
+
+func TestElasticHandler_BulkHandler(t *testing.T) {
+	type item struct {
+		Action string
+		Index  string
+		Status int
+	}
+
+	tests := []struct {
+		name             string
+		body             string
+		expectedStatus   int
+		expectedErrors   bool
+		expectedItems    []item
+		expectedMessages []string
+	}{
+		{
+			name: "Pass",
+			body: `{"index":{}}
+{"message":"first"}
+{"create":{"_index":"other"}}
+{"message":"second"}
+`,
+			expectedStatus: http.StatusOK,
+			expectedItems: []item{
+				{Action: ActionIndex, Index: "logs", Status: http.StatusCreated},
+				{Action: ActionCreate, Index: "other", Status: http.StatusCreated},
+			},
+			expectedMessages: []string{"first", "second"},
+		},
+		{
+			name: "EmptyLinesPass",
+			body: `{"index":{}}
+
+{"message":"first"}
+
+{"index":{}}
+  
+{"message":"second"}`,
+			expectedStatus: http.StatusOK,
+			expectedItems: []item{
+				{Action: ActionIndex, Index: "logs", Status: http.StatusCreated},
+				{Action: ActionIndex, Index: "logs", Status: http.StatusCreated},
+			},
+			expectedMessages: []string{"first", "second"},
+		},
+		{
+			name: "DeleteAndUpdatePass",
+			body: `{"delete":{"_id":"1"}}
+{"update":{"_id":"2"}}
+{"doc":{"message":"updated"}}
+{"update":{"_id":"3"}}
+{"script":{"source":"ctx._source.counter += 1"}}
+{"index":{}}
+{"message":"first"}
+`,
+			expectedStatus: http.StatusOK,
+			expectedErrors: true,
+			expectedItems: []item{
+				{Action: ActionDelete, Index: "logs", Status: http.StatusBadRequest},
+				{Action: ActionUpdate, Index: "logs", Status: http.StatusCreated},
+				{Action: ActionUpdate, Index: "logs", Status: http.StatusBadRequest},
+				{Action: ActionIndex, Index: "logs", Status: http.StatusCreated},
+			},
+			expectedMessages: []string{"updated", "first"},
+		},
+		{
+			name: "InvalidDocumentPass",
+			body: `{"index":{}}
+{"message":
+{"index":{}}
+{"message":"second"}
+`,
+			expectedStatus: http.StatusOK,
+			expectedErrors: true,
+			expectedItems: []item{
+				{Action: ActionIndex, Index: "logs", Status: http.StatusBadRequest},
+				{Action: ActionIndex, Index: "logs", Status: http.StatusCreated},
+			},
+			expectedMessages: []string{"second"},
+		},
+		{
+			name: "MissingDocumentError",
+			body: `{"index":{}}
+
+`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name: "InvalidActionError",
+			body: `{"message":"first"}
+`,
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				service = &serviceMock{}
+				handler = NewElasticHandler(service, tracelog.NewTraceLogger(zap.NewNop().Sugar()), nil, "7.17.0")
+				rec     = httptest.NewRecorder()
+				req     = httptest.NewRequest(http.MethodPost, "/logs/_bulk", strings.NewReader(tt.body))
+			)
+
+			handler.BulkHandler(rec, mux.SetURLVars(req, map[string]string{"index": "logs"}))
+
+			assert.Equal(t, tt.expectedStatus, rec.Code)
+
+			if tt.expectedStatus != http.StatusOK {
+				assert.Empty(t, service.list)
+
+				return
+			}
+
+			var resp BulkResponse
+
+			if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+				t.Fatal(err)
+			}
+
+			items := make([]item, 0, len(resp.Items))
+
+			for _, bulkItem := range resp.Items {
+				for action, res := range bulkItem {
+					items = append(items, item{Action: action, Index: res.Index, Status: res.Status})
+				}
+			}
+
+			messages := make([]string, 0, len(service.list))
+
+			for _, entry := range service.list {
+				messages = append(messages, entry.Message)
+			}
+
+			assert.Equal(t, tt.expectedItems, items)
+			assert.Equal(t, tt.expectedErrors, resp.Errors)
+			assert.Equal(t, tt.expectedMessages, messages)
+		})
+	}
+}
+
+func TestElasticHandler_BulkHandler_QueueFull(t *testing.T) {
+	var (
+		service = &serviceMock{err: &domain.QueueFullError{RetryAfter: 2500 * time.Millisecond}}
+		handler = NewElasticHandler(service, tracelog.NewTraceLogger(zap.NewNop().Sugar()), nil, "7.17.0")
+		rec     = httptest.NewRecorder()
+		req     = httptest.NewRequest(http.MethodPost, "/_bulk",
+			strings.NewReader("{\"index\":{\"_index\":\"logs\"}}\n{\"message\":\"first\"}\n"))
+	)
+
+	handler.BulkHandler(rec, req)
+
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Equal(t, "3", rec.Header().Get("Retry-After"))
+}
@@ -2,20 +2,41 @@ package v1
 
 import (
 	"context"
+	"errors"
 	"io"
 	"net/http"
+	"strconv"
 
 	"github.com/lissteron/simplerr"
 	"github.com/loghole/tracing"
 	"github.com/loghole/tracing/tracelog"
 
+	"github.com/loghole/collector/internal/app/api/middleware"
 	"github.com/loghole/collector/internal/app/codes"
+	"github.com/loghole/collector/internal/app/domain"
+)
+
+const (
+	_listModeReport = "report"
+	_listModeStrict = "strict"
+
+	// _syncHeader or the sync query parameter makes the request wait until entries are written.
+	_syncHeader = "X-Collector-Sync"
+	_syncQuery  = "sync"
+)
+
+var (
+	ErrUnknownMode = errors.New("unknown mode")
+	ErrInvalidSync = errors.New("invalid sync value")
 )
 
 type EntryService interface {
 	Ping(ctx context.Context) error
-	StoreItem(ctx context.Context, remoteIP string, data []byte) (err error)
-	StoreList(ctx context.Context, remoteIP string, data []byte) (err error)
+	Stats(ctx context.Context) domain.WriterStats
+	StoreItem(ctx context.Context, remoteIP string, data []byte, sync bool) (err error)
+	StoreList(ctx context.Context, remoteIP string, data []byte, sync bool) (err error)
+	StoreListResult(ctx context.Context, remoteIP string, data []byte, strict, sync bool) (*domain.StoreResult, error)
+	StoreNDJSON(ctx context.Context, remoteIP string, r io.Reader) (*domain.StoreResult, error)
 }
 
 type EntryHandlers struct {
@@ -36,10 +57,18 @@ func NewEntryHandlers(
 	}
 }
 
+// StoreItemHandler receives json object of entry. In sync mode the response is sent after the entry was written.
 func (h *EntryHandlers) StoreItemHandler(w http.ResponseWriter, r *http.Request) {
 	resp, ctx := NewBaseResponse(), r.Context()
 	defer resp.Write(ctx, w, h.logger)
 
+	sync, err := syncMode(r)
+	if err != nil {
+		resp.ParseError(err)
+
+		return
+	}
+
 	data, err := readData(r.Body)
 	if err != nil {
 		h.logger.Errorf(ctx, "read body failed: %v", err)
@@ -48,17 +77,27 @@ func (h *EntryHandlers) StoreItemHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	err = h.service.StoreItem(ctx, r.RemoteAddr, data)
+	err = h.service.StoreItem(ctx, r.RemoteAddr, data, sync)
 	if err != nil {
 		h.logger.Errorf(ctx, "store entry item failed: %v", err)
 		resp.ParseError(err)
 	}
 }
 
+// StoreListHandler receives json array of entries. Invalid elements are dropped unless mode is set:
+// "report" reports rejected elements in the response, "strict" rejects the whole list on any invalid element.
+// In sync mode the response is sent after the entries were written.
 func (h *EntryHandlers) StoreListHandler(w http.ResponseWriter, r *http.Request) {
 	resp, ctx := NewBaseResponse(), r.Context()
 	defer resp.Write(ctx, w, h.logger)
 
+	sync, err := syncMode(r)
+	if err != nil {
+		resp.ParseError(err)
+
+		return
+	}
+
 	data, err := readData(r.Body)
 	if err != nil {
 		h.logger.Errorf(ctx, "read body failed: %v", err)
@@ -67,13 +106,43 @@ func (h *EntryHandlers) StoreListHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	err = h.service.StoreList(ctx, r.RemoteAddr, data)
+	switch mode := r.URL.Query().Get("mode"); mode {
+	case "":
+		err = h.service.StoreList(ctx, r.RemoteAddr, data, sync)
+	case _listModeReport, _listModeStrict:
+		var result *domain.StoreResult
+
+		result, err = h.service.StoreListResult(ctx, r.RemoteAddr, data, mode == _listModeStrict, sync)
+		resp.SetData(NewListResult(result))
+	default:
+		err = simplerr.WrapWithCode(ErrUnknownMode, simplerr.InvalidArgumentCode(codes.ValidationError), "unknown mode")
+	}
+
 	if err != nil {
 		h.logger.Errorf(ctx, "store entry list failed: %v", err)
 		resp.ParseError(err)
 	}
 }
 
+// StoreNDJSONHandler receives newline delimited json objects, the body is stored while it is read.
+// If the decoded body exceeds the size limit the response has 413 status and reports the stored part.
+func (h *EntryHandlers) StoreNDJSONHandler(w http.ResponseWriter, r *http.Request) {
+	resp, ctx := NewBaseResponse(), r.Context()
+	defer resp.Write(ctx, w, h.logger)
+
+	result, err := h.service.StoreNDJSON(ctx, r.RemoteAddr, r.Body)
+	if err != nil {
+		h.logger.Errorf(ctx, "store ndjson failed: %v", err)
+		resp.ParseError(err)
+
+		if errors.Is(err, middleware.ErrBodyTooLarge) {
+			resp.Status = http.StatusRequestEntityTooLarge
+		}
+	}
+
+	resp.SetData(NewStoreResult(result))
+}
+
 func (h *EntryHandlers) PingHandler(w http.ResponseWriter, r *http.Request) {
 	resp, ctx := NewBaseResponse(), r.Context()
 	defer resp.Write(ctx, w, h.logger)
@@ -84,6 +153,34 @@ func (h *EntryHandlers) PingHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// StatsHandler responds with the writer queue state and the numbers of rejected and dropped entries.
+func (h *EntryHandlers) StatsHandler(w http.ResponseWriter, r *http.Request) {
+	resp, ctx := NewBaseResponse(), r.Context()
+	defer resp.Write(ctx, w, h.logger)
+
+	resp.SetData(NewWriterStats(h.service.Stats(ctx)))
+}
+
+// syncMode reports whether the client waits until entries are written, the header takes precedence.
+func syncMode(r *http.Request) (bool, error) {
+	value := r.Header.Get(_syncHeader)
+	if value == "" {
+		value = r.URL.Query().Get(_syncQuery)
+	}
+
+	if value == "" {
+		return false, nil
+	}
+
+	sync, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, simplerr.WrapWithCode(ErrInvalidSync, simplerr.InvalidArgumentCode(codes.ValidationError),
+			"invalid sync value")
+	}
+
+	return sync, nil
+}
+
 func readData(r io.Reader) ([]byte, error) {
 	data, err := io.ReadAll(r)
 	if err != nil {
@@ -0,0 +1,323 @@
+package v1
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lissteron/simplerr"
+	"github.com/loghole/tracing/tracelog"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+
+	"github.com/loghole/collector/internal/app/codes"
+	"github.com/loghole/collector/internal/app/domain"
+)
+
+type serviceMock struct {
+	err    error
+	result *domain.StoreResult
+
+	called bool
+	data   string
+	sync   bool
+	strict bool
+}
+
+func (s *serviceMock) Ping(ctx context.Context) error { return s.err }
+
+func (s *serviceMock) Stats(ctx context.Context) domain.WriterStats { return domain.WriterStats{} }
+
+func (s *serviceMock) StoreItem(ctx context.Context, remoteIP string, data []byte, sync bool) error {
+	s.called, s.data, s.sync = true, string(data), sync
+
+	return s.err
+}
+
+func (s *serviceMock) StoreList(ctx context.Context, remoteIP string, data []byte, sync bool) error {
+	s.called, s.data, s.sync = true, string(data), sync
+
+	return s.err
+}
+
+func (s *serviceMock) StoreListResult(
+	ctx context.Context,
+	remoteIP string,
+	data []byte,
+	strict, sync bool,
+) (*domain.StoreResult, error) {
+	s.called, s.data, s.sync, s.strict = true, string(data), sync, strict
+
+	return s.result, s.err
+}
+
+func (s *serviceMock) StoreNDJSON(ctx context.Context, remoteIP string, r io.Reader) (*domain.StoreResult, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	s.called, s.data = true, string(data)
+
+	return s.result, s.err
+}
+
+// Store errors as they are returned by the entry service.
+var (
+	errQueueFull = simplerr.WrapWithCode(&domain.QueueFullError{RetryAfter: 2500 * time.Millisecond},
+		codes.UnavailableCode(codes.QueueFullError), "writer queue is full")
+	errListTooLarge = simplerr.WrapWithCode(domain.ErrListTooLarge,
+		codes.TooLargeCode(codes.ValidationError), "entry list is too large")
+	errWriterStopped = simplerr.WrapWithCode(domain.ErrWriterStopped,
+		codes.UnavailableCode(codes.SystemError), "writer is stopped")
+	errStore = simplerr.WrapWithCode(errors.New("insert failed"),
+		simplerr.InternalCode(codes.DatabaseError), "store failed")
+)
+
+func TestEntryHandlers_StoreItemHandler(t *testing.T) {
+	tests := []struct {
+		name               string
+		target             string
+		header             string
+		storeErr           error
+		expectedStatus     int
+		expectedRetryAfter string
+		expectedSync       bool
+		expectedCalled     bool
+	}{
+		{
+			name:           "Pass",
+			target:         "/api/v1/store",
+			expectedStatus: http.StatusOK,
+			expectedCalled: true,
+		},
+		{
+			name:           "SyncHeaderPass",
+			target:         "/api/v1/store",
+			header:         "true",
+			expectedStatus: http.StatusOK,
+			expectedSync:   true,
+			expectedCalled: true,
+		},
+		{
+			name:           "SyncQueryPass",
+			target:         "/api/v1/store?sync=1",
+			expectedStatus: http.StatusOK,
+			expectedSync:   true,
+			expectedCalled: true,
+		},
+		{
+			name:           "SyncHeaderPrecedencePass",
+			target:         "/api/v1/store?sync=true",
+			header:         "false",
+			expectedStatus: http.StatusOK,
+			expectedCalled: true,
+		},
+		{
+			name:           "InvalidSyncError",
+			target:         "/api/v1/store",
+			header:         "maybe",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:               "QueueFullError",
+			target:             "/api/v1/store",
+			storeErr:           errQueueFull,
+			expectedStatus:     http.StatusServiceUnavailable,
+			expectedRetryAfter: "3",
+			expectedCalled:     true,
+		},
+		{
+			name:           "WriterStoppedError",
+			target:         "/api/v1/store?sync=true",
+			storeErr:       errWriterStopped,
+			expectedStatus: http.StatusServiceUnavailable,
+			expectedSync:   true,
+			expectedCalled: true,
+		},
+		{
+			name:           "ListTooLargeError",
+			target:         "/api/v1/store",
+			storeErr:       errListTooLarge,
+			expectedStatus: http.StatusRequestEntityTooLarge,
+			expectedCalled: true,
+		},
+		{
+			name:           "StoreError",
+			target:         "/api/v1/store",
+			storeErr:       errStore,
+			expectedStatus: http.StatusInternalServerError,
+			expectedCalled: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				service = &serviceMock{err: tt.storeErr}
+				handler = NewEntryHandlers(service, tracelog.NewTraceLogger(zap.NewNop().Sugar()), nil)
+				rec     = httptest.NewRecorder()
+				req     = httptest.NewRequest(http.MethodPost, tt.target, strings.NewReader(`{"message":"first"}`))
+			)
+
+			if tt.header != "" {
+				req.Header.Set(_syncHeader, tt.header)
+			}
+
+			handler.StoreItemHandler(rec, req)
+
+			assert.Equal(t, tt.expectedStatus, rec.Code)
+			assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+			assert.Equal(t, tt.expectedRetryAfter, rec.Header().Get("Retry-After"))
+			assert.Equal(t, tt.expectedCalled, service.called)
+			assert.Equal(t, tt.expectedSync, service.sync)
+		})
+	}
+}
+
+func TestEntryHandlers_StoreListHandler(t *testing.T) {
+	const body = `[{"message":"first"},{"message":"second"}]`
+
+	tests := []struct {
+		name               string
+		target             string
+		header             string
+		storeErr           error
+		result             *domain.StoreResult
+		expectedStatus     int
+		expectedRetryAfter string
+		expectedSync       bool
+		expectedStrict     bool
+		expectedResp       string
+	}{
+		{
+			name:           "Pass",
+			target:         "/api/v1/store/list",
+			expectedStatus: http.StatusOK,
+			expectedResp:   `{"errors":null,"data":null}`,
+		},
+		{
+			name:           "SyncHeaderPass",
+			target:         "/api/v1/store/list",
+			header:         "1",
+			expectedStatus: http.StatusOK,
+			expectedSync:   true,
+			expectedResp:   `{"errors":null,"data":null}`,
+		},
+		{
+			name:           "ReportModePass",
+			target:         "/api/v1/store/list?mode=report&sync=true",
+			result:         &domain.StoreResult{Accepted: 2},
+			expectedStatus: http.StatusOK,
+			expectedSync:   true,
+			expectedResp:   `{"errors":null,"data":{"accepted":2,"rejected":0}}`,
+		},
+		{
+			name:           "StrictModePass",
+			target:         "/api/v1/store/list?mode=strict",
+			result:         &domain.StoreResult{Accepted: 2},
+			expectedStatus: http.StatusOK,
+			expectedStrict: true,
+			expectedResp:   `{"errors":null,"data":{"accepted":2,"rejected":0}}`,
+		},
+		{
+			name:               "QueueFullError",
+			target:             "/api/v1/store/list?sync=true",
+			storeErr:           errQueueFull,
+			expectedStatus:     http.StatusServiceUnavailable,
+			expectedRetryAfter: "3",
+			expectedSync:       true,
+			expectedResp:       `{"errors":[{"code":"1002","detail":"writer queue is full"}],"data":null}`,
+		},
+		{
+			name:           "ListTooLargeError",
+			target:         "/api/v1/store/list",
+			storeErr:       errListTooLarge,
+			expectedStatus: http.StatusRequestEntityTooLarge,
+			expectedResp:   `{"errors":[{"code":"2001","detail":"entry list is too large"}],"data":null}`,
+		},
+		{
+			name:           "ReportModeListTooLargeError",
+			target:         "/api/v1/store/list?mode=report",
+			storeErr:       errListTooLarge,
+			expectedStatus: http.StatusRequestEntityTooLarge,
+			expectedResp:   `{"errors":[{"code":"2001","detail":"entry list is too large"}],"data":null}`,
+		},
+		{
+			name:           "UnknownModeError",
+			target:         "/api/v1/store/list?mode=lenient",
+			expectedStatus: http.StatusBadRequest,
+			expectedResp:   `{"errors":[{"code":"2001","detail":"unknown mode"}],"data":null}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				service = &serviceMock{err: tt.storeErr, result: tt.result}
+				handler = NewEntryHandlers(service, tracelog.NewTraceLogger(zap.NewNop().Sugar()), nil)
+				rec     = httptest.NewRecorder()
+				req     = httptest.NewRequest(http.MethodPost, tt.target, strings.NewReader(body))
+			)
+
+			if tt.header != "" {
+				req.Header.Set(_syncHeader, tt.header)
+			}
+
+			handler.StoreListHandler(rec, req)
+
+			assert.Equal(t, tt.expectedStatus, rec.Code)
+			assert.Equal(t, tt.expectedRetryAfter, rec.Header().Get("Retry-After"))
+			assert.JSONEq(t, tt.expectedResp, rec.Body.String())
+			assert.Equal(t, tt.expectedSync, service.sync)
+			assert.Equal(t, tt.expectedStrict, service.strict)
+		})
+	}
+}
+
+func TestEntryHandlers_StoreNDJSONHandler(t *testing.T) {
+	tests := []struct {
+		name               string
+		storeErr           error
+		result             *domain.StoreResult
+		expectedStatus     int
+		expectedRetryAfter string
+	}{
+		{
+			name:           "Pass",
+			result:         &domain.StoreResult{Accepted: 2},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:               "QueueFullError",
+			storeErr:           errQueueFull,
+			expectedStatus:     http.StatusServiceUnavailable,
+			expectedRetryAfter: "3",
+		},
+		{
+			name:           "ListTooLargeError",
+			storeErr:       errListTooLarge,
+			expectedStatus: http.StatusRequestEntityTooLarge,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				service = &serviceMock{err: tt.storeErr, result: tt.result}
+				handler = NewEntryHandlers(service, tracelog.NewTraceLogger(zap.NewNop().Sugar()), nil)
+				rec     = httptest.NewRecorder()
+				req     = httptest.NewRequest(http.MethodPost, "/api/v1/store/ndjson",
+					strings.NewReader("{\"message\":\"first\"}\n{\"message\":\"second\"}\n"))
+			)
+
+			handler.StoreNDJSONHandler(rec, req)
+
+			assert.Equal(t, tt.expectedStatus, rec.Code)
+			assert.Equal(t, tt.expectedRetryAfter, rec.Header().Get("Retry-After"))
+			assert.Equal(t, "{\"message\":\"first\"}\n{\"message\":\"second\"}\n", service.data)
+		})
+	}
+}
@@ -7,6 +7,8 @@ import (
 	"strconv"
 
 	"github.com/lissteron/simplerr"
+
+	"github.com/loghole/collector/internal/app/domain"
 )
 
 type Logger interface {
@@ -14,9 +16,10 @@ type Logger interface {
 }
 
 type BaseResponse struct {
-	Status int         `json:"-"`
-	Errors []RespError `json:"errors"`
-	Data   interface{} `json:"data"`
+	Status     int         `json:"-"`
+	RetryAfter string      `json:"-"`
+	Errors     []RespError `json:"errors"`
+	Data       interface{} `json:"data"`
 }
 
 type RespError struct {
@@ -31,6 +34,10 @@ func NewBaseResponse() *BaseResponse {
 func (r *BaseResponse) Write(ctx context.Context, w http.ResponseWriter, log Logger) {
 	w.Header().Add("Content-Type", "application/json")
 
+	if r.RetryAfter != "" {
+		w.Header().Set("Retry-After", r.RetryAfter)
+	}
+
 	if r.Status != 0 {
 		w.WriteHeader(r.Status)
 	}
@@ -44,6 +51,7 @@ func (r *BaseResponse) ParseError(err error) {
 	code := simplerr.GetCode(err)
 
 	r.Status = code.HTTP()
+	r.RetryAfter, _ = domain.RetryAfter(err)
 
 	r.Errors = append(r.Errors, RespError{
 		Code:   strconv.Itoa(code.Int()),
@@ -54,3 +62,93 @@ func (r *BaseResponse) ParseError(err error) {
 func (r *BaseResponse) SetData(v interface{}) {
 	r.Data = v
 }
+
+type StoreResult struct {
+	Accepted int            `json:"accepted"`
+	Rejected int            `json:"rejected"`
+	Failures []EntryFailure `json:"failures,omitempty"`
+}
+
+// EntryFailure is a rejected entry, line is set for ndjson requests and index for json arrays.
+type EntryFailure struct {
+	Line  *int   `json:"line,omitempty"`
+	Index *int   `json:"index,omitempty"`
+	Code  string `json:"code"`
+	Error string `json:"error"`
+}
+
+// NewStoreResult returns result with failures by line number.
+func NewStoreResult(result *domain.StoreResult) *StoreResult {
+	return newStoreResult(result, func(failure *EntryFailure, number int) { failure.Line = &number })
+}
+
+// NewListResult returns result with failures by index of the entry in array.
+func NewListResult(result *domain.StoreResult) *StoreResult {
+	return newStoreResult(result, func(failure *EntryFailure, number int) { failure.Index = &number })
+}
+
+func newStoreResult(result *domain.StoreResult, setNumber func(failure *EntryFailure, number int)) *StoreResult {
+	if result == nil {
+		return nil
+	}
+
+	resp := &StoreResult{
+		Accepted: result.Accepted,
+		Rejected: result.Rejected,
+		Failures: make([]EntryFailure, 0, len(result.Failures)),
+	}
+
+	for _, failure := range result.Failures {
+		item := EntryFailure{Code: strconv.Itoa(failure.Code), Error: failure.Error}
+		setNumber(&item, failure.Number)
+
+		resp.Failures = append(resp.Failures, item)
+	}
+
+	return resp
+}
+
+type WriterStats struct {
+	QueueLength   int            `json:"queue_length"`
+	QueueCapacity int            `json:"queue_capacity"`
+	Rejected      uint64         `json:"rejected"`
+	DroppedOldest uint64         `json:"dropped_oldest"`
+	DroppedNewest uint64         `json:"dropped_newest"`
+	Failed        uint64         `json:"failed"`
+	WALSize       int64          `json:"wal_size"`
+	Healthy       bool           `json:"healthy"`
+	LastError     string         `json:"last_error,omitempty"`
+	LagSeconds    float64        `json:"lag_seconds"`
+	Backends      []BackendStats `json:"backends,omitempty"`
+}
+
+type BackendStats struct {
+	Name     string `json:"name"`
+	Optional bool   `json:"optional"`
+	*WriterStats
+}
+
+func NewWriterStats(stats domain.WriterStats) *WriterStats {
+	resp := &WriterStats{
+		QueueLength:   stats.QueueLength,
+		QueueCapacity: stats.QueueCapacity,
+		Rejected:      stats.Rejected,
+		DroppedOldest: stats.DroppedOldest,
+		DroppedNewest: stats.DroppedNewest,
+		Failed:        stats.Failed,
+		WALSize:       stats.WALSize,
+		Healthy:       stats.LastError == "",
+		LastError:     stats.LastError,
+		LagSeconds:    stats.Lag.Seconds(),
+	}
+
+	for _, backend := range stats.Backends {
+		resp.Backends = append(resp.Backends, BackendStats{
+			Name:        backend.Name,
+			Optional:    backend.Optional,
+			WriterStats: NewWriterStats(backend.WriterStats),
+		})
+	}
+
+	return resp
+}
@@ -0,0 +1,64 @@
+package fluent
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/loghole/collector/internal/app/domain"
+)
+
+// newEntry converts record to domain entry, record keys are mapped the same way as json object keys.
+// Tag is used as source if the record has no source, "log" key written by docker driver is used as message.
+func newEntry(tag string, eventTime time.Time, record map[string]interface{}) (*domain.Entry, error) {
+	fields := make(map[string]interface{}, len(record))
+
+	for key, val := range record {
+		fields[key] = normalize(val)
+	}
+
+	if _, ok := fields["message"]; !ok {
+		if line, ok := fields["log"]; ok {
+			fields["message"] = line
+			delete(fields, "log")
+		}
+	}
+
+	if _, ok := fields["source"]; !ok && tag != "" {
+		fields["source"] = tag
+	}
+
+	fields["time"] = eventTime
+
+	return domain.NewEntry(fields)
+}
+
+// normalize converts msgpack values which are not marshaled to json as is: binary strings and maps with
+// not string keys.
+func normalize(val interface{}) interface{} {
+	switch val := val.(type) {
+	case []byte:
+		return string(val)
+	case map[string]interface{}:
+		for key, item := range val {
+			val[key] = normalize(item)
+		}
+
+		return val
+	case map[interface{}]interface{}:
+		result := make(map[string]interface{}, len(val))
+
+		for key, item := range val {
+			result[fmt.Sprint(normalize(key))] = normalize(item)
+		}
+
+		return result
+	case []interface{}:
+		for i, item := range val {
+			val[i] = normalize(item)
+		}
+
+		return val
+	default:
+		return val
+	}
+}
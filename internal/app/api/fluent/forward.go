@@ -0,0 +1,295 @@
+package fluent
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/vmihailenco/msgpack/v5"
+	"github.com/vmihailenco/msgpack/v5/msgpcode"
+
+	"github.com/loghole/collector/internal/app/domain"
+)
+
+const (
+	_eventTimeExtID  = 0
+	_eventTimeExtLen = 8
+	_entryLen        = 2
+	_compressedGzip  = "gzip"
+)
+
+var (
+	ErrInvalidMessage  = errors.New("invalid message")
+	ErrMessageTooLarge = errors.New("message is too large")
+)
+
+// Message is a decoded Forward protocol message.
+// https://github.com/fluent/fluentd/wiki/Forward-Protocol-Specification-v1
+type Message struct {
+	Tag    string
+	List   domain.EntryList
+	Option map[string]interface{}
+}
+
+// Chunk returns chunk id to be acknowledged, it is empty if ack is not requested.
+func (m *Message) Chunk() string {
+	chunk, _ := m.Option["chunk"].(string)
+
+	return chunk
+}
+
+// messageReader limits size of a single message read from the connection,
+// so a declared length of array, map or string can't make the decoder read more than the limit.
+type messageReader struct {
+	r         *bufio.Reader
+	remaining int
+}
+
+func newMessageReader(r io.Reader) *messageReader {
+	return &messageReader{r: bufio.NewReader(r)}
+}
+
+// reset sets the limit of the next message.
+func (r *messageReader) reset(limit int) {
+	r.remaining = limit
+}
+
+func (r *messageReader) Read(p []byte) (int, error) {
+	if r.remaining <= 0 {
+		return 0, ErrMessageTooLarge
+	}
+
+	if len(p) > r.remaining {
+		p = p[:r.remaining]
+	}
+
+	n, err := r.r.Read(p)
+	r.remaining -= n
+
+	return n, err
+}
+
+func (r *messageReader) ReadByte() (byte, error) {
+	if r.remaining <= 0 {
+		return 0, ErrMessageTooLarge
+	}
+
+	b, err := r.r.ReadByte()
+	if err == nil {
+		r.remaining--
+	}
+
+	return b, err
+}
+
+func (r *messageReader) UnreadByte() error {
+	err := r.r.UnreadByte()
+	if err == nil {
+		r.remaining++
+	}
+
+	return err
+}
+
+// decodeMessage reads message in the Message, Forward, PackedForward or CompressedPackedForward mode.
+// Message size limits size of decompressed entries, size of the message itself is limited by messageReader.
+func decodeMessage(dec *msgpack.Decoder, maxSize int) (*Message, error) {
+	size, err := dec.DecodeArrayLen()
+	if err != nil {
+		return nil, err
+	}
+
+	if size < 2 { //nolint:gomnd // tag and entries
+		return nil, fmt.Errorf("%w: array length %d", ErrInvalidMessage, size)
+	}
+
+	tag, err := dec.DecodeString()
+	if err != nil {
+		return nil, invalidMessage("tag", err)
+	}
+
+	code, err := dec.PeekCode()
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		message = &Message{Tag: tag}
+		fields  = 2 //nolint:gomnd // tag and entries
+		packed  []byte
+	)
+
+	switch {
+	case msgpcode.IsFixedArray(code) || code == msgpcode.Array16 || code == msgpcode.Array32:
+		err = message.decodeForward(dec)
+	case msgpcode.IsString(code) || msgpcode.IsBin(code):
+		packed, err = dec.DecodeBytes()
+	default:
+		fields = 3 //nolint:gomnd // tag, time and record
+		err = message.decodeEntry(dec)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if size > fields {
+		if message.Option, err = dec.DecodeMap(); err != nil {
+			return nil, invalidMessage("option", err)
+		}
+
+		for i := fields + 1; i < size; i++ {
+			if err := dec.Skip(); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if packed != nil {
+		if err := message.decodePackedForward(packed, maxSize); err != nil {
+			return nil, err
+		}
+	}
+
+	return message, nil
+}
+
+// decodeForward reads entries array: [[time, record], ...].
+// The list is not preallocated, the array length is not trusted before entries are read.
+func (m *Message) decodeForward(dec *msgpack.Decoder) error {
+	size, err := dec.DecodeArrayLen()
+	if err != nil {
+		return err
+	}
+
+	m.List = make(domain.EntryList, 0)
+
+	for i := 0; i < size; i++ {
+		if err := m.decodeEntryArray(dec); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// decodePackedForward reads concatenated [time, record] entries, gzip compressed if the option is set.
+func (m *Message) decodePackedForward(data []byte, maxSize int) error {
+	var reader io.Reader = bytes.NewReader(data)
+
+	if compressed, _ := m.Option["compressed"].(string); compressed == _compressedGzip {
+		gzipReader, err := gzip.NewReader(reader)
+		if err != nil {
+			return fmt.Errorf("decompress: %w", err)
+		}
+
+		defer gzipReader.Close()
+
+		if data, err = io.ReadAll(io.LimitReader(gzipReader, int64(maxSize)+1)); err != nil {
+			return fmt.Errorf("decompress: %w", err)
+		}
+
+		if len(data) > maxSize {
+			return ErrMessageTooLarge
+		}
+
+		reader = bytes.NewReader(data)
+	}
+
+	dec := msgpack.NewDecoder(reader)
+
+	m.List = make(domain.EntryList, 0)
+
+	for {
+		if err := m.decodeEntryArray(dec); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+
+			return err
+		}
+	}
+}
+
+func (m *Message) decodeEntryArray(dec *msgpack.Decoder) error {
+	size, err := dec.DecodeArrayLen()
+	if err != nil {
+		return err
+	}
+
+	if size != _entryLen {
+		return fmt.Errorf("%w: entry length %d", ErrInvalidMessage, size)
+	}
+
+	return m.decodeEntry(dec)
+}
+
+// decodeEntry reads time and record of the entry.
+func (m *Message) decodeEntry(dec *msgpack.Decoder) error {
+	eventTime, err := decodeTime(dec)
+	if err != nil {
+		return invalidMessage("time", err)
+	}
+
+	record, err := dec.DecodeMap()
+	if err != nil {
+		return invalidMessage("record", err)
+	}
+
+	entry, err := newEntry(m.Tag, eventTime, record)
+	if err != nil {
+		return err
+	}
+
+	m.List = append(m.List, entry)
+
+	return nil
+}
+
+// decodeTime reads time as integer seconds or as EventTime extension with nanoseconds.
+func decodeTime(dec *msgpack.Decoder) (time.Time, error) {
+	code, err := dec.PeekCode()
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if !msgpcode.IsExt(code) {
+		sec, err := dec.DecodeFloat64()
+		if err != nil {
+			return time.Time{}, err
+		}
+
+		return time.Unix(0, int64(sec*float64(time.Second))), nil
+	}
+
+	extID, extLen, err := dec.DecodeExtHeader()
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if extID != _eventTimeExtID || extLen != _eventTimeExtLen {
+		return time.Time{}, fmt.Errorf("unexpected ext id=%d len=%d", extID, extLen)
+	}
+
+	buf := make([]byte, _eventTimeExtLen)
+
+	if err := dec.ReadFull(buf); err != nil {
+		return time.Time{}, err
+	}
+
+	return time.Unix(int64(binary.BigEndian.Uint32(buf[:4])), int64(binary.BigEndian.Uint32(buf[4:]))), nil
+}
+
+// invalidMessage wraps decoding error of the message field, exceeded size limit is returned as is.
+func invalidMessage(field string, err error) error {
+	if errors.Is(err, ErrMessageTooLarge) {
+		return err
+	}
+
+	return fmt.Errorf("%w: %s: %v", ErrInvalidMessage, field, err)
+}
@@ -0,0 +1,218 @@
+package fluent
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vmihailenco/msgpack/v5"
+	"github.com/vmihailenco/msgpack/v5/msgpcode"
+)
+
+// eventTime is encoded as EventTime extension.
+type eventTime time.Time
+
+func (t eventTime) MarshalMsgpack() ([]byte, error) {
+	buf := []byte{msgpcode.FixExt8, _eventTimeExtID, 0, 0, 0, 0, 0, 0, 0, 0}
+
+	binary.BigEndian.PutUint32(buf[2:6], uint32(time.Time(t).Unix()))
+	binary.BigEndian.PutUint32(buf[6:], uint32(time.Time(t).Nanosecond()))
+
+	return buf, nil
+}
+
+func TestDecodeMessage(t *testing.T) {
+	var (
+		ts     = time.Date(2021, time.January, 10, 12, 0, 0, 123456789, time.UTC)
+		record = map[string]interface{}{"log": "Hello", "level": "info", "count": 3}
+		entry  = []interface{}{eventTime(ts), record}
+		packed = append(encode(t, entry), encode(t, []interface{}{ts.Unix(), record})...)
+	)
+
+	tests := []struct {
+		name           string
+		data           []byte
+		wantErr        bool
+		expectedTimes  []time.Time
+		expectedChunk  string
+		expectedSource string
+	}{
+		{
+			name:           "MessagePass",
+			data:           encode(t, []interface{}{"app.web", eventTime(ts), record}),
+			expectedTimes:  []time.Time{ts},
+			expectedSource: "app.web",
+		},
+		{
+			name:           "MessageOptionPass",
+			data:           encode(t, []interface{}{"app.web", ts.Unix(), record, map[string]interface{}{"chunk": "c1"}}),
+			expectedTimes:  []time.Time{ts.Truncate(time.Second)},
+			expectedChunk:  "c1",
+			expectedSource: "app.web",
+		},
+		{
+			name:           "ForwardPass",
+			data:           encode(t, []interface{}{"app.web", []interface{}{entry, entry}}),
+			expectedTimes:  []time.Time{ts, ts},
+			expectedSource: "app.web",
+		},
+		{
+			name:           "PackedForwardPass",
+			data:           encode(t, []interface{}{"app.web", packed, map[string]interface{}{"chunk": "c2"}}),
+			expectedTimes:  []time.Time{ts, ts.Truncate(time.Second)},
+			expectedChunk:  "c2",
+			expectedSource: "app.web",
+		},
+		{
+			name: "CompressedPackedForwardPass",
+			data: encode(t, []interface{}{
+				"app.web",
+				gzipData(t, packed),
+				map[string]interface{}{"compressed": "gzip", "size": 2},
+			}),
+			expectedTimes:  []time.Time{ts, ts.Truncate(time.Second)},
+			expectedSource: "app.web",
+		},
+		{
+			name:    "NoRecordError",
+			data:    encode(t, []interface{}{"app.web"}),
+			wantErr: true,
+		},
+		{
+			name:    "InvalidRecordError",
+			data:    encode(t, []interface{}{"app.web", ts.Unix(), "record"}),
+			wantErr: true,
+		},
+		{
+			name:    "HugeArrayLengthError",
+			data:    []byte{0x92, 0xa3, 't', 'a', 'g', msgpcode.Array32, 0xff, 0xff, 0xff, 0xff},
+			wantErr: true,
+		},
+		{
+			name:    "HugeMessageLengthError",
+			data:    []byte{msgpcode.Array32, 0xff, 0xff, 0xff, 0xff, 0xa3, 't', 'a', 'g', 0x90},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reader := newMessageReader(bytes.NewReader(tt.data))
+			reader.reset(1024)
+
+			message, err := decodeMessage(msgpack.NewDecoder(reader), 1024)
+			if (err != nil) != tt.wantErr {
+				t.Error(err)
+			}
+
+			if tt.wantErr {
+				return
+			}
+
+			assert.Equal(t, tt.expectedChunk, message.Chunk())
+
+			times := make([]time.Time, 0, len(message.List))
+
+			for _, entry := range message.List {
+				times = append(times, entry.Time.UTC())
+
+				assert.Equal(t, tt.expectedSource, entry.Source)
+				assert.Equal(t, "hello", entry.Message)
+				assert.Equal(t, "info", entry.Level)
+				assert.Equal(t, []string{"count"}, entry.FloatKey)
+			}
+
+			assert.Equal(t, tt.expectedTimes, times)
+		})
+	}
+}
+
+func TestDecodeMessage_MaxSize(t *testing.T) {
+	var (
+		record = map[string]interface{}{"log": strings.Repeat("a", 100)}
+		entry  = []interface{}{int64(1610280000), record}
+		packed = append(encode(t, entry), encode(t, entry)...)
+	)
+
+	tests := []struct {
+		name    string
+		data    []byte
+		maxSize int
+		wantErr error
+	}{
+		{
+			name:    "ForwardPass",
+			data:    encode(t, []interface{}{"app.web", []interface{}{entry, entry}}),
+			maxSize: 512,
+		},
+		{
+			name:    "MessageError",
+			data:    encode(t, []interface{}{"app.web", int64(1610280000), record}),
+			maxSize: 64,
+			wantErr: ErrMessageTooLarge,
+		},
+		{
+			name:    "ForwardError",
+			data:    encode(t, []interface{}{"app.web", []interface{}{entry, entry}}),
+			maxSize: 128,
+			wantErr: ErrMessageTooLarge,
+		},
+		{
+			name:    "PackedForwardError",
+			data:    encode(t, []interface{}{"app.web", packed}),
+			maxSize: 128,
+			wantErr: ErrMessageTooLarge,
+		},
+		{
+			name: "CompressedPackedForwardError",
+			data: encode(t, []interface{}{
+				"app.web",
+				gzipData(t, packed),
+				map[string]interface{}{"compressed": "gzip"},
+			}),
+			maxSize: 128,
+			wantErr: ErrMessageTooLarge,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reader := newMessageReader(bytes.NewReader(tt.data))
+			reader.reset(tt.maxSize)
+
+			_, err := decodeMessage(msgpack.NewDecoder(reader), tt.maxSize)
+			assert.ErrorIs(t, err, tt.wantErr)
+		})
+	}
+}
+
+func encode(t *testing.T, v interface{}) []byte {
+	t.Helper()
+
+	data, err := msgpack.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return data
+}
+
+func gzipData(t *testing.T, data []byte) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+
+	writer := gzip.NewWriter(&buf)
+
+	if _, err := writer.Write(data); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
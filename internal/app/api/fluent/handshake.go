@@ -0,0 +1,93 @@
+package fluent
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha512"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+const (
+	_nonceSize = 16
+	_pingLen   = 6
+)
+
+var ErrAuthFailed = errors.New("shared key mismatch")
+
+// handshake authenticates client by the shared key: server sends HELO with nonce, client answers
+// with PING containing digest of the shared key and server replies with PONG. User authentication
+// is not supported.
+func handshake(enc *msgpack.Encoder, dec *msgpack.Decoder, sharedKey, hostname string) error {
+	nonce := make([]byte, _nonceSize)
+
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+
+	helo := []interface{}{"HELO", map[string]interface{}{"nonce": nonce, "auth": "", "keepalive": true}}
+
+	if err := enc.Encode(helo); err != nil {
+		return err
+	}
+
+	size, err := dec.DecodeArrayLen()
+	if err != nil {
+		return err
+	}
+
+	if size != _pingLen {
+		return fmt.Errorf("%w: ping length %d", ErrInvalidMessage, size)
+	}
+
+	// PING, client hostname, shared key salt, shared key digest, username, password digest.
+	ping := make([]string, 0, size)
+
+	for i := 0; i < size; i++ {
+		val, err := dec.DecodeBytes()
+		if err != nil {
+			return fmt.Errorf("%w: ping: %v", ErrInvalidMessage, err)
+		}
+
+		ping = append(ping, string(val))
+	}
+
+	if ping[0] != "PING" {
+		return fmt.Errorf("%w: unexpected %q", ErrInvalidMessage, ping[0])
+	}
+
+	var (
+		salt   = ping[2]
+		valid  = hmac.Equal([]byte(ping[3]), []byte(keyDigest(salt, ping[1], nonce, sharedKey)))
+		reason string
+	)
+
+	if !valid {
+		reason = ErrAuthFailed.Error()
+	}
+
+	pong := []interface{}{"PONG", valid, reason, hostname, keyDigest(salt, hostname, nonce, sharedKey)}
+
+	if err := enc.Encode(pong); err != nil {
+		return err
+	}
+
+	if !valid {
+		return ErrAuthFailed
+	}
+
+	return nil
+}
+
+func keyDigest(salt, hostname string, nonce []byte, sharedKey string) string {
+	hash := sha512.New()
+	hash.Write([]byte(salt))
+	hash.Write([]byte(hostname))
+	hash.Write(nonce)
+	hash.Write([]byte(sharedKey))
+
+	return hex.EncodeToString(hash.Sum(nil))
+}
@@ -0,0 +1,127 @@
+package fluent
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"time"
+
+	"github.com/loghole/tracing/tracelog"
+	"github.com/vmihailenco/msgpack/v5"
+
+	"github.com/loghole/collector/internal/app/domain"
+	"github.com/loghole/collector/pkg/server"
+)
+
+type EntryService interface {
+	StoreEntryList(ctx context.Context, remoteIP string, list domain.EntryList) (err error)
+}
+
+// Config of forward listeners, listener is disabled if its address is empty.
+// Handshake is required if shared key is set.
+type Config struct {
+	TCPAddr     string
+	TLSAddr     string
+	TLSCertFile string
+	TLSKeyFile  string
+	SharedKey   string
+	Hostname    string
+	// MaxMessageSize limits size of a message in any mode and size of decompressed entries.
+	MaxMessageSize int
+	IdleTimeout    time.Duration
+}
+
+// Server receives messages of the Fluentd Forward protocol over TCP and TLS.
+type Server struct {
+	*server.Stream
+
+	config  *Config
+	service EntryService
+	logger  tracelog.Logger
+}
+
+func NewServer(config *Config, service EntryService, logger tracelog.Logger) *Server {
+	s := &Server{
+		config:  config,
+		service: service,
+		logger:  logger,
+	}
+
+	s.Stream = server.NewStream(&server.StreamConfig{
+		TCPAddr:     config.TCPAddr,
+		TLSAddr:     config.TLSAddr,
+		TLSCertFile: config.TLSCertFile,
+		TLSKeyFile:  config.TLSKeyFile,
+	}, s)
+
+	return s
+}
+
+// ServePacket ignores UDP packets, heartbeat is not supported.
+func (s *Server) ServePacket(addr net.Addr, data []byte) {}
+
+// ServeConn reads messages from the connection, chunk is acknowledged after entries are stored.
+func (s *Server) ServeConn(conn net.Conn) {
+	var (
+		ctx      = context.Background()
+		remoteIP = server.RemoteIP(conn.RemoteAddr())
+		reader   = newMessageReader(conn)
+		dec      = msgpack.NewDecoder(reader)
+		enc      = msgpack.NewEncoder(conn)
+	)
+
+	if s.config.SharedKey != "" {
+		s.setDeadline(conn)
+		reader.reset(s.config.MaxMessageSize)
+
+		if err := handshake(enc, dec, s.config.SharedKey, s.config.Hostname); err != nil {
+			s.logger.Errorf(ctx, "forward handshake with %s failed: %v", remoteIP, err)
+
+			return
+		}
+	}
+
+	for {
+		s.setDeadline(conn)
+		reader.reset(s.config.MaxMessageSize)
+
+		message, err := decodeMessage(dec, s.config.MaxMessageSize)
+		if err != nil {
+			if !s.isClosedConn(err) {
+				s.logger.Errorf(ctx, "read forward message from %s failed: %v", remoteIP, err)
+			}
+
+			return
+		}
+
+		if len(message.List) > 0 {
+			if err := s.service.StoreEntryList(ctx, remoteIP, message.List); err != nil {
+				s.logger.Errorf(ctx, "store entry list failed: %v", err)
+
+				// Chunk is not acknowledged, client will resend it.
+				continue
+			}
+		}
+
+		if chunk := message.Chunk(); chunk != "" {
+			if err := enc.Encode(map[string]string{"ack": chunk}); err != nil {
+				s.logger.Errorf(ctx, "write forward ack to %s failed: %v", remoteIP, err)
+
+				return
+			}
+		}
+	}
+}
+
+func (s *Server) setDeadline(conn net.Conn) {
+	if s.config.IdleTimeout > 0 {
+		_ = conn.SetReadDeadline(time.Now().Add(s.config.IdleTimeout))
+	}
+}
+
+func (s *Server) isClosedConn(err error) bool {
+	var netErr net.Error
+
+	return errors.Is(err, io.EOF) || s.IsClosed() || (errors.As(err, &netErr) && netErr.Timeout())
+}
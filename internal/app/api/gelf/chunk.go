@@ -0,0 +1,156 @@
+package gelf
+
+import (
+	"bytes"
+	"container/list"
+	"errors"
+	"sync"
+	"time"
+)
+
+const (
+	_chunkMagic0     = 0x1e
+	_chunkMagic1     = 0x0f
+	_chunkHeaderSize = 12
+	_maxChunks       = 128
+)
+
+var ErrInvalidChunk = errors.New("invalid chunk")
+
+type chunkedMessage struct {
+	id       string
+	chunks   [][]byte
+	received int
+	size     int
+	created  time.Time
+	elem     *list.Element
+}
+
+// assembler collects chunks of UDP messages. Incomplete messages are evicted after timeout,
+// the oldest of them are dropped if there are more than maxPending messages or maxPendingSize bytes.
+type assembler struct {
+	timeout        time.Duration
+	maxSize        int
+	maxPending     int
+	maxPendingSize int
+
+	mu        sync.Mutex
+	messages  map[string]*chunkedMessage
+	order     *list.List
+	size      int
+	lastEvict time.Time
+}
+
+// newAssembler returns chunk assembler, zero maxPending and maxPendingSize disable the limits.
+func newAssembler(timeout time.Duration, maxSize, maxPending, maxPendingSize int) *assembler {
+	return &assembler{
+		timeout:        timeout,
+		maxSize:        maxSize,
+		maxPending:     maxPending,
+		maxPendingSize: maxPendingSize,
+		messages:       make(map[string]*chunkedMessage),
+		order:          list.New(),
+	}
+}
+
+func isChunked(data []byte) bool {
+	return len(data) > 1 && data[0] == _chunkMagic0 && data[1] == _chunkMagic1
+}
+
+// add stores chunk, the complete message is returned after the last chunk is received.
+// Chunk format: magic (2 bytes), message id (8 bytes), sequence number (1 byte), sequence count (1 byte).
+func (a *assembler) add(data []byte, now time.Time) ([]byte, error) {
+	if len(data) < _chunkHeaderSize {
+		return nil, ErrInvalidChunk
+	}
+
+	var (
+		id     = string(data[2:10])
+		number = int(data[10])
+		count  = int(data[11])
+	)
+
+	if count == 0 || count > _maxChunks || number >= count {
+		return nil, ErrInvalidChunk
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.evict(now)
+
+	msg, ok := a.messages[id]
+	if ok && now.Sub(msg.created) >= a.timeout {
+		a.remove(msg)
+
+		ok = false
+	}
+
+	if !ok {
+		for a.maxPending > 0 && len(a.messages) >= a.maxPending {
+			a.remove(a.oldest())
+		}
+
+		msg = &chunkedMessage{id: id, chunks: make([][]byte, count), created: now}
+		msg.elem = a.order.PushBack(msg)
+		a.messages[id] = msg
+	}
+
+	if len(msg.chunks) != count {
+		a.remove(msg)
+
+		return nil, ErrInvalidChunk
+	}
+
+	if msg.chunks[number] != nil {
+		return nil, nil
+	}
+
+	// Packet buffer is reused by the listener.
+	msg.chunks[number] = append([]byte(nil), data[_chunkHeaderSize:]...)
+	msg.received++
+	msg.size += len(data) - _chunkHeaderSize
+	a.size += len(data) - _chunkHeaderSize
+
+	if msg.size > a.maxSize {
+		a.remove(msg)
+
+		return nil, ErrMessageTooLarge
+	}
+
+	if msg.received == count {
+		a.remove(msg)
+
+		return bytes.Join(msg.chunks, nil), nil
+	}
+
+	for a.maxPendingSize > 0 && a.size > a.maxPendingSize {
+		a.remove(a.oldest())
+	}
+
+	return nil, nil
+}
+
+func (a *assembler) oldest() *chunkedMessage {
+	return a.order.Front().Value.(*chunkedMessage) //nolint:forcetypeassert // list of messages
+}
+
+func (a *assembler) remove(msg *chunkedMessage) {
+	delete(a.messages, msg.id)
+	a.order.Remove(msg.elem)
+	a.size -= msg.size
+}
+
+// evict removes incomplete messages older than timeout, it runs at most once per timeout.
+func (a *assembler) evict(now time.Time) {
+	if now.Sub(a.lastEvict) < a.timeout {
+		return
+	}
+
+	a.lastEvict = now
+
+	// Messages are ordered by creation time.
+	for a.order.Len() > 0 && now.Sub(a.oldest().created) >= a.timeout {
+		a.remove(a.oldest())
+	}
+}
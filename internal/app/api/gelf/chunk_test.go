@@ -0,0 +1,106 @@
+package gelf
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func chunk(id byte, number, count byte, data string) []byte {
+	return append([]byte{_chunkMagic0, _chunkMagic1, id, 0, 0, 0, 0, 0, 0, 0, number, count}, data...)
+}
+
+func TestAssembler(t *testing.T) {
+	var (
+		now       = time.Now()
+		assembler = newAssembler(time.Second, 1024, 0, 0)
+	)
+
+	message, err := assembler.add(chunk(1, 1, 2, "world"), now)
+	assert.NoError(t, err)
+	assert.Nil(t, message)
+
+	// Duplicate chunk is ignored.
+	message, err = assembler.add(chunk(1, 1, 2, "world"), now)
+	assert.NoError(t, err)
+	assert.Nil(t, message)
+
+	message, err = assembler.add(chunk(1, 0, 2, "hello "), now)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("hello world"), message)
+
+	// Incomplete message is evicted after timeout.
+	message, err = assembler.add(chunk(2, 0, 2, "hello "), now)
+	assert.NoError(t, err)
+	assert.Nil(t, message)
+
+	message, err = assembler.add(chunk(2, 1, 2, "world"), now.Add(time.Second))
+	assert.NoError(t, err)
+	assert.Nil(t, message)
+	assert.Len(t, assembler.messages, 1)
+
+	_, err = assembler.add(chunk(3, 2, 2, "world"), now)
+	assert.ErrorIs(t, err, ErrInvalidChunk)
+}
+
+func TestAssembler_MaxPending(t *testing.T) {
+	tests := []struct {
+		name           string
+		maxPending     int
+		maxPendingSize int
+		chunks         [][]byte
+		expectedIDs    []byte
+		expectedSize   int
+	}{
+		{
+			name:         "MaxMessages",
+			maxPending:   2,
+			chunks:       [][]byte{chunk(1, 0, 2, "hello"), chunk(2, 0, 2, "hello"), chunk(3, 0, 2, "hello")},
+			expectedIDs:  []byte{2, 3},
+			expectedSize: 10,
+		},
+		{
+			name:           "MaxSize",
+			maxPendingSize: 12,
+			chunks:         [][]byte{chunk(1, 0, 2, "hello"), chunk(2, 0, 2, "hello"), chunk(3, 0, 2, "abc")},
+			expectedIDs:    []byte{2, 3},
+			expectedSize:   8,
+		},
+		{
+			name:           "MaxSizeOwnChunk",
+			maxPendingSize: 12,
+			chunks:         [][]byte{chunk(1, 0, 3, "hello"), chunk(2, 0, 2, "hello"), chunk(1, 1, 3, "abc")},
+			expectedIDs:    []byte{2},
+			expectedSize:   5,
+		},
+		{
+			name:         "NoLimits",
+			chunks:       [][]byte{chunk(1, 0, 2, "hello"), chunk(2, 0, 2, "hello"), chunk(3, 0, 2, "hello")},
+			expectedIDs:  []byte{1, 2, 3},
+			expectedSize: 15,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				now       = time.Now()
+				assembler = newAssembler(time.Second, 1024, tt.maxPending, tt.maxPendingSize)
+			)
+
+			for _, data := range tt.chunks {
+				_, _ = assembler.add(data, now)
+			}
+
+			ids := make([]byte, 0)
+
+			for elem := assembler.order.Front(); elem != nil; elem = elem.Next() {
+				ids = append(ids, elem.Value.(*chunkedMessage).id[0])
+			}
+
+			assert.Equal(t, tt.expectedIDs, ids)
+			assert.Len(t, assembler.messages, len(tt.expectedIDs))
+			assert.Equal(t, tt.expectedSize, assembler.size)
+		})
+	}
+}
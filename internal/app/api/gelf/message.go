@@ -0,0 +1,149 @@
+package gelf
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/loghole/collector/internal/app/domain"
+)
+
+const (
+	_gzipMagic0 = 0x1f
+	_gzipMagic1 = 0x8b
+	_zlibMagic  = 0x78
+	_zlibCheck  = 31
+)
+
+var (
+	ErrShortMessageRequired = errors.New("short_message field is required")
+	ErrMessageTooLarge      = errors.New("message is too large")
+)
+
+// Parse decodes GELF message, gzip and zlib compressed payloads are decompressed.
+// https://go2docs.graylog.org/current/getting_in_log_data/gelf.html
+func Parse(data []byte, maxSize int) (*domain.Entry, error) {
+	data, err := decompress(data, maxSize)
+	if err != nil {
+		return nil, err
+	}
+
+	fields, err := messageFields(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return domain.NewEntry(fields)
+}
+
+func decompress(data []byte, maxSize int) ([]byte, error) {
+	var (
+		reader io.ReadCloser
+		err    error
+	)
+
+	switch {
+	case len(data) > 1 && data[0] == _gzipMagic0 && data[1] == _gzipMagic1:
+		reader, err = gzip.NewReader(bytes.NewReader(data))
+	case len(data) > 1 && data[0] == _zlibMagic && (int(data[0])<<8|int(data[1]))%_zlibCheck == 0:
+		reader, err = zlib.NewReader(bytes.NewReader(data))
+	default:
+		return data, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("decompress: %w", err)
+	}
+
+	defer reader.Close()
+
+	result, err := io.ReadAll(io.LimitReader(reader, int64(maxSize)+1))
+	if err != nil {
+		return nil, fmt.Errorf("decompress: %w", err)
+	}
+
+	if len(result) > maxSize {
+		return nil, ErrMessageTooLarge
+	}
+
+	return result, nil
+}
+
+// messageFields maps GELF fields onto entry fields. Additional fields are stored without
+// the "_" prefix, they don't override standard fields.
+func messageFields(data []byte) (map[string]interface{}, error) {
+	var message map[string]interface{}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	if err := decoder.Decode(&message); err != nil {
+		return nil, err
+	}
+
+	shortMessage, ok := message["short_message"].(string)
+	if !ok || strings.TrimSpace(shortMessage) == "" {
+		return nil, ErrShortMessageRequired
+	}
+
+	fields := map[string]interface{}{"message": shortMessage}
+
+	for key, val := range message {
+		switch key {
+		case "version", "short_message":
+		case "host", "full_message", "facility", "file", "line":
+			fields[key] = val
+		case "timestamp":
+			ts, err := parseTimestamp(val)
+			if err != nil {
+				return nil, fmt.Errorf("parse timestamp: %w", err)
+			}
+
+			fields["time"] = ts
+		case "level":
+			if number, ok := val.(json.Number); ok {
+				if level, err := number.Int64(); err == nil {
+					fields["level"] = domain.SyslogLevel(int(level))
+				}
+			}
+		}
+	}
+
+	for key, val := range message {
+		if strings.HasPrefix(key, "_") && key != "_id" {
+			setDefault(fields, strings.TrimPrefix(key, "_"), val)
+		}
+	}
+
+	return fields, nil
+}
+
+// parseTimestamp parses seconds since epoch with optional decimal milliseconds.
+func parseTimestamp(val interface{}) (time.Time, error) {
+	number, ok := val.(json.Number)
+	if !ok {
+		return time.Time{}, fmt.Errorf("unexpected type %T", val)
+	}
+
+	seconds, err := number.Float64()
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	sec, frac := math.Modf(seconds)
+
+	return time.Unix(int64(sec), int64(frac*float64(time.Second))).Round(time.Microsecond), nil
+}
+
+func setDefault(fields map[string]interface{}, key string, val interface{}) {
+	if _, ok := fields[key]; !ok {
+		fields[key] = val
+	}
+}
@@ -0,0 +1,117 @@
+package gelf
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/loghole/collector/internal/app/domain"
+)
+
+func TestParse(t *testing.T) {
+	const message = `{"version":"1.1","host":"web-1","short_message":"Hello","full_message":"Hello\nworld",` +
+		`"timestamp":1385053862.3072,"level":3,"_namespace":"prod","_user_id":9001,"_host":"other","_id":"x"}`
+
+	expected := &domain.Entry{
+		Time:      time.Date(2013, time.November, 21, 17, 11, 2, 307200000, time.UTC),
+		Namespace: "prod",
+		Host:      "web-1",
+		Level:     "error",
+		Message:   "hello",
+		StringKey: []string{"full_message"},
+		StringVal: []string{"hello\\nworld"},
+		FloatKey:  []string{"user_id"},
+		FloatVal:  []float64{9001},
+	}
+
+	tests := []struct {
+		name        string
+		data        []byte
+		maxSize     int
+		wantErr     bool
+		expectedRes *domain.Entry
+	}{
+		{
+			name:        "PlainPass",
+			data:        []byte(message),
+			maxSize:     1024,
+			expectedRes: expected,
+		},
+		{
+			name:        "GzipPass",
+			data:        compress(t, "gzip", message),
+			maxSize:     1024,
+			expectedRes: expected,
+		},
+		{
+			name:        "ZlibPass",
+			data:        compress(t, "zlib", message),
+			maxSize:     1024,
+			expectedRes: expected,
+		},
+		{
+			name:    "TooLargeError",
+			data:    compress(t, "gzip", message),
+			maxSize: 10,
+			wantErr: true,
+		},
+		{
+			name:    "ShortMessageError",
+			data:    []byte(`{"version":"1.1","host":"web-1","full_message":"Hello"}`),
+			maxSize: 1024,
+			wantErr: true,
+		},
+		{
+			name:    "InvalidJSONError",
+			data:    []byte(`{"short_message":`),
+			maxSize: 1024,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entry, err := Parse(tt.data, tt.maxSize)
+			if (err != nil) != tt.wantErr {
+				t.Error(err)
+			}
+
+			if !tt.wantErr {
+				entry.Params = nil
+				entry.Time = entry.Time.UTC()
+
+				assert.Equal(t, tt.expectedRes, entry)
+			}
+		})
+	}
+}
+
+func compress(t *testing.T, encoding, data string) []byte {
+	t.Helper()
+
+	var (
+		buf    bytes.Buffer
+		writer io.WriteCloser
+	)
+
+	switch encoding {
+	case "gzip":
+		writer = gzip.NewWriter(&buf)
+	case "zlib":
+		writer = zlib.NewWriter(&buf)
+	}
+
+	if _, err := writer.Write([]byte(data)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
@@ -0,0 +1,142 @@
+package gelf
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"time"
+
+	"github.com/loghole/tracing/tracelog"
+
+	"github.com/loghole/collector/internal/app/domain"
+	"github.com/loghole/collector/pkg/server"
+)
+
+type EntryService interface {
+	StoreEntryList(ctx context.Context, remoteIP string, list domain.EntryList) (err error)
+}
+
+// Config of GELF listeners, listener is disabled if its address is empty.
+type Config struct {
+	UDPAddr        string
+	TCPAddr        string
+	MaxMessageSize int
+	ChunkTimeout   time.Duration
+	// ChunkMaxMessages and ChunkMaxSize limit incomplete chunked messages, zero disables the limit.
+	ChunkMaxMessages int
+	ChunkMaxSize     int
+	IdleTimeout      time.Duration
+}
+
+// Server receives GELF messages over UDP and TCP.
+type Server struct {
+	*server.Stream
+
+	config    *Config
+	service   EntryService
+	logger    tracelog.Logger
+	assembler *assembler
+}
+
+func NewServer(config *Config, service EntryService, logger tracelog.Logger) *Server {
+	s := &Server{
+		config:  config,
+		service: service,
+		logger:  logger,
+		assembler: newAssembler(
+			config.ChunkTimeout,
+			config.MaxMessageSize,
+			config.ChunkMaxMessages,
+			config.ChunkMaxSize,
+		),
+	}
+
+	s.Stream = server.NewStream(&server.StreamConfig{
+		UDPAddr: config.UDPAddr,
+		TCPAddr: config.TCPAddr,
+	}, s)
+
+	return s
+}
+
+// ServePacket handles UDP message, chunked messages are handled after the last chunk is received.
+func (s *Server) ServePacket(addr net.Addr, data []byte) {
+	remoteIP := server.RemoteIP(addr)
+
+	if isChunked(data) {
+		message, err := s.assembler.add(data, time.Now())
+		if err != nil {
+			s.logger.Errorf(context.Background(), "add gelf chunk from %s failed: %v", remoteIP, err)
+
+			return
+		}
+
+		if message == nil {
+			return
+		}
+
+		data = message
+	}
+
+	s.handle(remoteIP, data)
+}
+
+// ServeConn reads null byte delimited messages from TCP stream.
+func (s *Server) ServeConn(conn net.Conn) {
+	var (
+		remoteIP = server.RemoteIP(conn.RemoteAddr())
+		scanner  = bufio.NewScanner(conn)
+	)
+
+	scanner.Buffer(make([]byte, 0, bufio.MaxScanTokenSize), s.config.MaxMessageSize+1)
+	scanner.Split(splitNull)
+
+	for {
+		if s.config.IdleTimeout > 0 {
+			_ = conn.SetReadDeadline(time.Now().Add(s.config.IdleTimeout))
+		}
+
+		if !scanner.Scan() {
+			break
+		}
+
+		if data := bytes.TrimSpace(scanner.Bytes()); len(data) > 0 {
+			s.handle(remoteIP, data)
+		}
+	}
+
+	var netErr net.Error
+
+	if err := scanner.Err(); err != nil && !s.IsClosed() && !(errors.As(err, &netErr) && netErr.Timeout()) {
+		s.logger.Errorf(context.Background(), "read gelf stream from %s failed: %v", remoteIP, err)
+	}
+}
+
+func (s *Server) handle(remoteIP string, data []byte) {
+	ctx := context.Background()
+
+	entry, err := Parse(data, s.config.MaxMessageSize)
+	if err != nil {
+		s.logger.Errorf(ctx, "parse gelf message from %s failed: %v", remoteIP, err)
+
+		return
+	}
+
+	if err := s.service.StoreEntryList(ctx, remoteIP, domain.EntryList{entry}); err != nil {
+		s.logger.Errorf(ctx, "store entry list failed: %v", err)
+	}
+}
+
+func splitNull(data []byte, atEOF bool) (int, []byte, error) {
+	if idx := bytes.IndexByte(data, 0); idx >= 0 {
+		return idx + 1, data[:idx], nil
+	}
+
+	if atEOF && len(data) > 0 {
+		return len(data), data, nil
+	}
+
+	return 0, nil, nil
+}
@@ -0,0 +1,267 @@
+// Package kafka consumes entries from Kafka topics with a consumer group. Offsets are committed
+// after the entries were written to the storage, so entries are not lost if the collector fails.
+package kafka
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/loghole/tracing/tracelog"
+	kafkago "github.com/segmentio/kafka-go"
+
+	"github.com/loghole/collector/internal/app/domain"
+)
+
+const _maxFetchBytes = 10 << 20
+
+type EntryService interface {
+	StoreEntryListSync(ctx context.Context, remoteIP string, list domain.EntryList) error
+}
+
+// Config of the consumer, it is disabled if brokers or topics are empty.
+type Config struct {
+	Brokers []string
+	Topics  []string
+	GroupID string
+	// BatchSize and BatchTimeout limit messages stored at once.
+	BatchSize    int
+	BatchTimeout time.Duration
+	// RetryInterval between attempts to store messages after a failure.
+	RetryInterval time.Duration
+}
+
+// MessageReader fetches and commits messages of the consumer group, see kafkago.Reader.
+type MessageReader interface {
+	FetchMessage(ctx context.Context) (kafkago.Message, error)
+	CommitMessages(ctx context.Context, messages ...kafkago.Message) error
+	Close() error
+}
+
+// Consumer stores messages with json objects or arrays of entries like the store api and messages
+// published by the kafka storage. Invalid messages are skipped.
+type Consumer struct {
+	config  *Config
+	reader  MessageReader
+	service EntryService
+	logger  tracelog.Logger
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// mu guards starting of Serve after Shutdown.
+	mu      sync.Mutex
+	closed  bool
+	running sync.WaitGroup
+}
+
+func NewConsumer(config *Config, service EntryService, logger tracelog.Logger) *Consumer {
+	var reader MessageReader
+
+	if enabled(config) {
+		reader = kafkago.NewReader(kafkago.ReaderConfig{
+			Brokers:     config.Brokers,
+			GroupID:     config.GroupID,
+			GroupTopics: config.Topics,
+			MaxBytes:    _maxFetchBytes,
+		})
+	}
+
+	return newConsumer(config, reader, service, logger)
+}
+
+func newConsumer(config *Config, reader MessageReader, service EntryService, logger tracelog.Logger) *Consumer {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &Consumer{
+		config:  config,
+		reader:  reader,
+		service: service,
+		logger:  logger,
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+}
+
+// Addr returns brokers and topics of the consumer, it is empty if the consumer is disabled.
+func (c *Consumer) Addr() string {
+	if !enabled(c.config) {
+		return ""
+	}
+
+	return fmt.Sprintf("kafka://%s/%s", strings.Join(c.config.Brokers, ","), strings.Join(c.config.Topics, ","))
+}
+
+// Serve stores fetched messages and commits them until Shutdown. Messages that could not be stored
+// are retried unless their entries were rejected by the storage or moved to the dead letter storage,
+// messages fetched but not committed before shutdown are consumed again after restart.
+func (c *Consumer) Serve() error {
+	c.mu.Lock()
+
+	if c.closed {
+		c.mu.Unlock()
+
+		return nil
+	}
+
+	c.running.Add(1)
+	defer c.running.Done()
+
+	c.mu.Unlock()
+
+	for {
+		messages, err := c.fetch(c.ctx)
+		if err != nil {
+			if c.ctx.Err() != nil || errors.Is(err, io.EOF) {
+				return nil
+			}
+
+			return fmt.Errorf("fetch messages: %w", err)
+		}
+
+		if err := c.store(c.ctx, messages); err != nil {
+			return nil
+		}
+
+		if err := c.reader.CommitMessages(c.ctx, messages...); err != nil && c.ctx.Err() == nil {
+			c.logger.Errorf(c.ctx, "commit %d kafka messages failed: %v", len(messages), err)
+		}
+	}
+}
+
+// Shutdown stops fetching messages, waits for the stored batch and closes the reader.
+func (c *Consumer) Shutdown() error {
+	c.mu.Lock()
+	c.closed = true
+	c.cancel()
+	c.mu.Unlock()
+
+	c.running.Wait()
+
+	if c.reader == nil {
+		return nil
+	}
+
+	return c.reader.Close()
+}
+
+// fetch waits for a message and fetches more messages until the batch size or the batch timeout.
+func (c *Consumer) fetch(ctx context.Context) ([]kafkago.Message, error) {
+	message, err := c.reader.FetchMessage(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	messages := []kafkago.Message{message}
+
+	ctx, cancel := context.WithTimeout(ctx, c.config.BatchTimeout)
+	defer cancel()
+
+	for len(messages) < c.config.BatchSize {
+		message, err := c.reader.FetchMessage(ctx)
+		if err != nil {
+			break
+		}
+
+		messages = append(messages, message)
+	}
+
+	return messages, nil
+}
+
+// store writes entries of the messages retrying transient failures, it fails only on shutdown.
+func (c *Consumer) store(ctx context.Context, messages []kafkago.Message) error {
+	for {
+		list := c.decode(ctx, messages)
+		if len(list) == 0 {
+			return nil
+		}
+
+		err := c.service.StoreEntryListSync(ctx, "", list)
+		if err == nil {
+			return nil
+		}
+
+		if handled(err) {
+			c.logger.Warnf(ctx, "skip %d kafka messages: %v", len(messages), err)
+
+			return nil
+		}
+
+		c.logger.Errorf(ctx, "store %d kafka messages failed: %v", len(messages), err)
+
+		timer := time.NewTimer(c.config.RetryInterval)
+
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+
+			return ctx.Err()
+		}
+	}
+}
+
+func (c *Consumer) decode(ctx context.Context, messages []kafkago.Message) domain.EntryList {
+	list := make(domain.EntryList, 0, len(messages))
+
+	for _, message := range messages {
+		entries, err := decodeMessage(message)
+		if err != nil {
+			c.logger.Warnf(ctx, "skip kafka message %s/%d/%d: %v",
+				message.Topic, message.Partition, message.Offset, err)
+
+			continue
+		}
+
+		list = append(list, entries...)
+	}
+
+	return list
+}
+
+// decodeMessage returns entries of the json object, array or record published by the kafka storage.
+func decodeMessage(message kafkago.Message) (domain.EntryList, error) {
+	for _, header := range message.Headers {
+		if header.Key == domain.RecordFormatHeader && string(header.Value) == domain.RecordFormat {
+			entry, err := domain.UnmarshalRecord(message.Value)
+			if err != nil {
+				return nil, err
+			}
+
+			return domain.EntryList{entry}, nil
+		}
+	}
+
+	if data := bytes.TrimSpace(message.Value); len(data) > 0 && data[0] == '[' {
+		list := domain.EntryList{}
+
+		if err := list.UnmarshalJSON(data); err != nil {
+			return nil, err
+		}
+
+		return list, nil
+	}
+
+	entry := &domain.Entry{}
+
+	if err := entry.UnmarshalJSON(message.Value); err != nil {
+		return nil, err
+	}
+
+	return domain.EntryList{entry}, nil
+}
+
+// handled reports whether entries that failed must not be stored again.
+func handled(err error) bool {
+	return errors.Is(err, domain.ErrEntryRejected) || errors.Is(err, domain.ErrDeadLettered)
+}
+
+func enabled(config *Config) bool {
+	return len(config.Brokers) > 0 && len(config.Topics) > 0
+}
@@ -0,0 +1,264 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/loghole/tracing/tracelog"
+	kafkago "github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+
+	"github.com/loghole/collector/internal/app/domain"
+	"github.com/loghole/collector/internal/app/repositories/kafkasink"
+)
+
+// brokerMock is an in-process broker of one topic partition, it is written by the kafka storage
+// and read by the consumer group of one member.
+type brokerMock struct {
+	mu        sync.Mutex
+	messages  []kafkago.Message
+	fetched   int
+	committed int64
+	notify    chan struct{}
+}
+
+func newBrokerMock() *brokerMock {
+	return &brokerMock{notify: make(chan struct{}, 1), committed: -1}
+}
+
+func (b *brokerMock) WriteMessages(ctx context.Context, messages ...kafkago.Message) error {
+	b.mu.Lock()
+
+	for _, message := range messages {
+		message.Topic, message.Offset = "logs", int64(len(b.messages))
+		b.messages = append(b.messages, message)
+	}
+
+	b.mu.Unlock()
+
+	select {
+	case b.notify <- struct{}{}:
+	default:
+	}
+
+	return nil
+}
+
+func (b *brokerMock) FetchMessage(ctx context.Context) (kafkago.Message, error) {
+	for {
+		b.mu.Lock()
+
+		if b.fetched < len(b.messages) {
+			message := b.messages[b.fetched]
+			b.fetched++
+			b.mu.Unlock()
+
+			return message, nil
+		}
+
+		b.mu.Unlock()
+
+		select {
+		case <-b.notify:
+		case <-ctx.Done():
+			return kafkago.Message{}, ctx.Err()
+		}
+	}
+}
+
+func (b *brokerMock) CommitMessages(ctx context.Context, messages ...kafkago.Message) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, message := range messages {
+		if message.Offset > b.committed {
+			b.committed = message.Offset
+		}
+	}
+
+	return nil
+}
+
+func (b *brokerMock) Close() error { return nil }
+
+func (b *brokerMock) committedOffset() int64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.committed
+}
+
+type serviceMock struct {
+	mu      sync.Mutex
+	err     error
+	reject  string
+	calls   int
+	entries domain.EntryList
+}
+
+// StoreEntryListSync stores entries except the rejected one, which fails the list permanently.
+func (s *serviceMock) StoreEntryListSync(ctx context.Context, remoteIP string, list domain.EntryList) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.calls++
+
+	if s.err != nil {
+		return s.err
+	}
+
+	var err error
+
+	for _, entry := range list {
+		if entry.Message == s.reject {
+			err = fmt.Errorf("store failed: %w", domain.ErrEntryRejected)
+
+			continue
+		}
+
+		s.entries = append(s.entries, entry)
+	}
+
+	return err
+}
+
+func (s *serviceMock) messages() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	messages := make([]string, 0, len(s.entries))
+
+	for _, entry := range s.entries {
+		messages = append(messages, entry.Message)
+	}
+
+	return messages
+}
+
+func (s *serviceMock) setErr(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.err = err
+}
+
+func (s *serviceMock) callCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.calls
+}
+
+func TestConsumer_Serve(t *testing.T) {
+	var (
+		broker  = newBrokerMock()
+		service = &serviceMock{err: errors.New("writer queue is full")}
+		sink    = kafkasink.NewSink(nil, broker)
+	)
+
+	consumer := newConsumer(&Config{
+		Brokers:       []string{"kafka:9092"},
+		Topics:        []string{"logs"},
+		BatchSize:     10,
+		BatchTimeout:  10 * time.Millisecond,
+		RetryInterval: 10 * time.Millisecond,
+	}, broker, service, tracelog.NewTraceLogger(zap.NewNop().Sugar()))
+
+	assert.Equal(t, "kafka://kafka:9092/logs", consumer.Addr())
+
+	done := make(chan error, 1)
+
+	go func() { done <- consumer.Serve() }()
+
+	// Entries published by the kafka storage, objects and arrays produced by other clients.
+	err := sink.InsertEntryList(context.Background(), []*domain.Entry{{Namespace: "app", Message: "record"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = broker.WriteMessages(context.Background(),
+		kafkago.Message{Value: []byte(`{"namespace":"app","message":"object"}`)},
+		kafkago.Message{Value: []byte(`not json`)},
+		kafkago.Message{Value: []byte(` [{"message":"first"},{"message":"second"}]`)},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Messages are not committed while they can't be stored.
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, int64(-1), broker.committedOffset())
+
+	service.setErr(nil)
+
+	assert.Eventually(t, func() bool { return broker.committedOffset() == 3 }, time.Second, 10*time.Millisecond)
+	assert.Equal(t, []string{"record", "object", "first", "second"}, service.messages())
+
+	assert.NoError(t, consumer.Shutdown())
+	assert.NoError(t, <-done)
+}
+
+func TestConsumer_Serve_Handled(t *testing.T) {
+	tests := []struct {
+		name             string
+		service          *serviceMock
+		expectedMessages []string
+	}{
+		{
+			name:             "RejectedRowPass",
+			service:          &serviceMock{reject: "invalid"},
+			expectedMessages: []string{"first", "second"},
+		},
+		{
+			name:             "DeadLetteredPass",
+			service:          &serviceMock{err: fmt.Errorf("%w: failed.ndjson", domain.ErrDeadLettered)},
+			expectedMessages: []string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			broker := newBrokerMock()
+
+			consumer := newConsumer(&Config{
+				Brokers:       []string{"kafka:9092"},
+				Topics:        []string{"logs"},
+				BatchSize:     10,
+				BatchTimeout:  10 * time.Millisecond,
+				RetryInterval: 10 * time.Millisecond,
+			}, broker, tt.service, tracelog.NewTraceLogger(zap.NewNop().Sugar()))
+
+			done := make(chan error, 1)
+
+			go func() { done <- consumer.Serve() }()
+
+			err := broker.WriteMessages(context.Background(),
+				kafkago.Message{Value: []byte(`{"message":"first"}`)},
+				kafkago.Message{Value: []byte(`{"message":"invalid"}`)},
+				kafkago.Message{Value: []byte(`{"message":"second"}`)},
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// Failed entries are not retried and don't hold the partition.
+			assert.Eventually(t, func() bool { return broker.committedOffset() == 2 }, time.Second, 10*time.Millisecond)
+			assert.Equal(t, 1, tt.service.callCount())
+			assert.Equal(t, tt.expectedMessages, tt.service.messages())
+
+			assert.NoError(t, consumer.Shutdown())
+			assert.NoError(t, <-done)
+		})
+	}
+}
+
+func TestConsumer_Addr(t *testing.T) {
+	consumer := NewConsumer(&Config{Topics: []string{"logs"}}, &serviceMock{}, nil)
+
+	assert.Empty(t, consumer.Addr())
+	assert.NoError(t, consumer.Shutdown())
+}
@@ -0,0 +1,103 @@
+package v1
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+
+	"github.com/golang/snappy"
+	"github.com/loghole/tracing"
+	"github.com/loghole/tracing/tracelog"
+
+	"github.com/loghole/collector/internal/app/domain"
+)
+
+type EntryService interface {
+	StoreEntryList(ctx context.Context, remoteIP string, list domain.EntryList) (err error)
+}
+
+type LokiHandler struct {
+	service EntryService
+	logger  tracelog.Logger
+	tracer  *tracing.Tracer
+}
+
+func NewLokiHandler(
+	service EntryService,
+	logger tracelog.Logger,
+	tracer *tracing.Tracer,
+) *LokiHandler {
+	return &LokiHandler{
+		service: service,
+		logger:  logger,
+		tracer:  tracer,
+	}
+}
+
+// PushHandler receives streams in the Loki push api format: snappy compressed protobuf
+// or json for the application/json content type.
+func (h *LokiHandler) PushHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	streams, err := h.readStreams(r)
+	if err != nil {
+		h.logger.Errorf(ctx, "read streams failed: %v", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+
+		return
+	}
+
+	list, err := EntryList(streams)
+	if err != nil {
+		h.logger.Errorf(ctx, "convert streams failed: %v", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+
+		return
+	}
+
+	if err := h.service.StoreEntryList(ctx, r.RemoteAddr, list); err != nil {
+		h.logger.Errorf(ctx, "store entry list failed: %v", err)
+
+		if retryAfter, ok := domain.RetryAfter(err); ok {
+			w.Header().Set("Retry-After", retryAfter)
+			http.Error(w, "writer queue is full", http.StatusServiceUnavailable)
+
+			return
+		}
+
+		if errors.Is(err, domain.ErrListTooLarge) {
+			http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+
+			return
+		}
+
+		http.Error(w, "store failed", http.StatusInternalServerError)
+
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *LokiHandler) readStreams(r *http.Request) ([]Stream, error) {
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, fmt.Errorf("read body: %w", err)
+	}
+
+	contentType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+
+	if contentType == "application/json" {
+		return decodeJSON(data)
+	}
+
+	data, err = snappy.Decode(nil, data)
+	if err != nil {
+		return nil, fmt.Errorf("snappy decode: %w", err)
+	}
+
+	return decodeProto(data)
+}
@@ -0,0 +1,81 @@
+package v1
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/loghole/tracing/tracelog"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+
+	"github.com/loghole/collector/internal/app/domain"
+)
+
+type serviceMock struct {
+	err  error
+	list domain.EntryList
+}
+
+func (s *serviceMock) StoreEntryList(ctx context.Context, remoteIP string, list domain.EntryList) error {
+	s.list = list
+
+	return s.err
+}
+
+func TestLokiHandler_PushHandler(t *testing.T) {
+	const body = `{"streams":[{"stream":{"job":"app"},"values":[["1666085400000000000","first"]]}]}`
+
+	tests := []struct {
+		name               string
+		body               string
+		storeErr           error
+		expectedStatus     int
+		expectedRetryAfter string
+	}{
+		{
+			name:           "Pass",
+			body:           body,
+			expectedStatus: http.StatusNoContent,
+		},
+		{
+			name:               "QueueFullError",
+			body:               body,
+			storeErr:           &domain.QueueFullError{RetryAfter: 2500 * time.Millisecond},
+			expectedStatus:     http.StatusServiceUnavailable,
+			expectedRetryAfter: "3",
+		},
+		{
+			name:           "StoreError",
+			body:           body,
+			storeErr:       errors.New("insert failed"),
+			expectedStatus: http.StatusInternalServerError,
+		},
+		{
+			name:           "InvalidBodyError",
+			body:           `{"streams":`,
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				service = &serviceMock{err: tt.storeErr}
+				handler = NewLokiHandler(service, tracelog.NewTraceLogger(zap.NewNop().Sugar()), nil)
+				rec     = httptest.NewRecorder()
+				req     = httptest.NewRequest(http.MethodPost, "/loki/api/v1/push", strings.NewReader(tt.body))
+			)
+
+			req.Header.Set("Content-Type", "application/json")
+
+			handler.PushHandler(rec, req)
+
+			assert.Equal(t, tt.expectedStatus, rec.Code)
+			assert.Equal(t, tt.expectedRetryAfter, rec.Header().Get("Retry-After"))
+		})
+	}
+}
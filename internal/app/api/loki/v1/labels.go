@@ -0,0 +1,65 @@
+package v1
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var ErrInvalidLabels = errors.New("invalid labels")
+
+// parseLabels parses labels in the prometheus format: {job="app", host="web-1"}.
+func parseLabels(str string) (map[string]string, error) {
+	str = strings.TrimSpace(str)
+
+	if !strings.HasPrefix(str, "{") || !strings.HasSuffix(str, "}") {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidLabels, str)
+	}
+
+	var (
+		labels = make(map[string]string)
+		rest   = strings.TrimSpace(str[1 : len(str)-1])
+	)
+
+	for rest != "" {
+		idx := strings.IndexByte(rest, '=')
+		if idx <= 0 {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidLabels, str)
+		}
+
+		name := strings.TrimSpace(rest[:idx])
+
+		value, tail, err := consumeQuoted(strings.TrimSpace(rest[idx+1:]))
+		if err != nil {
+			return nil, fmt.Errorf("%w: %q: %v", ErrInvalidLabels, str, err)
+		}
+
+		labels[name] = value
+
+		rest = strings.TrimPrefix(strings.TrimSpace(tail), ",")
+		rest = strings.TrimSpace(rest)
+	}
+
+	return labels, nil
+}
+
+// consumeQuoted returns unquoted value of the leading quoted string and the rest of str.
+func consumeQuoted(str string) (value, rest string, err error) {
+	if !strings.HasPrefix(str, `"`) {
+		return "", "", strconv.ErrSyntax
+	}
+
+	for i := 1; i < len(str); i++ {
+		switch str[i] {
+		case '\\':
+			i++
+		case '"':
+			value, err = strconv.Unquote(str[:i+1])
+
+			return value, str[i+1:], err
+		}
+	}
+
+	return "", "", strconv.ErrSyntax
+}
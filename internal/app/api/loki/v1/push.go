@@ -0,0 +1,296 @@
+package v1
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"github.com/loghole/collector/internal/app/domain"
+)
+
+var ErrInvalidValue = errors.New("invalid stream value")
+
+// Stream is a set of log lines with the same labels.
+type Stream struct {
+	Labels  map[string]string
+	Entries []StreamEntry
+}
+
+type StreamEntry struct {
+	Time     time.Time
+	Line     string
+	Metadata map[string]string
+}
+
+// EntryList converts streams to entries. Well-known labels are moved to the entry fields,
+// others are stored as params.
+func EntryList(streams []Stream) (domain.EntryList, error) {
+	list := make(domain.EntryList, 0)
+
+	for _, stream := range streams {
+		for _, item := range stream.Entries {
+			fields := make(map[string]interface{}, len(stream.Labels)+len(item.Metadata)+2) //nolint:gomnd // time and message
+
+			for key, val := range stream.Labels {
+				fields[key] = val
+			}
+
+			for key, val := range item.Metadata {
+				fields[key] = val
+			}
+
+			if job, ok := fields["job"]; ok {
+				if _, ok := fields["source"]; !ok {
+					fields["source"] = job
+				}
+			}
+
+			fields["time"] = item.Time
+			fields["message"] = item.Line
+
+			entry, err := domain.NewEntry(fields)
+			if err != nil {
+				return nil, err
+			}
+
+			list = append(list, entry)
+		}
+	}
+
+	return list, nil
+}
+
+// pushRequestJSON is the json form of the push request:
+// {"streams": [{"stream": {"label": "value"}, "values": [["<unix epoch in ns>", "<line>", {"key": "value"}]]}]}.
+type pushRequestJSON struct {
+	Streams []struct {
+		Stream map[string]string   `json:"stream"`
+		Values [][]json.RawMessage `json:"values"`
+	} `json:"streams"`
+}
+
+func decodeJSON(data []byte) ([]Stream, error) {
+	var req pushRequestJSON
+
+	if err := json.Unmarshal(data, &req); err != nil {
+		return nil, err
+	}
+
+	streams := make([]Stream, 0, len(req.Streams))
+
+	for _, item := range req.Streams {
+		stream := Stream{Labels: item.Stream, Entries: make([]StreamEntry, 0, len(item.Values))}
+
+		for _, value := range item.Values {
+			entry, err := decodeJSONValue(value)
+			if err != nil {
+				return nil, err
+			}
+
+			stream.Entries = append(stream.Entries, entry)
+		}
+
+		streams = append(streams, stream)
+	}
+
+	return streams, nil
+}
+
+func decodeJSONValue(value []json.RawMessage) (entry StreamEntry, err error) {
+	const (
+		minLen  = 2
+		metaIdx = 2
+	)
+
+	if len(value) < minLen {
+		return entry, fmt.Errorf("%w: expected timestamp and line", ErrInvalidValue)
+	}
+
+	var timestamp string
+
+	if err := json.Unmarshal(value[0], &timestamp); err != nil {
+		return entry, fmt.Errorf("%w: timestamp: %v", ErrInvalidValue, err)
+	}
+
+	nsec, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return entry, fmt.Errorf("%w: timestamp: %v", ErrInvalidValue, err)
+	}
+
+	entry.Time = time.Unix(0, nsec)
+
+	if err := json.Unmarshal(value[1], &entry.Line); err != nil {
+		return entry, fmt.Errorf("%w: line: %v", ErrInvalidValue, err)
+	}
+
+	if len(value) > metaIdx {
+		if err := json.Unmarshal(value[metaIdx], &entry.Metadata); err != nil {
+			return entry, fmt.Errorf("%w: metadata: %v", ErrInvalidValue, err)
+		}
+	}
+
+	return entry, nil
+}
+
+// decodeProto decodes protobuf PushRequest message.
+//
+//	message PushRequest { repeated StreamAdapter streams = 1; }
+func decodeProto(data []byte) ([]Stream, error) {
+	streams := make([]Stream, 0)
+
+	err := eachField(data, func(num protowire.Number, value []byte, _ uint64) error {
+		if num != 1 || value == nil {
+			return nil
+		}
+
+		stream, err := decodeStream(value)
+		if err != nil {
+			return fmt.Errorf("stream: %w", err)
+		}
+
+		streams = append(streams, stream)
+
+		return nil
+	})
+
+	return streams, err
+}
+
+// decodeStream decodes StreamAdapter message.
+//
+//	message StreamAdapter { string labels = 1; repeated EntryAdapter entries = 2; uint64 hash = 3; }
+func decodeStream(data []byte) (stream Stream, err error) {
+	err = eachField(data, func(num protowire.Number, value []byte, _ uint64) (err error) {
+		switch num {
+		case 1:
+			stream.Labels, err = parseLabels(string(value))
+		case 2: //nolint:gomnd // field number
+			var entry StreamEntry
+
+			if entry, err = decodeEntry(value); err == nil {
+				stream.Entries = append(stream.Entries, entry)
+			}
+		}
+
+		return err
+	})
+
+	return stream, err
+}
+
+// decodeEntry decodes EntryAdapter message.
+//
+//	message EntryAdapter {
+//	  google.protobuf.Timestamp timestamp = 1;
+//	  string line = 2;
+//	  repeated LabelPairAdapter structuredMetadata = 3;
+//	}
+func decodeEntry(data []byte) (entry StreamEntry, err error) {
+	err = eachField(data, func(num protowire.Number, value []byte, _ uint64) (err error) {
+		switch num {
+		case 1:
+			entry.Time, err = decodeTimestamp(value)
+		case 2: //nolint:gomnd // field number
+			entry.Line = string(value)
+		case 3: //nolint:gomnd // field number
+			var name, val string
+
+			if name, val, err = decodeLabelPair(value); err == nil {
+				if entry.Metadata == nil {
+					entry.Metadata = make(map[string]string)
+				}
+
+				entry.Metadata[name] = val
+			}
+		}
+
+		return err
+	})
+
+	return entry, err
+}
+
+// decodeLabelPair decodes LabelPairAdapter message.
+//
+//	message LabelPairAdapter { string name = 1; string value = 2; }
+func decodeLabelPair(data []byte) (name, value string, err error) {
+	err = eachField(data, func(num protowire.Number, val []byte, _ uint64) error {
+		switch num {
+		case 1:
+			name = string(val)
+		case 2: //nolint:gomnd // field number
+			value = string(val)
+		}
+
+		return nil
+	})
+
+	return name, value, err
+}
+
+// decodeTimestamp decodes google.protobuf.Timestamp message.
+//
+//	message Timestamp { int64 seconds = 1; int32 nanos = 2; }
+func decodeTimestamp(data []byte) (time.Time, error) {
+	var sec, nsec int64
+
+	err := eachField(data, func(num protowire.Number, _ []byte, varint uint64) error {
+		switch num {
+		case 1:
+			sec = int64(varint)
+		case 2: //nolint:gomnd // field number
+			nsec = int64(int32(varint))
+		}
+
+		return nil
+	})
+
+	return time.Unix(sec, nsec), err
+}
+
+// eachField calls fn for every length-delimited and varint field of the message, other fields are skipped.
+func eachField(data []byte, fn func(num protowire.Number, value []byte, varint uint64) error) error {
+	for len(data) > 0 {
+		num, typ, n := protowire.ConsumeTag(data)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+
+		data = data[n:]
+
+		var err error
+
+		switch typ {
+		case protowire.BytesType:
+			var value []byte
+
+			if value, n = protowire.ConsumeBytes(data); n >= 0 {
+				err = fn(num, value, 0)
+			}
+		case protowire.VarintType:
+			var value uint64
+
+			if value, n = protowire.ConsumeVarint(data); n >= 0 {
+				err = fn(num, nil, value)
+			}
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, data)
+		}
+
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+
+		if err != nil {
+			return err
+		}
+
+		data = data[n:]
+	}
+
+	return nil
+}
@@ -0,0 +1,157 @@
+package v1
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+func TestParseLabels(t *testing.T) {
+	tests := []struct {
+		name        string
+		data        string
+		wantErr     bool
+		expectedRes map[string]string
+	}{
+		{
+			name:        "EmptyPass",
+			data:        `{}`,
+			expectedRes: map[string]string{},
+		},
+		{
+			name:        "LabelsPass",
+			data:        `{job="app", host="web-1",namespace = "prod"}`,
+			expectedRes: map[string]string{"job": "app", "host": "web-1", "namespace": "prod"},
+		},
+		{
+			name:        "EscapedPass",
+			data:        `{msg="a \"b\", c=\\d"}`,
+			expectedRes: map[string]string{"msg": `a "b", c=\d`},
+		},
+		{
+			name:    "NoBracesError",
+			data:    `job="app"`,
+			wantErr: true,
+		},
+		{
+			name:    "NotQuotedError",
+			data:    `{job=app}`,
+			wantErr: true,
+		},
+		{
+			name:    "UnterminatedError",
+			data:    `{job="app}`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			labels, err := parseLabels(tt.data)
+			if (err != nil) != tt.wantErr {
+				t.Error(err)
+			}
+
+			if !tt.wantErr {
+				assert.Equal(t, tt.expectedRes, labels)
+			}
+		})
+	}
+}
+
+func TestDecode(t *testing.T) {
+	var (
+		timestamp = time.Unix(1595768727, 429286952)
+		expected  = []Stream{{
+			Labels: map[string]string{"job": "app", "level": "info"},
+			Entries: []StreamEntry{
+				{Time: timestamp, Line: "first line"},
+				{Time: timestamp, Line: "second line", Metadata: map[string]string{"trace_id": "abc"}},
+			},
+		}}
+	)
+
+	t.Run("ProtoPass", func(t *testing.T) {
+		var ts, first, second, pair, stream, req []byte
+
+		ts = protowire.AppendTag(ts, 1, protowire.VarintType)
+		ts = protowire.AppendVarint(ts, uint64(timestamp.Unix()))
+		ts = protowire.AppendTag(ts, 2, protowire.VarintType)
+		ts = protowire.AppendVarint(ts, uint64(timestamp.Nanosecond()))
+
+		first = protowire.AppendTag(first, 1, protowire.BytesType)
+		first = protowire.AppendBytes(first, ts)
+		first = protowire.AppendTag(first, 2, protowire.BytesType)
+		first = protowire.AppendString(first, "first line")
+
+		pair = protowire.AppendTag(pair, 1, protowire.BytesType)
+		pair = protowire.AppendString(pair, "trace_id")
+		pair = protowire.AppendTag(pair, 2, protowire.BytesType)
+		pair = protowire.AppendString(pair, "abc")
+
+		second = protowire.AppendTag(second, 1, protowire.BytesType)
+		second = protowire.AppendBytes(second, ts)
+		second = protowire.AppendTag(second, 2, protowire.BytesType)
+		second = protowire.AppendString(second, "second line")
+		second = protowire.AppendTag(second, 3, protowire.BytesType)
+		second = protowire.AppendBytes(second, pair)
+
+		stream = protowire.AppendTag(stream, 1, protowire.BytesType)
+		stream = protowire.AppendString(stream, `{job="app", level="info"}`)
+		stream = protowire.AppendTag(stream, 2, protowire.BytesType)
+		stream = protowire.AppendBytes(stream, first)
+		stream = protowire.AppendTag(stream, 2, protowire.BytesType)
+		stream = protowire.AppendBytes(stream, second)
+		stream = protowire.AppendTag(stream, 3, protowire.VarintType)
+		stream = protowire.AppendVarint(stream, 42)
+
+		req = protowire.AppendTag(req, 1, protowire.BytesType)
+		req = protowire.AppendBytes(req, stream)
+
+		streams, err := decodeProto(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, expected, streams)
+	})
+
+	t.Run("JSONPass", func(t *testing.T) {
+		streams, err := decodeJSON([]byte(`{"streams": [{"stream": {"job": "app", "level": "info"}, "values": [
+			["1595768727429286952", "first line"],
+			["1595768727429286952", "second line", {"trace_id": "abc"}]
+		]}]}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, expected, streams)
+	})
+
+	t.Run("JSONError", func(t *testing.T) {
+		_, err := decodeJSON([]byte(`{"streams": [{"stream": {}, "values": [["not a number", "line"]]}]}`))
+		assert.ErrorIs(t, err, ErrInvalidValue)
+	})
+}
+
+func TestEntryList(t *testing.T) {
+	list, err := EntryList([]Stream{{
+		Labels:  map[string]string{"job": "app", "host": "web-1", "namespace": "prod", "level": "error", "region": "eu"},
+		Entries: []StreamEntry{{Time: time.Unix(1595768727, 0), Line: "some message"}},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if assert.Len(t, list, 1) {
+		assert.True(t, time.Unix(1595768727, 0).Equal(list[0].Time))
+		assert.Equal(t, "app", list[0].Source)
+		assert.Equal(t, "web-1", list[0].Host)
+		assert.Equal(t, "prod", list[0].Namespace)
+		assert.Equal(t, "error", list[0].Level)
+		assert.Equal(t, "some message", list[0].Message)
+		assert.Equal(t, []string{"job", "region"}, list[0].StringKey)
+		assert.Equal(t, []string{"app", "eu"}, list[0].StringVal)
+	}
+}
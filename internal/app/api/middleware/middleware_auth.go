@@ -1,13 +1,20 @@
 package middleware
 
 import (
+	"context"
 	"net/http"
 	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
 )
 
 const (
-	_tokenParts          = 2
-	_authorizationHeader = "Authorization"
+	_tokenParts            = 2
+	_authorizationHeader   = "Authorization"
+	_authorizationMetadata = "authorization"
 )
 
 type AuthMiddleware struct {
@@ -40,23 +47,7 @@ func (m *AuthMiddleware) Middleware(next http.Handler) http.Handler {
 			return
 		}
 
-		auth := strings.TrimSpace(r.Header.Get(_authorizationHeader))
-
-		if auth == "" {
-			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
-
-			return
-		}
-
-		parts := strings.Split(auth, " ")
-
-		if len(parts) < _tokenParts {
-			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
-
-			return
-		}
-
-		if _, ok := m.tokens[parts[1]]; !ok {
+		if !m.validToken(r.Header.Get(_authorizationHeader)) {
 			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
 
 			return
@@ -69,3 +60,42 @@ func (m *AuthMiddleware) Middleware(next http.Handler) http.Handler {
 		next.ServeHTTP(w, r)
 	})
 }
+
+// UnaryServerInterceptor checks token from the authorization metadata of grpc requests.
+func (m *AuthMiddleware) UnaryServerInterceptor(
+	ctx context.Context,
+	req interface{},
+	_ *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler,
+) (interface{}, error) {
+	if !m.enabled {
+		return handler(ctx, req)
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	if values := md.Get(_authorizationMetadata); len(values) == 0 || !m.validToken(values[0]) {
+		return nil, status.Error(codes.Unauthenticated, "unauthenticated")
+	}
+
+	return handler(ctx, req)
+}
+
+// validToken checks authorization value in the "<type> <token>" format.
+func (m *AuthMiddleware) validToken(auth string) bool {
+	auth = strings.TrimSpace(auth)
+
+	if auth == "" {
+		return false
+	}
+
+	parts := strings.Split(auth, " ")
+
+	if len(parts) < _tokenParts {
+		return false
+	}
+
+	_, ok := m.tokens[parts[1]]
+
+	return ok
+}
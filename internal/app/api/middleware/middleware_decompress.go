@@ -0,0 +1,257 @@
+package middleware
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/golang/snappy"
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	_contentEncodingHeader = "Content-Encoding"
+	_contentLengthHeader   = "Content-Length"
+	_acceptEncodingHeader  = "Accept-Encoding"
+	_supportedEncodings    = "gzip, deflate, zstd, snappy"
+
+	_zlibMagic = 0x78
+	_zlibCheck = 31
+)
+
+var (
+	ErrBodyTooLarge        = errors.New("request body too large")
+	ErrUnsupportedEncoding = errors.New("unsupported content encoding")
+)
+
+// DecompressMiddleware decodes request body by the Content-Encoding header. Decoded body is limited
+// by max size, response is replaced with 413 status if the handler read more than the limit
+// and didn't report it with 413 status itself.
+type DecompressMiddleware struct {
+	maxSize int64
+}
+
+func NewDecompressMiddleware(maxSize int64) *DecompressMiddleware {
+	return &DecompressMiddleware{maxSize: maxSize}
+}
+
+func (m *DecompressMiddleware) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		encodings := contentEncodings(r.Header.Get(_contentEncodingHeader))
+
+		if len(encodings) == 0 {
+			next.ServeHTTP(w, r)
+
+			return
+		}
+
+		body := &decodedBody{body: r.Body, remaining: m.maxSize}
+
+		if err := body.decode(encodings, m.maxSize); err != nil {
+			body.Close()
+
+			if errors.Is(err, ErrUnsupportedEncoding) {
+				w.Header().Set(_acceptEncodingHeader, _supportedEncodings)
+				http.Error(w, err.Error(), http.StatusUnsupportedMediaType)
+
+				return
+			}
+
+			if errors.Is(err, ErrBodyTooLarge) {
+				http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+
+				return
+			}
+
+			http.Error(w, err.Error(), http.StatusBadRequest)
+
+			return
+		}
+
+		writer := &decodedBodyWriter{ResponseWriter: w, body: body}
+
+		r.Body = body
+		r.ContentLength = -1
+		r.Header.Del(_contentEncodingHeader)
+		r.Header.Del(_contentLengthHeader)
+
+		next.ServeHTTP(writer, r)
+
+		if body.exceeded && !writer.wroteHeader {
+			writer.WriteHeader(http.StatusOK)
+		}
+	})
+}
+
+// contentEncodings returns encodings in the order they were applied, identity is skipped.
+func contentEncodings(header string) []string {
+	encodings := make([]string, 0)
+
+	for _, encoding := range strings.Split(header, ",") {
+		encoding = strings.ToLower(strings.TrimSpace(encoding))
+
+		if encoding != "" && encoding != "identity" {
+			encodings = append(encodings, encoding)
+		}
+	}
+
+	return encodings
+}
+
+// decodedBody reads request body through decoders, ErrBodyTooLarge is returned after max size is read.
+type decodedBody struct {
+	body      io.ReadCloser
+	reader    io.Reader
+	closers   []func()
+	remaining int64
+	exceeded  bool
+}
+
+// decode wraps body with decoders in the reverse order of the applied encodings.
+func (b *decodedBody) decode(encodings []string, maxSize int64) (err error) {
+	b.reader = b.body
+
+	for i := len(encodings) - 1; i >= 0; i-- {
+		switch encodings[i] {
+		case "gzip", "x-gzip":
+			b.reader, err = gzip.NewReader(b.reader)
+		case "deflate":
+			b.reader, err = newDeflateReader(b.reader)
+		case "zstd":
+			err = b.decodeZstd(maxSize)
+		case "snappy":
+			b.reader, err = newSnappyReader(b.reader, maxSize)
+		default:
+			return fmt.Errorf("%w: %s", ErrUnsupportedEncoding, encodings[i])
+		}
+
+		if err != nil {
+			return fmt.Errorf("decode %s body: %w", encodings[i], err)
+		}
+	}
+
+	return nil
+}
+
+func (b *decodedBody) decodeZstd(maxSize int64) error {
+	decoder, err := zstd.NewReader(b.reader, zstd.WithDecoderConcurrency(1), zstd.WithDecoderMaxMemory(uint64(maxSize)))
+	if err != nil {
+		return err
+	}
+
+	b.reader = decoder
+	b.closers = append(b.closers, decoder.Close)
+
+	return nil
+}
+
+func (b *decodedBody) Read(p []byte) (int, error) {
+	if b.exceeded {
+		return 0, ErrBodyTooLarge
+	}
+
+	// One byte over the limit is read to tell the end of the body from the exceeded limit.
+	if int64(len(p)) > b.remaining+1 {
+		p = p[:b.remaining+1]
+	}
+
+	n, err := b.reader.Read(p)
+
+	if b.remaining -= int64(n); b.remaining < 0 {
+		b.exceeded = true
+
+		return n - 1, ErrBodyTooLarge
+	}
+
+	return n, err
+}
+
+func (b *decodedBody) Close() error {
+	for _, closer := range b.closers {
+		closer()
+	}
+
+	return b.body.Close()
+}
+
+// decodedBodyWriter replaces response with 413 status if the body exceeded the limit,
+// response of the handler that has already set 413 status is kept.
+type decodedBodyWriter struct {
+	http.ResponseWriter
+	body        *decodedBody
+	wroteHeader bool
+	rejected    bool
+}
+
+func (w *decodedBodyWriter) WriteHeader(status int) {
+	if w.wroteHeader {
+		return
+	}
+
+	w.wroteHeader = true
+
+	if w.body.exceeded && status != http.StatusRequestEntityTooLarge {
+		w.rejected = true
+
+		http.Error(w.ResponseWriter, ErrBodyTooLarge.Error(), http.StatusRequestEntityTooLarge)
+
+		return
+	}
+
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *decodedBodyWriter) Write(data []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	if w.rejected {
+		return len(data), nil
+	}
+
+	return w.ResponseWriter.Write(data)
+}
+
+// newDeflateReader reads zlib stream, raw deflate stream is accepted as well because some clients send it.
+func newDeflateReader(r io.Reader) (io.Reader, error) {
+	reader := bufio.NewReader(r)
+
+	header, err := reader.Peek(2) //nolint:gomnd // zlib header size
+	if err == nil && header[0] == _zlibMagic && (int(header[0])<<8|int(header[1]))%_zlibCheck == 0 {
+		return zlib.NewReader(reader)
+	}
+
+	return flate.NewReader(reader), nil
+}
+
+// newSnappyReader decodes snappy block format, the format is not streamed so the whole block is read.
+func newSnappyReader(r io.Reader, maxSize int64) (io.Reader, error) {
+	data, err := io.ReadAll(io.LimitReader(r, maxSize+1))
+	if err != nil {
+		return nil, err
+	}
+
+	size, err := snappy.DecodedLen(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if int64(len(data)) > maxSize || int64(size) > maxSize {
+		return nil, ErrBodyTooLarge
+	}
+
+	data, err = snappy.Decode(nil, data)
+	if err != nil {
+		return nil, err
+	}
+
+	return bytes.NewReader(data), nil
+}
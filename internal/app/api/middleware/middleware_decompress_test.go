@@ -0,0 +1,187 @@
+package middleware
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/golang/snappy"
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecompressMiddleware(t *testing.T) {
+	const body = `[{"message":"hello"},{"message":"world"}]`
+
+	tests := []struct {
+		name           string
+		encoding       string
+		data           []byte
+		maxSize        int64
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:           "IdentityPass",
+			data:           []byte(body),
+			maxSize:        10,
+			expectedStatus: http.StatusOK,
+			expectedBody:   body,
+		},
+		{
+			name:           "GzipPass",
+			encoding:       "gzip",
+			data:           compress(t, "gzip", body),
+			maxSize:        1024,
+			expectedStatus: http.StatusOK,
+			expectedBody:   body,
+		},
+		{
+			name:           "DeflatePass",
+			encoding:       "deflate",
+			data:           compress(t, "deflate", body),
+			maxSize:        1024,
+			expectedStatus: http.StatusOK,
+			expectedBody:   body,
+		},
+		{
+			name:           "ZstdPass",
+			encoding:       "zstd",
+			data:           compress(t, "zstd", body),
+			maxSize:        1024,
+			expectedStatus: http.StatusOK,
+			expectedBody:   body,
+		},
+		{
+			name:           "SnappyPass",
+			encoding:       "snappy",
+			data:           snappy.Encode(nil, []byte(body)),
+			maxSize:        1024,
+			expectedStatus: http.StatusOK,
+			expectedBody:   body,
+		},
+		{
+			name:           "ExactSizePass",
+			encoding:       "gzip",
+			data:           compress(t, "gzip", body),
+			maxSize:        int64(len(body)),
+			expectedStatus: http.StatusOK,
+			expectedBody:   body,
+		},
+		{
+			name:           "TooLargeError",
+			encoding:       "gzip",
+			data:           compress(t, "gzip", body),
+			maxSize:        int64(len(body)) - 1,
+			expectedStatus: http.StatusRequestEntityTooLarge,
+			expectedBody:   "request body too large\n",
+		},
+		{
+			name:           "SnappyTooLargeError",
+			encoding:       "snappy",
+			data:           snappy.Encode(nil, []byte(body)),
+			maxSize:        10,
+			expectedStatus: http.StatusRequestEntityTooLarge,
+			expectedBody:   "decode snappy body: request body too large\n",
+		},
+		{
+			name:           "InvalidBodyError",
+			encoding:       "gzip",
+			data:           []byte(body),
+			maxSize:        1024,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   "decode gzip body: gzip: invalid header\n",
+		},
+		{
+			name:           "UnsupportedEncodingError",
+			encoding:       "br",
+			data:           []byte(body),
+			maxSize:        1024,
+			expectedStatus: http.StatusUnsupportedMediaType,
+			expectedBody:   "unsupported content encoding: br\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewDecompressMiddleware(tt.maxSize).Middleware(http.HandlerFunc(echoHandler))
+
+			req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(tt.data))
+			req.Header.Set("Content-Encoding", tt.encoding)
+
+			resp := httptest.NewRecorder()
+
+			handler.ServeHTTP(resp, req)
+
+			assert.Equal(t, tt.expectedStatus, resp.Code)
+			assert.Equal(t, tt.expectedBody, resp.Body.String())
+		})
+	}
+}
+
+func TestDecompressMiddleware_HandlerTooLarge(t *testing.T) {
+	handler := NewDecompressMiddleware(10).Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := io.ReadAll(r.Body); err != nil {
+			// Handler reports the part of the body it has processed.
+			http.Error(w, "stored 1 entry", http.StatusRequestEntityTooLarge)
+		}
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(compress(t, "gzip", strings.Repeat("a", 20))))
+	req.Header.Set("Content-Encoding", "gzip")
+
+	resp := httptest.NewRecorder()
+
+	handler.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusRequestEntityTooLarge, resp.Code)
+	assert.Equal(t, "stored 1 entry\n", resp.Body.String())
+}
+
+func echoHandler(w http.ResponseWriter, r *http.Request) {
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "read failed", http.StatusInternalServerError)
+
+		return
+	}
+
+	_, _ = w.Write(data)
+}
+
+func compress(t *testing.T, encoding, data string) []byte {
+	t.Helper()
+
+	var (
+		buf    bytes.Buffer
+		writer io.WriteCloser
+		err    error
+	)
+
+	switch encoding {
+	case "gzip":
+		writer = gzip.NewWriter(&buf)
+	case "deflate":
+		writer = zlib.NewWriter(&buf)
+	case "zstd":
+		writer, err = zstd.NewWriter(&buf)
+	}
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := io.Copy(writer, strings.NewReader(data)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
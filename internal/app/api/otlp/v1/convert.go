@@ -0,0 +1,206 @@
+package v1
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"math"
+	"strings"
+	"time"
+
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	logspb "go.opentelemetry.io/proto/otlp/logs/v1"
+
+	"github.com/loghole/collector/internal/app/domain"
+)
+
+const (
+	_traceIDSize = 16
+	_spanIDSize  = 8
+)
+
+// Resource attributes mapped onto the entry fields.
+const (
+	_attrServiceName      = "service.name"
+	_attrServiceNamespace = "service.namespace"
+	_attrHostName         = "host.name"
+)
+
+// EntryList converts logs export request to entries. Resource and log record attributes are stored
+// as params, numeric attributes as float params.
+func EntryList(req *collogspb.ExportLogsServiceRequest) (domain.EntryList, error) {
+	list := make(domain.EntryList, 0)
+
+	for _, resourceLogs := range req.GetResourceLogs() {
+		resource := attributes(resourceLogs.GetResource().GetAttributes())
+
+		for _, scopeLogs := range resourceLogs.GetScopeLogs() {
+			for _, record := range scopeLogs.GetLogRecords() {
+				entry, err := newEntry(resource, scopeLogs.GetScope(), record)
+				if err != nil {
+					return nil, err
+				}
+
+				list = append(list, entry)
+			}
+		}
+	}
+
+	return list, nil
+}
+
+func newEntry(
+	resource map[string]interface{},
+	scope *commonpb.InstrumentationScope,
+	record *logspb.LogRecord,
+) (*domain.Entry, error) {
+	fields := make(map[string]interface{}, len(resource)+len(record.GetAttributes()))
+
+	for key, val := range resource {
+		fields[key] = val
+	}
+
+	for key, val := range attributes(record.GetAttributes()) {
+		fields[key] = val
+	}
+
+	if name := scope.GetName(); name != "" {
+		fields["otel.scope.name"] = name
+	}
+
+	switch body := anyValue(record.GetBody()).(type) {
+	case nil:
+	case map[string]interface{}:
+		for key, val := range body {
+			fields[key] = val
+		}
+	case string:
+		fields["message"] = body
+	default:
+		fields["body"] = body
+	}
+
+	setDefault(fields, "source", resource[_attrServiceName])
+	setDefault(fields, "namespace", resource[_attrServiceNamespace])
+	setDefault(fields, "host", resource[_attrHostName])
+	setDefault(fields, "level", severity(record))
+
+	if id := record.GetTraceId(); len(id) > 0 {
+		fields["trace_id"] = hex.EncodeToString(id)
+	}
+
+	if id := record.GetSpanId(); len(id) > 0 {
+		fields["span_id"] = hex.EncodeToString(id)
+	}
+
+	switch {
+	case record.GetTimeUnixNano() > 0:
+		fields["time"] = time.Unix(0, int64(record.GetTimeUnixNano()))
+	case record.GetObservedTimeUnixNano() > 0:
+		fields["time"] = time.Unix(0, int64(record.GetObservedTimeUnixNano()))
+	}
+
+	return domain.NewEntry(fields)
+}
+
+func attributes(list []*commonpb.KeyValue) map[string]interface{} {
+	result := make(map[string]interface{}, len(list))
+
+	for _, attr := range list {
+		result[attr.GetKey()] = anyValue(attr.GetValue())
+	}
+
+	return result
+}
+
+// anyValue converts value to the json compatible type.
+func anyValue(value *commonpb.AnyValue) interface{} {
+	switch value := value.GetValue().(type) {
+	case *commonpb.AnyValue_StringValue:
+		return value.StringValue
+	case *commonpb.AnyValue_BoolValue:
+		return value.BoolValue
+	case *commonpb.AnyValue_IntValue:
+		return value.IntValue
+	case *commonpb.AnyValue_DoubleValue:
+		if math.IsNaN(value.DoubleValue) || math.IsInf(value.DoubleValue, 0) {
+			return nil
+		}
+
+		return value.DoubleValue
+	case *commonpb.AnyValue_BytesValue:
+		return base64.StdEncoding.EncodeToString(value.BytesValue)
+	case *commonpb.AnyValue_ArrayValue:
+		result := make([]interface{}, 0, len(value.ArrayValue.GetValues()))
+
+		for _, item := range value.ArrayValue.GetValues() {
+			result = append(result, anyValue(item))
+		}
+
+		return result
+	case *commonpb.AnyValue_KvlistValue:
+		return attributes(value.KvlistValue.GetValues())
+	default:
+		return nil
+	}
+}
+
+// severity returns level from the severity text or the severity number.
+func severity(record *logspb.LogRecord) string {
+	if text := record.GetSeverityText(); text != "" {
+		return strings.ToLower(text)
+	}
+
+	switch number := record.GetSeverityNumber(); {
+	case number >= logspb.SeverityNumber_SEVERITY_NUMBER_FATAL:
+		return "fatal"
+	case number >= logspb.SeverityNumber_SEVERITY_NUMBER_ERROR:
+		return "error"
+	case number >= logspb.SeverityNumber_SEVERITY_NUMBER_WARN:
+		return "warn"
+	case number >= logspb.SeverityNumber_SEVERITY_NUMBER_INFO:
+		return "info"
+	case number >= logspb.SeverityNumber_SEVERITY_NUMBER_DEBUG:
+		return "debug"
+	case number >= logspb.SeverityNumber_SEVERITY_NUMBER_TRACE:
+		return "trace"
+	default:
+		return ""
+	}
+}
+
+// fixJSONIDs restores trace and span ids after protojson decoding. OTLP/JSON encodes ids as hex strings,
+// protojson decodes them as base64, so the original string is encoded back and decoded as hex.
+func fixJSONIDs(req *collogspb.ExportLogsServiceRequest) {
+	for _, resourceLogs := range req.GetResourceLogs() {
+		for _, scopeLogs := range resourceLogs.GetScopeLogs() {
+			for _, record := range scopeLogs.GetLogRecords() {
+				record.TraceId = fixJSONID(record.GetTraceId(), _traceIDSize)
+				record.SpanId = fixJSONID(record.GetSpanId(), _spanIDSize)
+			}
+		}
+	}
+}
+
+func fixJSONID(id []byte, size int) []byte {
+	if len(id) == 0 || len(id) == size {
+		return id
+	}
+
+	result, err := hex.DecodeString(base64.StdEncoding.EncodeToString(id))
+	if err != nil {
+		return nil
+	}
+
+	return result
+}
+
+func setDefault(fields map[string]interface{}, key string, val interface{}) {
+	if str, ok := val.(string); !ok || str == "" {
+		return
+	}
+
+	if _, ok := fields[key]; !ok {
+		fields[key] = val
+	}
+}
@@ -0,0 +1,89 @@
+package v1
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEntryList(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		data        string
+		wantErr     bool
+		expectedRes map[string]interface{}
+	}{
+		{
+			name:        "JSONPass",
+			contentType: _contentTypeJSON,
+			data: `{"resourceLogs": [{
+				"resource": {"attributes": [
+					{"key": "service.name", "value": {"stringValue": "api"}},
+					{"key": "service.namespace", "value": {"stringValue": "prod"}},
+					{"key": "host.name", "value": {"stringValue": "web-1"}}
+				]},
+				"scopeLogs": [{"logRecords": [{
+					"timeUnixNano": "1595768727429286952",
+					"severityNumber": 17,
+					"body": {"stringValue": "Request failed"},
+					"traceId": "5b8efff798038103d269b633813fc60c",
+					"spanId": "eee19b7ec3c1b174",
+					"attributes": [{"key": "http.status_code", "value": {"intValue": "500"}}]
+				}]}]
+			}]}`,
+			expectedRes: map[string]interface{}{
+				"time":      time.Unix(0, 1595768727429286952),
+				"namespace": "prod",
+				"source":    "api",
+				"host":      "web-1",
+				"level":     "error",
+				"message":   "request failed",
+				"trace_id":  "5b8efff798038103d269b633813fc60c",
+				"floatKey":  []string{"http.status_code"},
+				"floatVal":  []float64{500},
+			},
+		},
+		{
+			name:        "ProtobufError",
+			contentType: _contentTypeProtobuf,
+			data:        "\x0a\x05abc",
+			wantErr:     true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := readRequest(strings.NewReader(tt.data), tt.contentType)
+			if (err != nil) != tt.wantErr {
+				t.Error(err)
+			}
+
+			if tt.wantErr {
+				return
+			}
+
+			list, err := EntryList(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !assert.Len(t, list, 1) {
+				return
+			}
+
+			entry := list[0]
+
+			assert.True(t, tt.expectedRes["time"].(time.Time).Equal(entry.Time))
+			assert.Equal(t, tt.expectedRes["namespace"], entry.Namespace)
+			assert.Equal(t, tt.expectedRes["source"], entry.Source)
+			assert.Equal(t, tt.expectedRes["host"], entry.Host)
+			assert.Equal(t, tt.expectedRes["level"], entry.Level)
+			assert.Equal(t, tt.expectedRes["message"], entry.Message)
+			assert.Equal(t, tt.expectedRes["trace_id"], entry.TraceID)
+			assert.Equal(t, tt.expectedRes["floatKey"], entry.FloatKey)
+			assert.Equal(t, tt.expectedRes["floatVal"], entry.FloatVal)
+		})
+	}
+}
@@ -0,0 +1,141 @@
+package v1
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+
+	"github.com/loghole/tracing"
+	"github.com/loghole/tracing/tracelog"
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/loghole/collector/internal/app/domain"
+)
+
+const (
+	_contentTypeJSON     = "application/json"
+	_contentTypeProtobuf = "application/x-protobuf"
+)
+
+type EntryService interface {
+	StoreEntryList(ctx context.Context, remoteIP string, list domain.EntryList) (err error)
+}
+
+type LogsHandler struct {
+	service EntryService
+	logger  tracelog.Logger
+	tracer  *tracing.Tracer
+}
+
+func NewLogsHandler(
+	service EntryService,
+	logger tracelog.Logger,
+	tracer *tracing.Tracer,
+) *LogsHandler {
+	return &LogsHandler{
+		service: service,
+		logger:  logger,
+		tracer:  tracer,
+	}
+}
+
+// ExportHandler receives OTLP/HTTP logs export request in protobuf or json encoding.
+func (h *LogsHandler) ExportHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	contentType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+
+	req, err := readRequest(r.Body, contentType)
+	if err != nil {
+		h.logger.Errorf(ctx, "read request failed: %v", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+
+		return
+	}
+
+	list, err := EntryList(req)
+	if err != nil {
+		h.logger.Errorf(ctx, "convert logs failed: %v", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+
+		return
+	}
+
+	if err := h.service.StoreEntryList(ctx, r.RemoteAddr, list); err != nil {
+		h.logger.Errorf(ctx, "store entry list failed: %v", err)
+
+		if errors.Is(err, domain.ErrListTooLarge) {
+			http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+
+			return
+		}
+
+		if retryAfter, ok := domain.RetryAfter(err); ok {
+			w.Header().Set("Retry-After", retryAfter)
+		}
+
+		http.Error(w, "store failed", http.StatusServiceUnavailable)
+
+		return
+	}
+
+	h.writeResponse(ctx, w, contentType)
+}
+
+func (h *LogsHandler) writeResponse(ctx context.Context, w http.ResponseWriter, contentType string) {
+	var (
+		resp = &collogspb.ExportLogsServiceResponse{}
+		data []byte
+		err  error
+	)
+
+	if contentType == _contentTypeJSON {
+		data, err = protojson.Marshal(resp)
+	} else {
+		contentType = _contentTypeProtobuf
+		data, err = proto.Marshal(resp)
+	}
+
+	if err != nil {
+		h.logger.Errorf(ctx, "marshal response failed: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(http.StatusOK)
+
+	if _, err := w.Write(data); err != nil {
+		h.logger.Errorf(ctx, "write response failed: %v", err)
+	}
+}
+
+func readRequest(r io.Reader, contentType string) (*collogspb.ExportLogsServiceRequest, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("read body: %w", err)
+	}
+
+	req := &collogspb.ExportLogsServiceRequest{}
+
+	switch contentType {
+	case _contentTypeJSON:
+		if err := (protojson.UnmarshalOptions{DiscardUnknown: true}).Unmarshal(data, req); err != nil {
+			return nil, fmt.Errorf("unmarshal json: %w", err)
+		}
+
+		fixJSONIDs(req)
+	default:
+		if err := proto.Unmarshal(data, req); err != nil {
+			return nil, fmt.Errorf("unmarshal protobuf: %w", err)
+		}
+	}
+
+	return req, nil
+}
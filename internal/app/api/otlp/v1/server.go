@@ -0,0 +1,75 @@
+package v1
+
+import (
+	"context"
+	"errors"
+	"net"
+
+	"github.com/loghole/tracing"
+	"github.com/loghole/tracing/tracelog"
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	"github.com/loghole/collector/internal/app/domain"
+)
+
+// LogsServer implements OTLP/gRPC logs service.
+type LogsServer struct {
+	collogspb.UnimplementedLogsServiceServer
+
+	service EntryService
+	logger  tracelog.Logger
+	tracer  *tracing.Tracer
+}
+
+func NewLogsServer(
+	service EntryService,
+	logger tracelog.Logger,
+	tracer *tracing.Tracer,
+) *LogsServer {
+	return &LogsServer{
+		service: service,
+		logger:  logger,
+		tracer:  tracer,
+	}
+}
+
+func (s *LogsServer) Export(
+	ctx context.Context,
+	req *collogspb.ExportLogsServiceRequest,
+) (*collogspb.ExportLogsServiceResponse, error) {
+	list, err := EntryList(req)
+	if err != nil {
+		s.logger.Errorf(ctx, "convert logs failed: %v", err)
+
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	if err := s.service.StoreEntryList(ctx, remoteIP(ctx), list); err != nil {
+		s.logger.Errorf(ctx, "store entry list failed: %v", err)
+
+		if errors.Is(err, domain.ErrListTooLarge) {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+
+		return nil, status.Error(codes.Unavailable, "store failed")
+	}
+
+	return &collogspb.ExportLogsServiceResponse{}, nil
+}
+
+func remoteIP(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return ""
+	}
+
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		return p.Addr.String()
+	}
+
+	return host
+}
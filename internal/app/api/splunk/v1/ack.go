@@ -0,0 +1,119 @@
+package v1
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/google/uuid"
+
+	"github.com/loghole/collector/internal/app/domain"
+)
+
+var ErrRequestFailed = errors.New("request failed")
+
+type AckRegistry interface {
+	Register(channel string) (uint64, error)
+	Commit(channel string, id uint64, err error)
+	Query(channel string, ids []uint64) map[uint64]bool
+}
+
+type AckRequest struct {
+	Acks []uint64 `json:"acks"`
+}
+
+type AckResponse struct {
+	Acks map[uint64]bool `json:"acks"`
+}
+
+// requestAck is an indexer acknowledgement of a single request.
+type requestAck struct {
+	id     uint64
+	commit *domain.Commit
+}
+
+// AckHandler reports status of indexer acknowledgements.
+func (h *SplunkHandler) AckHandler(w http.ResponseWriter, r *http.Request) {
+	resp, ctx := NewResponse(), r.Context()
+
+	channel, code := h.ackChannel(r)
+	if code != CodeSuccess {
+		resp.SetCode(code)
+		resp.Write(ctx, w, h.logger)
+
+		return
+	}
+
+	var req AckRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.logger.Errorf(ctx, "decode ack request failed: %v", err)
+		resp.SetCode(CodeInvalidDataFormat)
+		resp.Write(ctx, w, h.logger)
+
+		return
+	}
+
+	writeJSON(ctx, w, h.logger, http.StatusOK, &AckResponse{Acks: h.acks.Query(channel, req.Acks)})
+}
+
+// newRequestAck registers ack for request, returns nil if indexer acknowledgement is disabled.
+func (h *SplunkHandler) newRequestAck(r *http.Request) (*requestAck, int) {
+	if h.acks == nil {
+		return nil, CodeSuccess
+	}
+
+	channel, code := h.ackChannel(r)
+	if code != CodeSuccess {
+		return nil, code
+	}
+
+	id, err := h.acks.Register(channel)
+	if err != nil {
+		h.logger.Errorf(r.Context(), "register ack failed: %v", err)
+
+		return nil, CodeServerBusy
+	}
+
+	return &requestAck{
+		id: id,
+		commit: domain.NewCommit(func(err error) {
+			h.acks.Commit(channel, id, err)
+		}),
+	}, CodeSuccess
+}
+
+func (h *SplunkHandler) ackChannel(r *http.Request) (string, int) {
+	if h.acks == nil {
+		return "", CodeAckDisabled
+	}
+
+	channel := requestChannel(r)
+
+	if channel == "" {
+		return "", CodeChannelMissing
+	}
+
+	if _, err := uuid.Parse(channel); err != nil {
+		return "", CodeInvalidChannel
+	}
+
+	return channel, CodeSuccess
+}
+
+// closeAck closes request commit and sets ack id to the successful response.
+func (h *SplunkHandler) closeAck(ack *requestAck, resp *Response) {
+	if ack == nil {
+		return
+	}
+
+	if resp.Code != CodeSuccess {
+		ack.commit.Close(ErrRequestFailed)
+
+		return
+	}
+
+	ack.commit.Close(nil)
+
+	resp.AckID = &ack.id
+}
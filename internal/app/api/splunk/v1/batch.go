@@ -0,0 +1,83 @@
+package v1
+
+import (
+	"context"
+
+	"github.com/loghole/collector/internal/app/domain"
+)
+
+// batch collects events of a single request and counts accepted and rejected events.
+// Events are stored only if the whole request is valid, so a client retrying the rejected
+// request doesn't store its valid events twice. The number of events is limited by the handler,
+// so a large request is rejected while it is decoded.
+type batch struct {
+	handler  *SplunkHandler
+	remoteIP string
+	ack      *requestAck
+	list     domain.EntryList
+	tooLarge bool
+
+	accepted     int
+	rejected     int
+	invalidCode  int
+	invalidEvent int
+}
+
+func (h *SplunkHandler) newBatch(remoteIP string, ack *requestAck) *batch {
+	return &batch{
+		handler:  h,
+		remoteIP: remoteIP,
+		ack:      ack,
+	}
+}
+
+// add appends event, it returns false if the request has more events than allowed.
+func (b *batch) add(entry *domain.Entry) bool {
+	if b.handler.maxEvents > 0 && len(b.list) >= b.handler.maxEvents {
+		b.list, b.tooLarge = nil, true
+
+		return false
+	}
+
+	b.list = append(b.list, entry)
+
+	return true
+}
+
+// reject counts invalid event, the first one is reported in response.
+func (b *batch) reject(number, code int) {
+	if b.rejected == 0 {
+		b.invalidCode, b.invalidEvent = code, number
+	}
+
+	b.rejected++
+}
+
+// flush stores collected events if none of them was rejected.
+func (b *batch) flush(ctx context.Context) error {
+	if len(b.list) == 0 || b.rejected > 0 || b.tooLarge {
+		return nil
+	}
+
+	if err := b.handler.store(ctx, b.remoteIP, b.list, b.ack); err != nil {
+		return err
+	}
+
+	b.accepted = len(b.list)
+
+	return nil
+}
+
+// setResult sets counters and the first invalid event to response.
+func (b *batch) setResult(resp *Response) {
+	switch {
+	case b.tooLarge:
+		resp.SetTooLarge()
+	case len(b.list)+b.rejected == 0:
+		resp.SetCode(CodeNoData)
+	case b.rejected > 0:
+		resp.SetInvalidEvent(b.invalidCode, b.invalidEvent)
+	}
+
+	resp.Accepted, resp.Rejected = b.accepted, b.rejected
+}
@@ -0,0 +1,172 @@
+package v1
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/loghole/collector/internal/app/domain"
+)
+
+const (
+	_timeParts  = 2
+	_nanoDigits = 9
+)
+
+var (
+	ErrEventRequired = errors.New("event field is required")
+	ErrEventBlank    = errors.New("event field cannot be blank")
+)
+
+// Event is a Splunk HTTP Event Collector event.
+// https://docs.splunk.com/Documentation/Splunk/latest/Data/FormateventsforHTTPEventCollector
+type Event struct {
+	Time       json.RawMessage        `json:"time"`
+	Host       string                 `json:"host"`
+	Source     string                 `json:"source"`
+	SourceType string                 `json:"sourcetype"`
+	Index      string                 `json:"index"`
+	Event      json.RawMessage        `json:"event"`
+	Fields     map[string]interface{} `json:"fields"`
+}
+
+// Entry converts event to domain entry. Values from event body have priority over event metadata.
+func (e *Event) Entry() (*domain.Entry, error) {
+	fields, err := e.eventFields()
+	if err != nil {
+		return nil, err
+	}
+
+	eventTime, err := parseTime(e.Time)
+	if err != nil {
+		return nil, fmt.Errorf("parse time: %w", err)
+	}
+
+	if !eventTime.IsZero() {
+		setDefault(fields, "time", eventTime)
+	}
+
+	setDefault(fields, "host", e.Host)
+	setDefault(fields, "source", e.Source)
+	setDefault(fields, "namespace", e.Index)
+	setDefault(fields, "sourcetype", e.SourceType)
+
+	for key, val := range e.Fields {
+		setDefault(fields, key, val)
+	}
+
+	return domain.NewEntry(fields)
+}
+
+func (e *Event) eventFields() (map[string]interface{}, error) {
+	data := bytes.TrimSpace(e.Event)
+
+	if len(data) == 0 || bytes.Equal(data, []byte("null")) {
+		return nil, ErrEventRequired
+	}
+
+	switch data[0] {
+	case '"':
+		var message string
+
+		if err := json.Unmarshal(data, &message); err != nil {
+			return nil, err
+		}
+
+		if strings.TrimSpace(message) == "" {
+			return nil, ErrEventBlank
+		}
+
+		return map[string]interface{}{"message": message}, nil
+	case '{':
+		return objectFields(data)
+	default:
+		return map[string]interface{}{"message": string(data)}, nil
+	}
+}
+
+// objectFields returns fields of event object. Objects sent by docker splunk logging driver
+// keep log line in the "line" key, as a string or as an object for json format.
+func objectFields(data []byte) (map[string]interface{}, error) {
+	fields, err := decodeObject(data)
+	if err != nil {
+		return nil, err
+	}
+
+	switch line := fields["line"].(type) {
+	case string:
+		return map[string]interface{}{"message": line}, nil
+	case map[string]interface{}:
+		return line, nil
+	default:
+		return fields, nil
+	}
+}
+
+func decodeObject(data []byte) (map[string]interface{}, error) {
+	var fields map[string]interface{}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	if err := decoder.Decode(&fields); err != nil {
+		return nil, err
+	}
+
+	if len(fields) == 0 {
+		return nil, ErrEventBlank
+	}
+
+	return fields, nil
+}
+
+// parseTime parses epoch time in seconds with optional fraction, as a number or a string.
+func parseTime(data json.RawMessage) (time.Time, error) {
+	str := strings.Trim(string(bytes.TrimSpace(data)), `"`)
+
+	if str == "" || str == "null" {
+		return time.Time{}, nil
+	}
+
+	parts := strings.SplitN(str, ".", _timeParts)
+
+	sec, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if len(parts) < _timeParts {
+		return time.Unix(sec, 0), nil
+	}
+
+	nsecStr := parts[1]
+
+	if len(nsecStr) > _nanoDigits {
+		nsecStr = nsecStr[:_nanoDigits]
+	}
+
+	if nsecStr == "" {
+		return time.Unix(sec, 0), nil
+	}
+
+	nsec, err := strconv.ParseInt(nsecStr+strings.Repeat("0", _nanoDigits-len(nsecStr)), 10, 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return time.Unix(sec, nsec), nil
+}
+
+func setDefault(fields map[string]interface{}, key string, val interface{}) {
+	if str, ok := val.(string); ok && str == "" {
+		return
+	}
+
+	if _, ok := fields[key]; !ok {
+		fields[key] = val
+	}
+}
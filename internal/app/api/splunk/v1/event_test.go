@@ -0,0 +1,172 @@
+package v1
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseTime(t *testing.T) {
+	tests := []struct {
+		name         string
+		data         string
+		wantErr      bool
+		expectedTime time.Time
+	}{
+		{
+			name: "MissingPass",
+			data: ``,
+		},
+		{
+			name: "NullPass",
+			data: `null`,
+		},
+		{
+			name:         "SecondsPass",
+			data:         `1595768727`,
+			expectedTime: time.Unix(1595768727, 0),
+		},
+		{
+			name:         "FloatPass",
+			data:         `1595768727.429`,
+			expectedTime: time.Unix(1595768727, 429000000),
+		},
+		{
+			name:         "StringPass",
+			data:         `"1595768727.429286952"`,
+			expectedTime: time.Unix(1595768727, 429286952),
+		},
+		{
+			name:         "LongFractionPass",
+			data:         `1595768727.4292869521234`,
+			expectedTime: time.Unix(1595768727, 429286952),
+		},
+		{
+			name:         "EmptyFractionPass",
+			data:         `"1595768727."`,
+			expectedTime: time.Unix(1595768727, 0),
+		},
+		{
+			name:    "NotNumberError",
+			data:    `"yesterday"`,
+			wantErr: true,
+		},
+		{
+			name:    "InvalidFractionError",
+			data:    `1595768727.4e3`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := parseTime(json.RawMessage(tt.data))
+			if (err != nil) != tt.wantErr {
+				t.Error(err)
+			}
+
+			assert.True(t, tt.expectedTime.Equal(res), res)
+		})
+	}
+}
+
+func TestEvent_Entry(t *testing.T) {
+	type expected struct {
+		Time      time.Time
+		Namespace string
+		Source    string
+		Host      string
+		Level     string
+		Message   string
+	}
+
+	tests := []struct {
+		name        string
+		data        string
+		wantErr     error
+		expectedRes expected
+	}{
+		{
+			name: "MetadataPass",
+			data: `{"time":1595768727.5,"host":"web-1","source":"api","index":"prod",
+				"event":"request done","fields":{"level":"info"}}`,
+			expectedRes: expected{
+				Time:      time.Unix(1595768727, 500000000).UTC(),
+				Namespace: "prod",
+				Source:    "api",
+				Host:      "web-1",
+				Level:     "info",
+				Message:   "request done",
+			},
+		},
+		{
+			name: "EventObjectPriorityPass",
+			data: `{"host":"web-1","index":"prod","event":{"message":"object","namespace":"dev","level":"error"},
+				"fields":{"level":"info"}}`,
+			expectedRes: expected{
+				Namespace: "dev",
+				Host:      "web-1",
+				Level:     "error",
+				Message:   "object",
+			},
+		},
+		{
+			name: "DockerLinePass",
+			data: `{"source":"stdout","event":{"line":"plain line","tag":"app"}}`,
+			expectedRes: expected{
+				Source:  "stdout",
+				Message: "plain line",
+			},
+		},
+		{
+			name: "DockerJSONLinePass",
+			data: `{"event":{"line":{"message":"json line","level":"warn"},"tag":"app"}}`,
+			expectedRes: expected{
+				Level:   "warn",
+				Message: "json line",
+			},
+		},
+		{
+			name: "NumberEventPass",
+			data: `{"event":42}`,
+			expectedRes: expected{
+				Message: "42",
+			},
+		},
+		{
+			name:    "MissingEventError",
+			data:    `{"host":"web-1"}`,
+			wantErr: ErrEventRequired,
+		},
+		{
+			name:    "BlankEventError",
+			data:    `{"event":"  "}`,
+			wantErr: ErrEventBlank,
+		},
+		{
+			name:    "EmptyObjectError",
+			data:    `{"event":{}}`,
+			wantErr: ErrEventBlank,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entry, err := parseMessage([]byte(tt.data))
+			assert.ErrorIs(t, err, tt.wantErr)
+
+			if tt.wantErr != nil {
+				return
+			}
+
+			assert.Equal(t, tt.expectedRes, expected{
+				Time:      entry.Time.UTC(),
+				Namespace: entry.Namespace,
+				Source:    entry.Source,
+				Host:      entry.Host,
+				Level:     entry.Level,
+				Message:   entry.Message,
+			})
+		})
+	}
+}
@@ -1,103 +1,147 @@
 package v1
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
-	"fmt"
+	"errors"
 	"io"
 	"net/http"
+	"regexp"
 
+	"github.com/gorilla/mux"
 	"github.com/loghole/tracing"
 	"github.com/loghole/tracing/tracelog"
+
+	"github.com/loghole/collector/internal/app/domain"
 )
 
 type EntryService interface {
-	StoreItem(ctx context.Context, remoteIP string, data []byte) (err error)
-	StoreList(ctx context.Context, remoteIP string, data []byte) (err error)
-}
-
-type Message struct {
-	Event Event `json:"event"`
-}
-
-type Event struct {
-	Line json.RawMessage `json:"line"`
+	StoreEntryList(ctx context.Context, remoteIP string, list domain.EntryList) (err error)
 }
 
 type SplunkHandler struct {
-	service EntryService
-	logger  tracelog.Logger
-	tracer  *tracing.Tracer
+	service     EntryService
+	logger      tracelog.Logger
+	tracer      *tracing.Tracer
+	lineBreaker *regexp.Regexp
+	acks        AckRegistry
+	maxEvents   int
 }
 
+// NewSplunkHandler creates handler, indexer acknowledgement is disabled if acks is nil.
+// Requests with more than maxEvents events are rejected, zero disables the limit.
 func NewSplunkHandler(
 	service EntryService,
 	logger tracelog.Logger,
 	tracer *tracing.Tracer,
+	lineBreaker *regexp.Regexp,
+	acks AckRegistry,
+	maxEvents int,
 ) *SplunkHandler {
 	return &SplunkHandler{
-		service: service,
-		logger:  logger,
-		tracer:  tracer,
+		service:     service,
+		logger:      logger,
+		tracer:      tracer,
+		lineBreaker: lineBreaker,
+		acks:        acks,
+		maxEvents:   maxEvents,
 	}
 }
 
+// RegisterRoutes adds endpoints of the HTTP Event Collector and their versioned aliases
+// to the router of the /services/collector prefix.
+func (h *SplunkHandler) RegisterRoutes(r *mux.Router) {
+	r.HandleFunc("", h.Handler)
+	r.HandleFunc("/event", h.Handler)
+	r.HandleFunc("/event/1.0", h.Handler)
+	r.HandleFunc("/raw", h.RawHandler)
+	r.HandleFunc("/raw/1.0", h.RawHandler)
+	r.HandleFunc("/ack", h.AckHandler)
+	r.HandleFunc("/ack/1.0", h.AckHandler)
+}
+
+// Handler receives events in the HTTP Event Collector format. Events are decoded one by one from
+// concatenated or whitespace separated json objects, the request is stored only if all events are valid.
 func (h *SplunkHandler) Handler(w http.ResponseWriter, r *http.Request) {
-	data, err := io.ReadAll(r.Body)
-	if err != nil {
-		http.Error(w, "read data", http.StatusInternalServerError)
+	resp, ctx := NewResponse(), r.Context()
+	defer resp.Write(ctx, w, h.logger)
 
-		return
-	}
+	ack, code := h.newRequestAck(r)
+	if code != CodeSuccess {
+		resp.SetCode(code)
 
-	if len(data) == 0 {
 		return
 	}
 
+	defer h.closeAck(ack, resp)
+
 	var (
-		ctx  = r.Context()
-		addr = r.RemoteAddr
+		decoder = json.NewDecoder(r.Body)
+		batch   = h.newBatch(r.RemoteAddr, ack)
 	)
 
-	for {
-		idx := bytes.Index(data, []byte("}{"))
-		if idx == -1 {
+	for number := 0; ; number++ {
+		var message json.RawMessage
+
+		if err := decoder.Decode(&message); err != nil {
+			if !errors.Is(err, io.EOF) {
+				h.logger.Errorf(ctx, "decode message failed: %v", err)
+				batch.reject(number, CodeInvalidDataFormat)
+			}
+
 			break
 		}
 
-		if err := h.handleMessage(ctx, addr, data[:idx+1]); err != nil {
-			http.Error(w, "handle message", http.StatusInternalServerError)
+		entry, err := parseMessage(message)
+		if err != nil {
+			h.logger.Errorf(ctx, "parse message failed: %v", err)
+			batch.reject(number, eventErrorCode(err))
 
-			return
+			continue
 		}
 
-		data = data[idx+1:]
+		if !batch.add(entry) {
+			h.logger.Errorf(ctx, "request has more than %d events", h.maxEvents)
+
+			break
+		}
 	}
 
-	if err := h.handleMessage(ctx, addr, data); err != nil {
-		http.Error(w, "handle message", http.StatusInternalServerError)
+	if err := batch.flush(ctx); err != nil {
+		h.logger.Errorf(ctx, "store entry list failed: %v", err)
+		resp.SetStoreError(err)
 
 		return
 	}
 
-	w.WriteHeader(http.StatusOK)
+	batch.setResult(resp)
 }
 
-func (h *SplunkHandler) handleMessage(ctx context.Context, addr string, data []byte) error {
-	var dest Message
+func (h *SplunkHandler) store(ctx context.Context, remoteIP string, list domain.EntryList, ack *requestAck) error {
+	if ack != nil {
+		ack.commit.Attach(list)
+	}
+
+	return h.service.StoreEntryList(ctx, remoteIP, list)
+}
 
-	if err := json.Unmarshal(data, &dest); err != nil {
-		h.logger.Errorf(ctx, "unmarshal message: %v", err)
+func parseMessage(data []byte) (*domain.Entry, error) {
+	var event Event
 
-		return nil
+	if err := json.Unmarshal(data, &event); err != nil {
+		return nil, err
 	}
 
-	if err := h.service.StoreItem(ctx, addr, dest.Event.Line); err != nil {
-		h.logger.Errorf(ctx, "store item: %v", err)
+	return event.Entry()
+}
 
-		return fmt.Errorf("store item: %w", err)
+func eventErrorCode(err error) int {
+	switch {
+	case errors.Is(err, ErrEventRequired):
+		return CodeEventRequired
+	case errors.Is(err, ErrEventBlank):
+		return CodeEventBlank
+	default:
+		return CodeInvalidDataFormat
 	}
-
-	return nil
 }
@@ -0,0 +1,234 @@
+package v1
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/loghole/tracing/tracelog"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+
+	"github.com/loghole/collector/internal/app/domain"
+)
+
+type serviceMock struct {
+	mu    sync.Mutex
+	err   error
+	lists []domain.EntryList
+}
+
+func (s *serviceMock) StoreEntryList(ctx context.Context, remoteIP string, list domain.EntryList) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.err != nil {
+		return s.err
+	}
+
+	s.lists = append(s.lists, list)
+
+	return nil
+}
+
+func (s *serviceMock) messages() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	messages := make([]string, 0)
+
+	for _, list := range s.lists {
+		for _, entry := range list {
+			messages = append(messages, entry.Message)
+		}
+	}
+
+	return messages
+}
+
+func TestSplunkHandler_RegisterRoutes(t *testing.T) {
+	tests := []struct {
+		name             string
+		path             string
+		body             string
+		expectedStatus   int
+		expectedMessages []string
+	}{
+		{
+			name:             "CollectorPass",
+			path:             "/services/collector",
+			body:             `{"event":"first"}`,
+			expectedStatus:   http.StatusOK,
+			expectedMessages: []string{"first"},
+		},
+		{
+			name:             "EventPass",
+			path:             "/services/collector/event",
+			body:             `{"event":"first"}`,
+			expectedStatus:   http.StatusOK,
+			expectedMessages: []string{"first"},
+		},
+		{
+			name:             "EventVersionPass",
+			path:             "/services/collector/event/1.0",
+			body:             `{"event":"first"}`,
+			expectedStatus:   http.StatusOK,
+			expectedMessages: []string{"first"},
+		},
+		{
+			name:             "RawPass",
+			path:             "/services/collector/raw",
+			body:             "first\nsecond",
+			expectedStatus:   http.StatusOK,
+			expectedMessages: []string{"first", "second"},
+		},
+		{
+			name:             "RawVersionPass",
+			path:             "/services/collector/raw/1.0",
+			body:             "first\nsecond",
+			expectedStatus:   http.StatusOK,
+			expectedMessages: []string{"first", "second"},
+		},
+		{
+			name:             "AckDisabledError",
+			path:             "/services/collector/ack/1.0",
+			body:             `{"acks":[0]}`,
+			expectedStatus:   http.StatusBadRequest,
+			expectedMessages: []string{},
+		},
+		{
+			name:             "UnknownPathError",
+			path:             "/services/collector/metrics",
+			body:             `{"event":"first"}`,
+			expectedStatus:   http.StatusNotFound,
+			expectedMessages: []string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				service = &serviceMock{}
+				router  = mux.NewRouter()
+				handler = NewSplunkHandler(service, tracelog.NewTraceLogger(zap.NewNop().Sugar()), nil,
+					regexp.MustCompile(`([\r\n]+)`), nil, 0)
+			)
+
+			handler.RegisterRoutes(router.PathPrefix("/services/collector").Subrouter())
+
+			rec := httptest.NewRecorder()
+
+			router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader(tt.body)))
+
+			assert.Equal(t, tt.expectedStatus, rec.Code)
+			assert.Equal(t, tt.expectedMessages, service.messages())
+		})
+	}
+}
+
+func TestSplunkHandler_Handler(t *testing.T) {
+	tests := []struct {
+		name             string
+		body             string
+		storeErr         error
+		maxEvents        int
+		expectedStatus   int
+		expectedResp     string
+		expectedMessages []string
+	}{
+		{
+			name:             "ConcatenatedPass",
+			body:             `{"event":"first"}{"event":"}{"}`,
+			expectedStatus:   http.StatusOK,
+			expectedResp:     `{"text":"Success","code":0,"accepted":2}`,
+			expectedMessages: []string{"first", "}{"},
+		},
+		{
+			name:             "NewlineSeparatedPass",
+			body:             "{\"event\":\"first\"}\n{\"event\":\"second\"}\r\n",
+			expectedStatus:   http.StatusOK,
+			expectedResp:     `{"text":"Success","code":0,"accepted":2}`,
+			expectedMessages: []string{"first", "second"},
+		},
+		{
+			name:             "WhitespaceSeparatedPass",
+			body:             " {\"event\":\"first\"} \t {\"event\":{\"message\":\"second\"}} ",
+			expectedStatus:   http.StatusOK,
+			expectedResp:     `{"text":"Success","code":0,"accepted":2}`,
+			expectedMessages: []string{"first", "second"},
+		},
+		{
+			name:             "NoDataError",
+			body:             " \n ",
+			expectedStatus:   http.StatusBadRequest,
+			expectedResp:     `{"text":"No data","code":5}`,
+			expectedMessages: []string{},
+		},
+		{
+			name:             "BlankEventError",
+			body:             `{"event":"first"}{"event":""}{"event":"third"}`,
+			expectedStatus:   http.StatusBadRequest,
+			expectedResp:     `{"text":"Event field cannot be blank","code":13,"invalid-event-number":1,"rejected":1}`,
+			expectedMessages: []string{},
+		},
+		{
+			name:             "InvalidFormatError",
+			body:             `{"event":"first"}{"event":`,
+			expectedStatus:   http.StatusBadRequest,
+			expectedResp:     `{"text":"Invalid data format","code":6,"invalid-event-number":1,"rejected":1}`,
+			expectedMessages: []string{},
+		},
+		{
+			name:             "TooManyEventsError",
+			body:             `{"event":"first"}{"event":"second"}{"event":"third"}`,
+			maxEvents:        2,
+			expectedStatus:   http.StatusRequestEntityTooLarge,
+			expectedResp:     `{"text":"Content too large","code":6}`,
+			expectedMessages: []string{},
+		},
+		{
+			name:             "MaxEventsPass",
+			body:             `{"event":"first"}{"event":"second"}`,
+			maxEvents:        2,
+			expectedStatus:   http.StatusOK,
+			expectedResp:     `{"text":"Success","code":0,"accepted":2}`,
+			expectedMessages: []string{"first", "second"},
+		},
+		{
+			name:             "QueueFullError",
+			body:             `{"event":"first"}`,
+			storeErr:         &domain.QueueFullError{RetryAfter: time.Second},
+			expectedStatus:   http.StatusServiceUnavailable,
+			expectedResp:     `{"text":"Server is busy","code":9}`,
+			expectedMessages: []string{},
+		},
+		{
+			name:             "ListTooLargeError",
+			body:             `{"event":"first"}`,
+			storeErr:         domain.ErrListTooLarge,
+			expectedStatus:   http.StatusRequestEntityTooLarge,
+			expectedResp:     `{"text":"Content too large","code":6}`,
+			expectedMessages: []string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				service = &serviceMock{err: tt.storeErr}
+				handler = NewSplunkHandler(service, tracelog.NewTraceLogger(zap.NewNop().Sugar()), nil, nil, nil, tt.maxEvents)
+				rec     = httptest.NewRecorder()
+			)
+
+			handler.Handler(rec, httptest.NewRequest(http.MethodPost, "/services/collector", strings.NewReader(tt.body)))
+
+			assert.Equal(t, tt.expectedStatus, rec.Code)
+			assert.JSONEq(t, tt.expectedResp, rec.Body.String())
+			assert.Equal(t, tt.expectedMessages, service.messages())
+		})
+	}
+}
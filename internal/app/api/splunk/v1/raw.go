@@ -0,0 +1,162 @@
+package v1
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/loghole/collector/internal/app/domain"
+)
+
+const (
+	_maxRawEventSize = 1 << 20
+
+	_channelHeader = "X-Splunk-Request-Channel"
+)
+
+var ErrEmptyLineBreaker = errors.New("line breaker matches empty string")
+
+// NewLineBreaker compiles regular expression that splits raw data into events.
+// Like the Splunk LINE_BREAKER the first capturing group is treated as the events delimiter,
+// without groups the whole match is the delimiter.
+func NewLineBreaker(expr string) (*regexp.Regexp, error) {
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, fmt.Errorf("compile: %w", err)
+	}
+
+	if re.MatchString("") {
+		return nil, ErrEmptyLineBreaker
+	}
+
+	return re, nil
+}
+
+// RawHandler receives plain text data, each line of data is stored as a separate entry.
+func (h *SplunkHandler) RawHandler(w http.ResponseWriter, r *http.Request) {
+	resp, ctx := NewResponse(), r.Context()
+	defer resp.Write(ctx, w, h.logger)
+
+	ack, code := h.newRequestAck(r)
+	if code != CodeSuccess {
+		resp.SetCode(code)
+
+		return
+	}
+
+	defer h.closeAck(ack, resp)
+
+	var (
+		query    = r.URL.Query()
+		template = Event{
+			Time:       json.RawMessage(query.Get("time")),
+			Host:       query.Get("host"),
+			Source:     query.Get("source"),
+			SourceType: query.Get("sourcetype"),
+			Index:      query.Get("index"),
+			Fields:     make(map[string]interface{}),
+		}
+		scanner = bufio.NewScanner(r.Body)
+		batch   = h.newBatch(r.RemoteAddr, ack)
+		number  int
+	)
+
+	if channel := requestChannel(r); channel != "" {
+		template.Fields["channel"] = channel
+	}
+
+	scanner.Buffer(make([]byte, 0, bufio.MaxScanTokenSize), _maxRawEventSize)
+	scanner.Split(splitFunc(h.lineBreaker))
+
+	for ; scanner.Scan(); number++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		entry, err := rawEntry(template, line)
+		if err != nil {
+			h.logger.Errorf(ctx, "parse raw event failed: %v", err)
+			batch.reject(number, CodeInvalidDataFormat)
+
+			continue
+		}
+
+		if !batch.add(entry) {
+			h.logger.Errorf(ctx, "request has more than %d events", h.maxEvents)
+
+			break
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		h.logger.Errorf(ctx, "read body failed: %v", err)
+		batch.reject(number, CodeInvalidDataFormat)
+	}
+
+	if err := batch.flush(ctx); err != nil {
+		h.logger.Errorf(ctx, "store entry list failed: %v", err)
+		resp.SetStoreError(err)
+
+		return
+	}
+
+	batch.setResult(resp)
+}
+
+func rawEntry(template Event, line string) (*domain.Entry, error) {
+	data, err := json.Marshal(line)
+	if err != nil {
+		return nil, err
+	}
+
+	template.Event = data
+
+	return template.Entry()
+}
+
+func requestChannel(r *http.Request) string {
+	if channel := r.Header.Get(_channelHeader); channel != "" {
+		return channel
+	}
+
+	return r.URL.Query().Get("channel")
+}
+
+func splitFunc(lineBreaker *regexp.Regexp) bufio.SplitFunc {
+	return func(data []byte, atEOF bool) (advance int, token []byte, err error) {
+		if atEOF && len(data) == 0 {
+			return 0, nil, nil
+		}
+
+		// Wait for more data if delimiter reaches end of buffer, it may continue in the next read.
+		if start, end := breakerIndex(lineBreaker, data); start >= 0 && (end < len(data) || atEOF) {
+			return end, data[:start], nil
+		}
+
+		if atEOF {
+			return len(data), data, nil
+		}
+
+		return 0, nil, nil
+	}
+}
+
+func breakerIndex(lineBreaker *regexp.Regexp, data []byte) (start, end int) {
+	const groupIdx = 2
+
+	loc := lineBreaker.FindSubmatchIndex(data)
+
+	switch {
+	case loc == nil:
+		return -1, -1
+	case len(loc) > groupIdx+1 && loc[groupIdx] >= 0:
+		return loc[groupIdx], loc[groupIdx+1]
+	default:
+		return loc[0], loc[1]
+	}
+}
@@ -0,0 +1,176 @@
+package v1
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/loghole/tracing/tracelog"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestBreakerIndex(t *testing.T) {
+	tests := []struct {
+		name          string
+		expr          string
+		data          string
+		expectedStart int
+		expectedEnd   int
+	}{
+		{
+			name:          "WholeMatchPass",
+			expr:          `\r?\n`,
+			data:          "first\r\nsecond",
+			expectedStart: 5,
+			expectedEnd:   7,
+		},
+		{
+			name:          "GroupPass",
+			expr:          `([\r\n]+)\d{4}-`,
+			data:          "first\ncontinued\n2022-second",
+			expectedStart: 15,
+			expectedEnd:   16,
+		},
+		{
+			name:          "UnmatchedGroupPass",
+			expr:          `([\r\n]+)|;`,
+			data:          "first;second",
+			expectedStart: 5,
+			expectedEnd:   6,
+		},
+		{
+			name:          "NotFoundPass",
+			expr:          `([\r\n]+)`,
+			data:          "first",
+			expectedStart: -1,
+			expectedEnd:   -1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start, end := breakerIndex(regexp.MustCompile(tt.expr), []byte(tt.data))
+
+			assert.Equal(t, tt.expectedStart, start)
+			assert.Equal(t, tt.expectedEnd, end)
+		})
+	}
+}
+
+func TestSplitFunc(t *testing.T) {
+	tests := []struct {
+		name           string
+		expr           string
+		data           string
+		expectedTokens []string
+	}{
+		{
+			name:           "NewlinesPass",
+			expr:           `([\r\n]+)`,
+			data:           "first\nsecond\n\nthird\n",
+			expectedTokens: []string{"first", "second", "third"},
+		},
+		{
+			name:           "CRLFPass",
+			expr:           `([\r\n]+)`,
+			data:           "first\r\nsecond\r\n",
+			expectedTokens: []string{"first", "second"},
+		},
+		{
+			name:           "GroupPass",
+			expr:           `([\r\n]+)\d{4}-\d{2}-\d{2}`,
+			data:           "2022-10-18 first\n  at main.go:10\n2022-10-18 second",
+			expectedTokens: []string{"2022-10-18 first\n  at main.go:10", "2022-10-18 second"},
+		},
+		{
+			name:           "BlankPass",
+			expr:           `([\r\n]+)`,
+			data:           "\r\n\n",
+			expectedTokens: []string{""},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Data is read by one byte, so delimiters are split across reads.
+			scanner := bufio.NewScanner(iotest.OneByteReader(strings.NewReader(tt.data)))
+			scanner.Split(splitFunc(regexp.MustCompile(tt.expr)))
+
+			tokens := make([]string, 0)
+
+			for scanner.Scan() {
+				tokens = append(tokens, scanner.Text())
+			}
+
+			assert.NoError(t, scanner.Err())
+			assert.Equal(t, tt.expectedTokens, tokens)
+		})
+	}
+}
+
+func TestSplunkHandler_RawHandler(t *testing.T) {
+	tests := []struct {
+		name             string
+		target           string
+		body             string
+		maxEvents        int
+		expectedStatus   int
+		expectedMessages []string
+		expectedHost     string
+	}{
+		{
+			name:             "LinesPass",
+			target:           "/services/collector/raw?host=web-1&index=prod",
+			body:             "first\r\n\r\nsecond\n",
+			expectedStatus:   http.StatusOK,
+			expectedMessages: []string{"first", "second"},
+			expectedHost:     "web-1",
+		},
+		{
+			name:             "BlankError",
+			target:           "/services/collector/raw",
+			body:             " \r\n\n\t\n",
+			expectedStatus:   http.StatusBadRequest,
+			expectedMessages: []string{},
+		},
+		{
+			name:             "TooManyEventsError",
+			target:           "/services/collector/raw",
+			body:             "first\nsecond\nthird",
+			maxEvents:        2,
+			expectedStatus:   http.StatusRequestEntityTooLarge,
+			expectedMessages: []string{},
+		},
+		{
+			name:             "InvalidTimeError",
+			target:           "/services/collector/raw?time=yesterday",
+			body:             "first\nsecond",
+			expectedStatus:   http.StatusBadRequest,
+			expectedMessages: []string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				service = &serviceMock{}
+				handler = NewSplunkHandler(service, tracelog.NewTraceLogger(zap.NewNop().Sugar()), nil,
+					regexp.MustCompile(`([\r\n]+)`), nil, tt.maxEvents)
+				rec = httptest.NewRecorder()
+			)
+
+			handler.RawHandler(rec, httptest.NewRequest(http.MethodPost, tt.target, strings.NewReader(tt.body)))
+
+			assert.Equal(t, tt.expectedStatus, rec.Code)
+			assert.Equal(t, tt.expectedMessages, service.messages())
+
+			for _, list := range service.lists {
+				for _, entry := range list {
+					assert.Equal(t, tt.expectedHost, entry.Host)
+				}
+			}
+		})
+	}
+}
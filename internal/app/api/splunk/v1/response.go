@@ -0,0 +1,122 @@
+package v1
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/loghole/collector/internal/app/domain"
+)
+
+// HTTP Event Collector status codes.
+// https://docs.splunk.com/Documentation/Splunk/latest/Data/TroubleshootHTTPEventCollector
+const (
+	CodeSuccess           = 0
+	CodeNoData            = 5
+	CodeInvalidDataFormat = 6
+	CodeServerError       = 8
+	CodeServerBusy        = 9
+	CodeChannelMissing    = 10
+	CodeInvalidChannel    = 11
+	CodeEventRequired     = 12
+	CodeEventBlank        = 13
+	CodeAckDisabled       = 14
+)
+
+type Logger interface {
+	Errorf(ctx context.Context, template string, args ...interface{})
+}
+
+type Response struct {
+	Status             int     `json:"-"`
+	RetryAfter         string  `json:"-"`
+	Text               string  `json:"text"`
+	Code               int     `json:"code"`
+	InvalidEventNumber *int    `json:"invalid-event-number,omitempty"`
+	AckID              *uint64 `json:"ackId,omitempty"`
+	Accepted           int     `json:"accepted,omitempty"`
+	Rejected           int     `json:"rejected,omitempty"`
+}
+
+func NewResponse() *Response {
+	return &Response{Status: http.StatusOK, Text: "Success", Code: CodeSuccess}
+}
+
+func (r *Response) Write(ctx context.Context, w http.ResponseWriter, log Logger) {
+	if r.RetryAfter != "" {
+		w.Header().Set("Retry-After", r.RetryAfter)
+	}
+
+	writeJSON(ctx, w, log, r.Status, r)
+}
+
+func (r *Response) SetCode(code int) {
+	r.Code = code
+
+	switch code {
+	case CodeSuccess:
+		r.Status, r.Text = http.StatusOK, "Success"
+	case CodeNoData:
+		r.Status, r.Text = http.StatusBadRequest, "No data"
+	case CodeInvalidDataFormat:
+		r.Status, r.Text = http.StatusBadRequest, "Invalid data format"
+	case CodeServerBusy:
+		r.Status, r.Text = http.StatusServiceUnavailable, "Server is busy"
+	case CodeChannelMissing:
+		r.Status, r.Text = http.StatusBadRequest, "Data channel is missing"
+	case CodeInvalidChannel:
+		r.Status, r.Text = http.StatusBadRequest, "Invalid data channel"
+	case CodeEventRequired:
+		r.Status, r.Text = http.StatusBadRequest, "Event field is required"
+	case CodeEventBlank:
+		r.Status, r.Text = http.StatusBadRequest, "Event field cannot be blank"
+	case CodeAckDisabled:
+		r.Status, r.Text = http.StatusBadRequest, "ACK is disabled"
+	default:
+		r.Status, r.Text = http.StatusInternalServerError, "Internal server error"
+	}
+}
+
+// SetStoreError sets server busy code if the writer queue is full and server error code otherwise.
+// Requests too large to be queued get 413 status, so that clients don't retry them.
+func (r *Response) SetStoreError(err error) {
+	if errors.Is(err, domain.ErrListTooLarge) {
+		r.SetTooLarge()
+
+		return
+	}
+
+	retryAfter, ok := domain.RetryAfter(err)
+	if !ok {
+		r.SetCode(CodeServerError)
+
+		return
+	}
+
+	r.SetCode(CodeServerBusy)
+	r.RetryAfter = retryAfter
+}
+
+// SetTooLarge sets invalid data format code with 413 status, so that clients don't retry the request.
+func (r *Response) SetTooLarge() {
+	r.SetCode(CodeInvalidDataFormat)
+	r.Status, r.Text = http.StatusRequestEntityTooLarge, "Content too large"
+}
+
+func (r *Response) SetInvalidEvent(code, number int) {
+	r.SetCode(code)
+	r.InvalidEventNumber = &number
+}
+
+func writeJSON(ctx context.Context, w http.ResponseWriter, log Logger, status int, v interface{}) {
+	w.Header().Add("Content-Type", "application/json")
+
+	if status != 0 {
+		w.WriteHeader(status)
+	}
+
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Errorf(ctx, "write response failed: %v", err)
+	}
+}
@@ -0,0 +1,48 @@
+package syslog
+
+import (
+	"strconv"
+
+	"github.com/loghole/collector/internal/app/domain"
+)
+
+// nolint:gochecknoglobals // lookup table
+var _facilities = [...]string{
+	"kern", "user", "mail", "daemon", "auth", "syslog", "lpr", "news",
+	"uucp", "cron", "authpriv", "ftp", "ntp", "security", "console", "solaris-cron",
+	"local0", "local1", "local2", "local3", "local4", "local5", "local6", "local7",
+}
+
+// Entry converts message to domain entry. Severity is stored as level, app-name as source
+// and structured data params as "sd-id.param-name" params.
+func (m *Message) Entry() (*domain.Entry, error) {
+	fields := map[string]interface{}{
+		"message":         m.Message,
+		"syslog.facility": _facilities[m.Facility],
+		"syslog.severity": strconv.Itoa(m.Severity),
+	}
+
+	setField(fields, "host", m.Hostname)
+	setField(fields, "source", m.AppName)
+	setField(fields, "level", domain.SyslogLevel(m.Severity))
+	setField(fields, "syslog.procid", m.ProcID)
+	setField(fields, "syslog.msgid", m.MsgID)
+
+	if !m.Timestamp.IsZero() {
+		fields["time"] = m.Timestamp
+	}
+
+	for id, params := range m.StructuredData {
+		for name, val := range params {
+			fields[id+"."+name] = val
+		}
+	}
+
+	return domain.NewEntry(fields)
+}
+
+func setField(fields map[string]interface{}, key, val string) {
+	if val != "" {
+		fields[key] = val
+	}
+}
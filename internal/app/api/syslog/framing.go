@@ -0,0 +1,64 @@
+package syslog
+
+import (
+	"bytes"
+	"errors"
+	"strconv"
+)
+
+const _maxFrameLenDigits = 10
+
+var ErrInvalidFrame = errors.New("invalid frame")
+
+// newSplitFunc returns split function for syslog over TCP stream. Octet-counting framing (RFC 6587)
+// is used for frames starting with a digit, other frames are delimited by a newline.
+func newSplitFunc(maxSize int) func(data []byte, atEOF bool) (int, []byte, error) {
+	return func(data []byte, atEOF bool) (int, []byte, error) {
+		if len(data) == 0 {
+			return 0, nil, nil
+		}
+
+		if data[0] >= '1' && data[0] <= '9' {
+			return splitOctetCounting(data, atEOF, maxSize)
+		}
+
+		if idx := bytes.IndexByte(data, '\n'); idx >= 0 {
+			return idx + 1, bytes.TrimSuffix(data[:idx], []byte{'\r'}), nil
+		}
+
+		if atEOF {
+			return len(data), data, nil
+		}
+
+		return 0, nil, nil
+	}
+}
+
+// splitOctetCounting reads frame in the "MSG-LEN SP SYSLOG-MSG" format.
+func splitOctetCounting(data []byte, atEOF bool, maxSize int) (int, []byte, error) {
+	idx := bytes.IndexByte(data, ' ')
+	if idx < 0 {
+		if atEOF || len(data) > _maxFrameLenDigits {
+			return 0, nil, ErrInvalidFrame
+		}
+
+		return 0, nil, nil
+	}
+
+	size, err := strconv.Atoi(string(data[:idx]))
+	if err != nil || size > maxSize {
+		return 0, nil, ErrInvalidFrame
+	}
+
+	end := idx + 1 + size
+
+	if len(data) < end {
+		if atEOF {
+			return 0, nil, ErrInvalidFrame
+		}
+
+		return 0, nil, nil
+	}
+
+	return end, data[idx+1 : end], nil
+}
@@ -0,0 +1,269 @@
+package syslog
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	_bom            = "\ufeff"
+	_nilValue       = "-"
+	_defaultPri     = 13 // user.notice
+	_maxPri         = 191
+	_maxTagLen      = 48
+	_rfc3164TimeLen = len(time.Stamp)
+)
+
+var (
+	ErrEmptyMessage  = errors.New("empty message")
+	ErrInvalidPri    = errors.New("invalid priority")
+	ErrInvalidHeader = errors.New("invalid header")
+	ErrInvalidSD     = errors.New("invalid structured data")
+)
+
+// Message is a parsed syslog message.
+type Message struct {
+	Facility       int
+	Severity       int
+	Timestamp      time.Time
+	Hostname       string
+	AppName        string
+	ProcID         string
+	MsgID          string
+	StructuredData map[string]map[string]string
+	Message        string
+}
+
+// Parse parses RFC 5424 message or BSD RFC 3164 message if the version is missing.
+func Parse(data []byte, now time.Time) (*Message, error) {
+	data = bytes.TrimRight(data, "\r\n\x00")
+
+	if len(bytes.TrimSpace(data)) == 0 {
+		return nil, ErrEmptyMessage
+	}
+
+	pri, rest, err := parsePri(data)
+	if err != nil {
+		return nil, err
+	}
+
+	msg := &Message{Facility: pri / 8, Severity: pri % 8} //nolint:gomnd // pri = facility * 8 + severity
+
+	if len(rest) > 1 && rest[0] == '1' && rest[1] == ' ' {
+		if err := msg.parseRFC5424(string(rest[2:])); err != nil {
+			return nil, err
+		}
+	} else {
+		msg.parseRFC3164(string(rest), now)
+	}
+
+	return msg, nil
+}
+
+func parsePri(data []byte) (pri int, rest []byte, err error) {
+	if data[0] != '<' {
+		return _defaultPri, data, nil
+	}
+
+	end := bytes.IndexByte(data, '>')
+	if end < 2 || end > 4 { //nolint:gomnd // one to three digits
+		return 0, nil, ErrInvalidPri
+	}
+
+	pri, err = strconv.Atoi(string(data[1:end]))
+	if err != nil || pri < 0 || pri > _maxPri {
+		return 0, nil, ErrInvalidPri
+	}
+
+	return pri, data[end+1:], nil
+}
+
+// parseRFC5424 parses message after version:
+// TIMESTAMP SP HOSTNAME SP APP-NAME SP PROCID SP MSGID SP STRUCTURED-DATA [SP MSG].
+func (m *Message) parseRFC5424(data string) (err error) {
+	const headerFields = 5
+
+	fields := make([]string, 0, headerFields)
+
+	for i := 0; i < headerFields; i++ {
+		idx := strings.IndexByte(data, ' ')
+		if idx <= 0 {
+			return fmt.Errorf("%w: expected %d header fields", ErrInvalidHeader, headerFields)
+		}
+
+		fields, data = append(fields, nilString(data[:idx])), data[idx+1:]
+	}
+
+	if fields[0] != "" {
+		if m.Timestamp, err = time.Parse(time.RFC3339Nano, fields[0]); err != nil {
+			return fmt.Errorf("%w: timestamp: %v", ErrInvalidHeader, err)
+		}
+	}
+
+	m.Hostname, m.AppName, m.ProcID, m.MsgID = fields[1], fields[2], fields[3], fields[4]
+
+	if data, err = m.parseStructuredData(data); err != nil {
+		return err
+	}
+
+	m.Message = strings.TrimPrefix(strings.TrimPrefix(data, " "), _bom)
+
+	return nil
+}
+
+// parseStructuredData parses "-" or [SD-ID *(SP PARAM-NAME="PARAM-VALUE")] elements and returns the rest of data.
+func (m *Message) parseStructuredData(data string) (string, error) {
+	if strings.HasPrefix(data, _nilValue) {
+		return data[1:], nil
+	}
+
+	for strings.HasPrefix(data, "[") {
+		end := strings.IndexAny(data, " ]")
+		if end < 0 {
+			return "", ErrInvalidSD
+		}
+
+		var (
+			id     = data[1:end]
+			params = make(map[string]string)
+		)
+
+		data = data[end:]
+
+		for strings.HasPrefix(data, " ") {
+			eq := strings.Index(data, `="`)
+			if eq < 0 {
+				return "", ErrInvalidSD
+			}
+
+			name := data[1:eq]
+
+			value, rest, err := parseSDValue(data[eq+2:])
+			if err != nil {
+				return "", err
+			}
+
+			params[name], data = value, rest
+		}
+
+		if !strings.HasPrefix(data, "]") {
+			return "", ErrInvalidSD
+		}
+
+		if m.StructuredData == nil {
+			m.StructuredData = make(map[string]map[string]string)
+		}
+
+		m.StructuredData[id], data = params, data[1:]
+	}
+
+	if data != "" && data[0] != ' ' {
+		return "", ErrInvalidSD
+	}
+
+	return data, nil
+}
+
+// parseSDValue reads param value until the closing quote, '"', '\' and ']' may be escaped.
+func parseSDValue(data string) (value, rest string, err error) {
+	var builder strings.Builder
+
+	for i := 0; i < len(data); i++ {
+		switch data[i] {
+		case '\\':
+			if i+1 < len(data) && strings.IndexByte(`"\]`, data[i+1]) >= 0 {
+				i++
+			}
+
+			builder.WriteByte(data[i])
+		case '"':
+			return builder.String(), data[i+1:], nil
+		default:
+			builder.WriteByte(data[i])
+		}
+	}
+
+	return "", "", ErrInvalidSD
+}
+
+// parseRFC3164 parses message after priority: TIMESTAMP SP HOSTNAME SP TAG[PID]: MSG.
+// Parts that do not match the format are treated as the message.
+func (m *Message) parseRFC3164(data string, now time.Time) {
+	data = m.parseRFC3164Timestamp(data, now)
+
+	if m.Timestamp.IsZero() {
+		m.Timestamp = now
+	}
+
+	// Hostname is the first word if it is not followed by a tag.
+	if idx := strings.IndexByte(data, ' '); idx > 0 && !isTag(data[:idx]) {
+		m.Hostname, data = data[:idx], data[idx+1:]
+	}
+
+	if idx := strings.IndexByte(data, ':'); idx > 0 && idx <= _maxTagLen && isTag(data[:idx+1]) {
+		tag := data[:idx]
+
+		if open := strings.IndexByte(tag, '['); open > 0 && strings.HasSuffix(tag, "]") {
+			m.ProcID, tag = tag[open+1:len(tag)-1], tag[:open]
+		}
+
+		m.AppName, data = tag, strings.TrimPrefix(data[idx+1:], " ")
+	}
+
+	m.Message = data
+}
+
+func (m *Message) parseRFC3164Timestamp(data string, now time.Time) string {
+	if len(data) > _rfc3164TimeLen && data[_rfc3164TimeLen] == ' ' {
+		if ts, err := time.ParseInLocation(time.Stamp, data[:_rfc3164TimeLen], now.Location()); err == nil {
+			m.Timestamp = ts.AddDate(now.Year(), 0, 0)
+
+			// Message from the end of the previous year.
+			if m.Timestamp.After(now.AddDate(0, 0, 1)) {
+				m.Timestamp = m.Timestamp.AddDate(-1, 0, 0)
+			}
+
+			return data[_rfc3164TimeLen+1:]
+		}
+	}
+
+	if idx := strings.IndexByte(data, ' '); idx > 0 {
+		if ts, err := time.Parse(time.RFC3339Nano, data[:idx]); err == nil {
+			m.Timestamp = ts
+
+			return data[idx+1:]
+		}
+	}
+
+	return data
+}
+
+// isTag reports whether word is a tag like "app:", "app[123]:".
+func isTag(word string) bool {
+	if !strings.HasSuffix(word, ":") {
+		return false
+	}
+
+	for _, r := range strings.TrimSuffix(word, ":") {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case strings.ContainsRune("-_./[]", r):
+		default:
+			return false
+		}
+	}
+
+	return true
+}
+
+func nilString(s string) string {
+	if s == _nilValue {
+		return ""
+	}
+
+	return s
+}
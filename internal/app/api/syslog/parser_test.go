@@ -0,0 +1,162 @@
+package syslog
+
+import (
+	"bufio"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	now := time.Date(2021, time.January, 10, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name        string
+		data        string
+		wantErr     bool
+		expectedRes *Message
+	}{
+		{
+			name: "RFC5424Pass",
+			data: `<165>1 2003-10-11T22:14:15.003Z mymachine.example.com evntslog - ID47 ` +
+				`[exampleSDID@32473 iut="3" eventSource="Appli\"cation"][origin ip="192.0.2.1"] ` + _bom + `An application event`,
+			expectedRes: &Message{
+				Facility:  20,
+				Severity:  5,
+				Timestamp: time.Date(2003, time.October, 11, 22, 14, 15, 3000000, time.UTC),
+				Hostname:  "mymachine.example.com",
+				AppName:   "evntslog",
+				MsgID:     "ID47",
+				StructuredData: map[string]map[string]string{
+					"exampleSDID@32473": {"iut": "3", "eventSource": `Appli"cation`},
+					"origin":            {"ip": "192.0.2.1"},
+				},
+				Message: "An application event",
+			},
+		},
+		{
+			name: "RFC5424NilValuesPass",
+			data: `<34>1 - - su 123 - -`,
+			expectedRes: &Message{
+				Facility: 4,
+				Severity: 2,
+				AppName:  "su",
+				ProcID:   "123",
+			},
+		},
+		{
+			name: "RFC3164Pass",
+			data: `<34>Oct  1 22:14:15 mymachine su[230]: 'su root' failed for lonvick on /dev/pts/8`,
+			expectedRes: &Message{
+				Facility:  4,
+				Severity:  2,
+				Timestamp: time.Date(2020, time.October, 1, 22, 14, 15, 0, time.UTC),
+				Hostname:  "mymachine",
+				AppName:   "su",
+				ProcID:    "230",
+				Message:   "'su root' failed for lonvick on /dev/pts/8",
+			},
+		},
+		{
+			name: "RFC3164NoHostnamePass",
+			data: `<13>Jan 10 11:00:00 app: hello world`,
+			expectedRes: &Message{
+				Facility:  1,
+				Severity:  5,
+				Timestamp: time.Date(2021, time.January, 10, 11, 0, 0, 0, time.UTC),
+				AppName:   "app",
+				Message:   "hello world",
+			},
+		},
+		{
+			name: "NoPriorityPass",
+			data: "myhost plain text\n",
+			expectedRes: &Message{
+				Facility:  1,
+				Severity:  5,
+				Timestamp: now,
+				Hostname:  "myhost",
+				Message:   "plain text",
+			},
+		},
+		{
+			name:    "InvalidPriorityError",
+			data:    `<192>1 - - - - - -`,
+			wantErr: true,
+		},
+		{
+			name:    "InvalidStructuredDataError",
+			data:    `<34>1 - - - - - [id a="b"`,
+			wantErr: true,
+		},
+		{
+			name:    "EmptyError",
+			data:    "\r\n",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg, err := Parse([]byte(tt.data), now)
+			if (err != nil) != tt.wantErr {
+				t.Error(err)
+			}
+
+			if !tt.wantErr {
+				assert.Equal(t, tt.expectedRes, msg)
+			}
+		})
+	}
+}
+
+func TestSplitFunc(t *testing.T) {
+	tests := []struct {
+		name        string
+		data        string
+		wantErr     bool
+		expectedRes []string
+	}{
+		{
+			name:        "NewlinePass",
+			data:        "<13>a\r\n<13>b\n<13>c",
+			expectedRes: []string{"<13>a", "<13>b", "<13>c"},
+		},
+		{
+			name:        "OctetCountingPass",
+			data:        "7 <13>a\nb5 <13>c",
+			expectedRes: []string{"<13>a\nb", "<13>c"},
+		},
+		{
+			name:        "TruncatedError",
+			data:        "10 <13>a",
+			wantErr:     true,
+			expectedRes: []string{},
+		},
+		{
+			name:        "TooLargeError",
+			data:        "1000 <13>a",
+			wantErr:     true,
+			expectedRes: []string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scanner := bufio.NewScanner(strings.NewReader(tt.data))
+			scanner.Split(newSplitFunc(100))
+
+			frames := make([]string, 0)
+
+			for scanner.Scan() {
+				frames = append(frames, scanner.Text())
+			}
+
+			if (scanner.Err() != nil) != tt.wantErr {
+				t.Error(scanner.Err())
+			}
+
+			assert.Equal(t, tt.expectedRes, frames)
+		})
+	}
+}
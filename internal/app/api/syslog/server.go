@@ -0,0 +1,116 @@
+package syslog
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"time"
+
+	"github.com/loghole/tracing/tracelog"
+
+	"github.com/loghole/collector/internal/app/domain"
+	"github.com/loghole/collector/pkg/server"
+)
+
+type EntryService interface {
+	StoreEntryList(ctx context.Context, remoteIP string, list domain.EntryList) (err error)
+}
+
+// Config of syslog listeners, listener is disabled if its address is empty.
+type Config struct {
+	UDPAddr        string
+	TCPAddr        string
+	TLSAddr        string
+	TLSCertFile    string
+	TLSKeyFile     string
+	MaxMessageSize int
+	IdleTimeout    time.Duration
+}
+
+// Server receives syslog messages over UDP, TCP and TLS.
+type Server struct {
+	*server.Stream
+
+	config  *Config
+	service EntryService
+	logger  tracelog.Logger
+}
+
+func NewServer(config *Config, service EntryService, logger tracelog.Logger) *Server {
+	s := &Server{
+		config:  config,
+		service: service,
+		logger:  logger,
+	}
+
+	s.Stream = server.NewStream(&server.StreamConfig{
+		UDPAddr:     config.UDPAddr,
+		TCPAddr:     config.TCPAddr,
+		TLSAddr:     config.TLSAddr,
+		TLSCertFile: config.TLSCertFile,
+		TLSKeyFile:  config.TLSKeyFile,
+	}, s)
+
+	return s
+}
+
+// ServePacket handles message received over UDP.
+func (s *Server) ServePacket(addr net.Addr, data []byte) {
+	s.handle(server.RemoteIP(addr), data)
+}
+
+// ServeConn reads messages from TCP stream with octet-counting or newline framing.
+func (s *Server) ServeConn(conn net.Conn) {
+	var (
+		remoteIP = server.RemoteIP(conn.RemoteAddr())
+		scanner  = bufio.NewScanner(conn)
+	)
+
+	scanner.Buffer(make([]byte, 0, bufio.MaxScanTokenSize), s.config.MaxMessageSize+_maxFrameLenDigits+1)
+	scanner.Split(newSplitFunc(s.config.MaxMessageSize))
+
+	for {
+		if s.config.IdleTimeout > 0 {
+			_ = conn.SetReadDeadline(time.Now().Add(s.config.IdleTimeout))
+		}
+
+		if !scanner.Scan() {
+			break
+		}
+
+		if len(scanner.Bytes()) > 0 {
+			s.handle(remoteIP, scanner.Bytes())
+		}
+	}
+
+	var netErr net.Error
+
+	if err := scanner.Err(); err != nil && !s.IsClosed() && !(errors.As(err, &netErr) && netErr.Timeout()) {
+		s.logger.Errorf(context.Background(), "read syslog stream from %s failed: %v", remoteIP, err)
+	}
+}
+
+func (s *Server) handle(remoteIP string, data []byte) {
+	ctx := context.Background()
+
+	msg, err := Parse(data, time.Now())
+	if err != nil {
+		if !errors.Is(err, ErrEmptyMessage) {
+			s.logger.Errorf(ctx, "parse syslog message from %s failed: %v", remoteIP, err)
+		}
+
+		return
+	}
+
+	entry, err := msg.Entry()
+	if err != nil {
+		s.logger.Errorf(ctx, "convert syslog message failed: %v", err)
+
+		return
+	}
+
+	if err := s.service.StoreEntryList(ctx, remoteIP, domain.EntryList{entry}); err != nil {
+		s.logger.Errorf(ctx, "store entry list failed: %v", err)
+	}
+}
@@ -2,6 +2,8 @@ package codes
 
 import (
 	"net/http"
+
+	"github.com/lissteron/simplerr"
 )
 
 const (
@@ -12,19 +14,51 @@ const (
 const (
 	DatabaseError = system + iota
 	SystemError
+	QueueFullError
 )
 
 const (
 	UnmarshalError = internal + iota
+	ValidationError
 )
 
 func ToHTTP(code int) int {
 	switch code {
 	case DatabaseError, SystemError:
 		return http.StatusInternalServerError
-	case UnmarshalError:
+	case QueueFullError:
+		return http.StatusServiceUnavailable
+	case UnmarshalError, ValidationError:
 		return http.StatusBadRequest
 	default:
 		return http.StatusTeapot
 	}
 }
+
+// grpc codes from google.golang.org/grpc/codes.
+const (
+	grpcInvalidArgument = 3
+	grpcUnavailable     = 14
+)
+
+type unavailableCode int
+
+func (c unavailableCode) HTTP() int { return http.StatusServiceUnavailable }
+func (c unavailableCode) GRPC() int { return grpcUnavailable }
+func (c unavailableCode) Int() int  { return int(c) }
+
+// UnavailableCode is a code of temporary overload, clients should retry the request later.
+func UnavailableCode(c int) simplerr.ErrCode {
+	return unavailableCode(c)
+}
+
+type tooLargeCode int
+
+func (c tooLargeCode) HTTP() int { return http.StatusRequestEntityTooLarge }
+func (c tooLargeCode) GRPC() int { return grpcInvalidArgument }
+func (c tooLargeCode) Int() int  { return int(c) }
+
+// TooLargeCode is a code of request that can't be accepted because of its size, it must not be retried.
+func TooLargeCode(c int) simplerr.ErrCode {
+	return tooLargeCode(c)
+}
@@ -0,0 +1,65 @@
+package domain
+
+import (
+	"sync"
+)
+
+// Commit tracks writing of a group of entries to the storage. Callback is called once
+// after the commit was closed and every attached entry was written or failed.
+type Commit struct {
+	mu       sync.Mutex
+	pending  int
+	closed   bool
+	called   bool
+	err      error
+	callback func(err error)
+}
+
+func NewCommit(callback func(err error)) *Commit {
+	return &Commit{callback: callback}
+}
+
+// Attach adds entries to the commit.
+func (c *Commit) Attach(list EntryList) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.pending += len(list)
+
+	for _, entry := range list {
+		entry.commit = c
+	}
+}
+
+// Close marks that no more entries will be attached, err fails the whole commit.
+func (c *Commit) Close(err error) {
+	c.finish(err, func() { c.closed = true })
+}
+
+// Done notifies commit about writing of one entry.
+func (c *Commit) Done(err error) {
+	c.finish(err, func() { c.pending-- })
+}
+
+func (c *Commit) finish(err error, update func()) {
+	c.mu.Lock()
+
+	if c.err == nil {
+		c.err = err
+	}
+
+	update()
+
+	done := c.closed && c.pending == 0 && !c.called
+	if done {
+		c.called = true
+	}
+
+	err = c.err
+
+	c.mu.Unlock()
+
+	if done {
+		c.callback(err)
+	}
+}
@@ -0,0 +1,86 @@
+package domain
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCommit(t *testing.T) {
+	errInsert := errors.New("insert failed")
+
+	tests := []struct {
+		name        string
+		entries     int
+		results     []error
+		closeErr    error
+		expectedErr error
+		wantCalled  bool
+	}{
+		{
+			name:       "EmptyPass",
+			wantCalled: true,
+		},
+		{
+			name:       "AllCommittedPass",
+			entries:    3,
+			results:    []error{nil, nil, nil},
+			wantCalled: true,
+		},
+		{
+			name:    "PendingPass",
+			entries: 3,
+			results: []error{nil, nil},
+		},
+		{
+			name:        "EntryError",
+			entries:     2,
+			results:     []error{errInsert, nil},
+			expectedErr: errInsert,
+			wantCalled:  true,
+		},
+		{
+			name:        "CloseError",
+			entries:     1,
+			results:     []error{nil},
+			closeErr:    errInsert,
+			expectedErr: errInsert,
+			wantCalled:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				calls int
+				err   error
+				list  = make(EntryList, tt.entries)
+			)
+
+			for i := range list {
+				list[i] = &Entry{}
+			}
+
+			commit := NewCommit(func(e error) {
+				calls++
+				err = e
+			})
+
+			commit.Attach(list)
+			commit.Close(tt.closeErr)
+
+			for i, result := range tt.results {
+				list[i].Committed(result)
+			}
+
+			if !tt.wantCalled {
+				assert.Equal(t, 0, calls)
+
+				return
+			}
+
+			assert.Equal(t, 1, calls)
+			assert.Equal(t, tt.expectedErr, err)
+		})
+	}
+}
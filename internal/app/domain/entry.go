@@ -2,6 +2,7 @@ package domain
 
 import (
 	"encoding/json"
+	"errors"
 	"log"
 	"strconv"
 	"strings"
@@ -10,6 +11,8 @@ import (
 	"github.com/buger/jsonparser"
 )
 
+var ErrObjectExpected = errors.New("json object expected")
+
 type EntryList []*Entry
 
 func (e *EntryList) UnmarshalJSON(data []byte) (err error) {
@@ -20,6 +23,40 @@ func (e *EntryList) UnmarshalJSON(data []byte) (err error) {
 	return err
 }
 
+// EachEntry parses elements of json array, fn is called with the index and the entry or the parse error
+// of every element.
+func EachEntry(data []byte, fn func(index int, entry *Entry, err error)) error {
+	index := 0
+
+	_, err := jsonparser.ArrayEach(data, func(value []byte, dataType jsonparser.ValueType, _ int, err error) {
+		defer func() { index++ }()
+
+		if err != nil {
+			fn(index, nil, err)
+
+			return
+		}
+
+		if dataType != jsonparser.Object {
+			fn(index, nil, ErrObjectExpected)
+
+			return
+		}
+
+		entry := &Entry{}
+
+		if err := entry.UnmarshalJSON(value); err != nil {
+			fn(index, nil, err)
+
+			return
+		}
+
+		fn(index, entry, nil)
+	})
+
+	return err
+}
+
 func (e EntryList) SetRemoteIP(remoteIP string) {
 	for _, entry := range e {
 		entry.SetRemoteIP(remoteIP)
@@ -58,6 +95,24 @@ type Entry struct {
 	StringVal   []string
 	FloatKey    []string
 	FloatVal    []float64
+
+	commit *Commit
+}
+
+// NewEntry builds entry from fields the same way as it was received in json object.
+func NewEntry(fields map[string]interface{}) (*Entry, error) {
+	data, err := json.Marshal(fields)
+	if err != nil {
+		return nil, err
+	}
+
+	entry := &Entry{}
+
+	if err := entry.UnmarshalJSON(data); err != nil {
+		return nil, err
+	}
+
+	return entry, nil
 }
 
 func (e *Entry) UnmarshalJSON(data []byte) (err error) {
@@ -70,6 +125,43 @@ func (e *Entry) SetRemoteIP(remoteIP string) {
 	e.RemoteIP = remoteIP
 }
 
+// Size returns approximate size of the entry row.
+func (e *Entry) Size() int {
+	const fixedSize = 32 // time, date, nsec and row id columns.
+
+	size := fixedSize + len(e.Namespace) + len(e.Source) + len(e.Host) + len(e.Level) + len(e.TraceID) +
+		len(e.Message) + len(e.BuildCommit) + len(e.ConfigHash) + len(e.RemoteIP) + len(e.Params) + 8*len(e.FloatVal)
+
+	for _, key := range e.StringKey {
+		size += len(key)
+	}
+
+	for _, val := range e.StringVal {
+		size += len(val)
+	}
+
+	for _, key := range e.FloatKey {
+		size += len(key)
+	}
+
+	return size
+}
+
+// Copy returns a copy of the entry without its commit, fields are shared with the entry.
+func (e *Entry) Copy() *Entry {
+	entry := *e
+	entry.commit = nil
+
+	return &entry
+}
+
+// Committed notifies entry commit about write result.
+func (e *Entry) Committed(err error) {
+	if e.commit != nil {
+		e.commit.Done(err)
+	}
+}
+
 // nolint:cyclop // fix it late
 func (e *Entry) parseRootObject(key, value []byte, dataType jsonparser.ValueType, offset int) (err error) {
 	switch string(key) {
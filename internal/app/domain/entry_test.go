@@ -163,3 +163,80 @@ func BenchmarkEntry_UnmarshalJSON(b *testing.B) {
 		}
 	}
 }
+
+func TestEachEntry(t *testing.T) {
+	tests := []struct {
+		name             string
+		data             []byte
+		wantErr          bool
+		expectedMessages map[int]string
+		expectedErrors   []int
+	}{
+		{
+			name:             "ListPass",
+			data:             []byte(`[{"message":"a"},{"message":"b"}]`),
+			expectedMessages: map[int]string{0: "a", 1: "b"},
+			expectedErrors:   []int{},
+		},
+		{
+			name:             "InvalidElementsPass",
+			data:             []byte(`[{"message":"a"},"text",{"time":1},{"message":"b"}]`),
+			expectedMessages: map[int]string{0: "a", 3: "b"},
+			expectedErrors:   []int{1, 2},
+		},
+		{
+			name:    "NotArrayError",
+			data:    []byte(`{"message":"a"}`),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				messages = make(map[int]string)
+				errs     = make([]int, 0)
+			)
+
+			err := EachEntry(tt.data, func(index int, entry *Entry, err error) {
+				if err != nil {
+					errs = append(errs, index)
+
+					return
+				}
+
+				messages[index] = entry.Message
+			})
+			if (err != nil) != tt.wantErr {
+				t.Error(err)
+			}
+
+			if !tt.wantErr {
+				assert.Equal(t, tt.expectedMessages, messages)
+				assert.Equal(t, tt.expectedErrors, errs)
+			}
+		})
+	}
+}
+
+func TestEntry_MarshalRecord(t *testing.T) {
+	entry := &Entry{}
+
+	if err := entry.UnmarshalJSON([]byte(`{"message":"Some Text","level":"info","user":{"id":15,"name":"a"}}`)); err != nil {
+		t.Fatal(err)
+	}
+
+	entry.SetRemoteIP("127.0.0.1")
+	entry.Time = time.Date(2021, 7, 6, 12, 0, 0, 123456789, time.UTC)
+
+	data, err := entry.MarshalRecord()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := UnmarshalRecord(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, entry, result)
+}
@@ -0,0 +1,15 @@
+package domain
+
+// nolint:gochecknoglobals // lookup table
+var _syslogLevels = [...]string{
+	"emergency", "alert", "critical", "error", "warning", "notice", "info", "debug",
+}
+
+// SyslogLevel returns level name of the syslog severity, it is empty for unknown severity.
+func SyslogLevel(severity int) string {
+	if severity < 0 || severity >= len(_syslogLevels) {
+		return ""
+	}
+
+	return _syslogLevels[severity]
+}
@@ -0,0 +1,78 @@
+package domain
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"time"
+)
+
+var (
+	ErrQueueFull     = errors.New("writer queue is full")
+	ErrEntryDropped  = errors.New("entry dropped by writer queue overflow")
+	ErrWriterStopped = errors.New("writer is stopped")
+	// ErrListTooLarge is returned for entries that can't be queued at all, the request must not be retried.
+	ErrListTooLarge = errors.New("entry list is too large")
+	// ErrEntryRejected and ErrDeadLettered are commit errors of entries that were not written
+	// and must not be stored again: rejected by the storage as invalid or moved to the dead letter storage.
+	ErrEntryRejected = errors.New("entry rejected by storage")
+	ErrDeadLettered  = errors.New("entries were moved to dead letters")
+)
+
+// QueueFullError is returned when entries can't be queued for writing,
+// RetryAfter is a hint for clients when to repeat the request.
+type QueueFullError struct {
+	RetryAfter time.Duration
+}
+
+func (e *QueueFullError) Error() string {
+	return ErrQueueFull.Error()
+}
+
+func (e *QueueFullError) Unwrap() error {
+	return ErrQueueFull
+}
+
+// RetryAfter returns value of the Retry-After header in seconds if err is a writer queue overflow.
+func RetryAfter(err error) (string, bool) {
+	if !errors.Is(err, ErrQueueFull) {
+		return "", false
+	}
+
+	var (
+		seconds = 1
+		target  *QueueFullError
+	)
+
+	if errors.As(err, &target) && target.RetryAfter > time.Second {
+		seconds = int(math.Ceil(target.RetryAfter.Seconds()))
+	}
+
+	return strconv.Itoa(seconds), true
+}
+
+// WriterStats contains the writer queue state, overflow counters and health of the storage.
+type WriterStats struct {
+	QueueLength   int
+	QueueCapacity int
+	Rejected      uint64
+	DroppedOldest uint64
+	DroppedNewest uint64
+	// Failed counts entries that could not be written after all retries.
+	Failed  uint64
+	WALSize int64
+	// LastError of the storage is empty if the last insert succeeded.
+	LastError string
+	// Lag is time since the last successful insert while inserts fail.
+	Lag time.Duration
+	// Backends contains stats of every storage written by the fan-out.
+	Backends []BackendStats
+}
+
+// BackendStats contains stats of one storage of the fan-out, failures of optional storages
+// don't fail requests.
+type BackendStats struct {
+	Name     string
+	Optional bool
+	WriterStats
+}
@@ -0,0 +1,80 @@
+package domain
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// RecordFormatHeader marks messages with json records of entries published to Kafka.
+const (
+	RecordFormatHeader = "collector-format"
+	RecordFormat       = "record"
+)
+
+// record is the json representation of a parsed entry.
+type record struct {
+	Time        time.Time       `json:"time"`
+	Namespace   string          `json:"namespace,omitempty"`
+	Source      string          `json:"source,omitempty"`
+	Host        string          `json:"host,omitempty"`
+	Level       string          `json:"level,omitempty"`
+	TraceID     string          `json:"trace_id,omitempty"`
+	Message     string          `json:"message,omitempty"`
+	BuildCommit string          `json:"build_commit,omitempty"`
+	ConfigHash  string          `json:"config_hash,omitempty"`
+	RemoteIP    string          `json:"remote_ip,omitempty"`
+	Params      json.RawMessage `json:"params,omitempty"`
+	StringKey   []string        `json:"string_key,omitempty"`
+	StringVal   []string        `json:"string_val,omitempty"`
+	FloatKey    []string        `json:"float_key,omitempty"`
+	FloatVal    []float64       `json:"float_val,omitempty"`
+}
+
+// MarshalRecord encodes parsed entry to json, unlike the received object it is decoded back
+// by UnmarshalRecord without parsing. It is used to persist entries outside of the database.
+func (e *Entry) MarshalRecord() ([]byte, error) {
+	return json.Marshal(&record{
+		Time:        e.Time,
+		Namespace:   e.Namespace,
+		Source:      e.Source,
+		Host:        e.Host,
+		Level:       e.Level,
+		TraceID:     e.TraceID,
+		Message:     e.Message,
+		BuildCommit: e.BuildCommit,
+		ConfigHash:  e.ConfigHash,
+		RemoteIP:    e.RemoteIP,
+		Params:      e.Params,
+		StringKey:   e.StringKey,
+		StringVal:   e.StringVal,
+		FloatKey:    e.FloatKey,
+		FloatVal:    e.FloatVal,
+	})
+}
+
+// UnmarshalRecord decodes entry encoded by MarshalRecord.
+func UnmarshalRecord(data []byte) (*Entry, error) {
+	var r record
+
+	if err := json.Unmarshal(data, &r); err != nil {
+		return nil, err
+	}
+
+	return &Entry{
+		Time:        r.Time,
+		Namespace:   r.Namespace,
+		Source:      r.Source,
+		Host:        r.Host,
+		Level:       r.Level,
+		TraceID:     r.TraceID,
+		Message:     r.Message,
+		BuildCommit: r.BuildCommit,
+		ConfigHash:  r.ConfigHash,
+		RemoteIP:    r.RemoteIP,
+		Params:      r.Params,
+		StringKey:   r.StringKey,
+		StringVal:   r.StringVal,
+		FloatKey:    r.FloatKey,
+		FloatVal:    r.FloatVal,
+	}, nil
+}
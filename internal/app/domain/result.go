@@ -0,0 +1,24 @@
+package domain
+
+// StoreResult describes entries received in one request: how many were accepted and why others were rejected.
+type StoreResult struct {
+	Accepted int
+	Rejected int
+	Failures []EntryFailure
+}
+
+// EntryFailure is a rejected entry, number is a line number or an index of the entry in the request.
+type EntryFailure struct {
+	Number int
+	Code   int
+	Error  string
+}
+
+// Reject adds failure of the entry, only first limit failures are kept if limit is positive.
+func (r *StoreResult) Reject(number, code int, err error, limit int) {
+	r.Rejected++
+
+	if limit <= 0 || len(r.Failures) < limit {
+		r.Failures = append(r.Failures, EntryFailure{Number: number, Code: code, Error: err.Error()})
+	}
+}
@@ -2,10 +2,14 @@ package clickhouse
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"math/rand"
+	"sync"
 	"time"
 
+	"github.com/ClickHouse/clickhouse-go"
+	"github.com/ClickHouse/clickhouse-go/lib/column"
 	"github.com/jmoiron/sqlx"
 	"github.com/loghole/database"
 	"github.com/loghole/gorand"
@@ -13,6 +17,7 @@ import (
 	"github.com/loghole/tracing/tracelog"
 
 	"github.com/loghole/collector/internal/app/domain"
+	"github.com/loghole/collector/internal/app/repositories/writer"
 )
 
 const (
@@ -38,28 +43,39 @@ const (
 		VALUES (?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?)`
 )
 
+// ClickHouse error codes of invalid rows, inserts that failed with them are not retried.
+// https://github.com/ClickHouse/ClickHouse/blob/master/src/Common/ErrorCodes.cpp
+// nolint:gochecknoglobals // constant set
+var _rowErrorCodes = map[int32]struct{}{
+	6:   {}, // CANNOT_PARSE_TEXT
+	26:  {}, // CANNOT_PARSE_QUOTED_STRING
+	27:  {}, // CANNOT_PARSE_INPUT_ASSERTION_FAILED
+	38:  {}, // CANNOT_PARSE_DATE
+	41:  {}, // CANNOT_PARSE_DATETIME
+	53:  {}, // TYPE_MISMATCH
+	69:  {}, // ARGUMENT_OUT_OF_BOUND
+	70:  {}, // CANNOT_CONVERT_TYPE
+	72:  {}, // CANNOT_PARSE_NUMBER
+	117: {}, // INCORRECT_DATA
+	131: {}, // TOO_LARGE_STRING_SIZE
+	190: {}, // SIZES_OF_ARRAYS_DOESNT_MATCH
+	321: {}, // VALUE_IS_OUT_OF_RANGE_OF_DATA_TYPE
+	349: {}, // CANNOT_INSERT_NULL_IN_ORDINARY_COLUMN
+}
+
+// EntryRepository is the ClickHouse storage backend of the entry writer.
 type EntryRepository struct {
 	db     *database.DB
 	logger tracelog.Logger
 
-	period time.Duration
-	queue  chan *domain.Entry
-
-	rand rand.Source64
+	rand *lockedSource
 }
 
-func NewEntryRepository(
-	db *database.DB,
-	logger tracelog.Logger,
-	capacity int,
-	period time.Duration,
-) *EntryRepository {
+func NewEntryRepository(db *database.DB, logger tracelog.Logger) *EntryRepository {
 	return &EntryRepository{
 		db:     db,
 		logger: logger,
-		period: period,
-		queue:  make(chan *domain.Entry, capacity),
-		rand:   rand.Source64(rand.New(gorand.NewSource(time.Now().UnixNano()))), //nolint:gosec // need pseudo random
+		rand:   &lockedSource{src: rand.New(gorand.NewSource(time.Now().UnixNano()))}, //nolint:gosec // need pseudo random
 	}
 }
 
@@ -69,67 +85,16 @@ func (r *EntryRepository) Ping(ctx context.Context) error {
 	return r.db.PingContext(ctx)
 }
 
-func (r *EntryRepository) Run(ctx context.Context) error {
-	return r.storeEntryChan(ctx)
+func (r *EntryRepository) Close() error {
+	return r.db.Close()
 }
 
-func (r *EntryRepository) Stop() {
-	close(r.queue)
-}
-
-func (r *EntryRepository) StoreEntryList(ctx context.Context, list []*domain.Entry) (err error) {
+// InsertEntryList writes entries in a single transaction, the batch fails with writer.RowError
+// if ClickHouse rejected invalid values.
+func (r *EntryRepository) InsertEntryList(ctx context.Context, cache []*domain.Entry) error {
 	defer tracing.ChildSpan(&ctx).Finish()
 
-	for _, entry := range list {
-		r.queue <- entry
-	}
-
-	return nil
-}
-
-func (r *EntryRepository) storeEntryChan(ctx context.Context) error {
-	var (
-		entry  *domain.Entry
-		active = true
-		ticker = time.NewTicker(r.period)
-
-		cache = make([]*domain.Entry, 0)
-	)
-
-	defer ticker.Stop()
-
-	for active {
-		select {
-		case <-ticker.C:
-			if len(cache) == 0 {
-				continue
-			}
-
-			if err := r.insertEntryList(ctx, cache); err != nil {
-				r.logger.Errorf(ctx, "insert entry list: %v", err)
-			}
-
-			cache = make([]*domain.Entry, 0, len(cache))
-		case entry, active = <-r.queue:
-			if !active {
-				break
-			}
-
-			cache = append(cache, entry)
-		}
-	}
-
-	if len(cache) > 0 {
-		if err := r.insertEntryList(ctx, cache); err != nil {
-			r.logger.Errorf(ctx, "insert entry list: %v", err)
-		}
-	}
-
-	return nil
-}
-
-func (r *EntryRepository) insertEntryList(ctx context.Context, cache []*domain.Entry) error {
-	err := r.db.RunTxx(ctx, func(ctx context.Context, tx *sqlx.Tx) error {
+	return insertError(r.db.RunTxx(ctx, func(ctx context.Context, tx *sqlx.Tx) error {
 		stmt, err := tx.Prepare(insertLogsQuery)
 		if err != nil {
 			return fmt.Errorf("prepare stmt: %w", err)
@@ -167,10 +132,51 @@ func (r *EntryRepository) insertEntryList(ctx context.Context, cache []*domain.E
 		}
 
 		return nil
-	})
-	if err != nil {
+	}))
+}
+
+// insertError wraps errors of invalid values with writer.RowError, other errors
+// like broken connections are returned as is to be retried.
+func insertError(err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case isRowException(err):
+		return &writer.RowError{Err: fmt.Errorf("transaction: %w", err)}
+	default:
 		return fmt.Errorf("transaction: %w", err)
 	}
+}
+
+// isRowException reports whether ClickHouse or the driver rejected the insert because of invalid values.
+func isRowException(err error) bool {
+	var (
+		exception  *clickhouse.Exception
+		conversion *column.ErrUnexpectedType
+	)
+
+	if errors.As(err, &conversion) {
+		return true
+	}
+
+	if !errors.As(err, &exception) {
+		return false
+	}
+
+	_, ok := _rowErrorCodes[exception.Code]
+
+	return ok
+}
+
+// lockedSource is a random source safe for concurrent use by the workers.
+type lockedSource struct {
+	mu  sync.Mutex
+	src rand.Source64
+}
+
+func (s *lockedSource) Uint64() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-	return nil
+	return s.src.Uint64()
 }
@@ -0,0 +1,162 @@
+package clickhouse
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ClickHouse/clickhouse-go"
+	"github.com/ClickHouse/clickhouse-go/lib/column"
+	"github.com/loghole/tracing/tracelog"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+
+	"github.com/loghole/collector/internal/app/domain"
+	"github.com/loghole/collector/internal/app/repositories/writer"
+)
+
+type backendMock struct {
+	mu      sync.Mutex
+	errs    []error
+	inserts int
+}
+
+func (b *backendMock) Ping(ctx context.Context) error { return nil }
+
+func (b *backendMock) InsertEntryList(ctx context.Context, list []*domain.Entry) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.inserts++
+
+	if len(b.errs) == 0 {
+		return nil
+	}
+
+	err := b.errs[0]
+	b.errs = b.errs[1:]
+
+	return insertError(err)
+}
+
+type deadLettersMock struct {
+	mu   sync.Mutex
+	list []*domain.Entry
+}
+
+func (d *deadLettersMock) Write(list []*domain.Entry) (string, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.list = append(d.list, list...)
+
+	return "dead", nil
+}
+
+func TestIsRowException(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected bool
+	}{
+		{
+			name:     "TypeMismatch",
+			err:      fmt.Errorf("transaction: %w", &clickhouse.Exception{Code: 53}),
+			expected: true,
+		},
+		{
+			name:     "UnexpectedType",
+			err:      fmt.Errorf("insert: %w", &column.ErrUnexpectedType{T: struct{}{}}),
+			expected: true,
+		},
+		{
+			name: "TableNotExists",
+			err:  fmt.Errorf("transaction: %w", &clickhouse.Exception{Code: 60}),
+		},
+		{
+			name: "ConnectionError",
+			err:  errors.New("broken pipe"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, isRowException(tt.err))
+		})
+	}
+}
+
+func TestInsertError(t *testing.T) {
+	tests := []struct {
+		name        string
+		err         error
+		expectedRow bool
+	}{
+		{
+			name:        "TypeMismatch",
+			err:         fmt.Errorf("insert: %w", &clickhouse.Exception{Code: 53}),
+			expectedRow: true,
+		},
+		{
+			name: "TransportError",
+			err:  fmt.Errorf("insert: %w", io.ErrUnexpectedEOF),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := insertError(tt.err)
+
+			assert.ErrorIs(t, err, tt.err)
+			assert.Equal(t, tt.expectedRow, errors.Is(err, domain.ErrEntryRejected))
+		})
+	}
+
+	assert.NoError(t, insertError(nil))
+}
+
+func TestEntryRepository_TransportErrorRetry(t *testing.T) {
+	var (
+		backend     = &backendMock{errs: []error{fmt.Errorf("insert: %w", io.ErrUnexpectedEOF)}}
+		deadLetters = &deadLettersMock{}
+		logger      = tracelog.NewTraceLogger(zap.NewNop().Sugar())
+		done        = make(chan error, 1)
+	)
+
+	w, err := writer.New(backend, logger, &writer.Config{
+		Capacity:       10,
+		Period:         time.Millisecond,
+		OverflowPolicy: writer.PolicyBlock,
+		Retry:          writer.RetryConfig{MaxAttempts: 3, InitialInterval: time.Millisecond, MaxInterval: time.Millisecond},
+		DeadLetters:    deadLetters,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go func() { done <- w.Run(context.Background()) }()
+
+	var (
+		committed = make(chan error, 1)
+		commit    = domain.NewCommit(func(err error) { committed <- err })
+		list      = []*domain.Entry{{Message: "first"}}
+	)
+
+	commit.Attach(list)
+	commit.Close(nil)
+
+	assert.NoError(t, w.StoreEntryList(context.Background(), list))
+	assert.NoError(t, <-committed)
+
+	w.Stop()
+
+	assert.NoError(t, <-done)
+
+	backend.mu.Lock()
+	assert.Equal(t, 2, backend.inserts)
+	backend.mu.Unlock()
+
+	assert.Empty(t, deadLetters.list)
+}
@@ -0,0 +1,125 @@
+// Package deadletter keeps entries that could not be written to the storage in ndjson files,
+// one entry record per line, to be written later by the replay command.
+package deadletter
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/loghole/collector/internal/app/domain"
+	"github.com/loghole/collector/pkg/fileutil"
+)
+
+const (
+	_fileExt    = ".ndjson"
+	_tmpExt     = ".tmp"
+	_timeLayout = "20060102T150405.000000000"
+	_dirPerm    = 0o755
+)
+
+type Dir struct {
+	path string
+	seq  uint64
+}
+
+func NewDir(path string) (*Dir, error) {
+	if err := os.MkdirAll(path, _dirPerm); err != nil {
+		return nil, fmt.Errorf("create dir: %w", err)
+	}
+
+	return &Dir{path: path}, nil
+}
+
+// Write stores entries to a new file, the file appears in the directory after it was written completely.
+func (d *Dir) Write(list []*domain.Entry) (string, error) {
+	name := fmt.Sprintf("%s-%d%s", time.Now().UTC().Format(_timeLayout), atomic.AddUint64(&d.seq, 1), _fileExt)
+	path := filepath.Join(d.path, name)
+
+	var buf bytes.Buffer
+
+	for _, entry := range list {
+		data, err := entry.MarshalRecord()
+		if err != nil {
+			return "", fmt.Errorf("marshal entry: %w", err)
+		}
+
+		buf.Write(data)
+		buf.WriteByte('\n')
+	}
+
+	if err := fileutil.WriteFile(path+_tmpExt, buf.Bytes()); err != nil {
+		return "", err
+	}
+
+	if err := os.Rename(path+_tmpExt, path); err != nil {
+		return "", err
+	}
+
+	return path, nil
+}
+
+// Files returns paths of stored files from the oldest.
+func (d *Dir) Files() ([]string, error) {
+	files, err := os.ReadDir(d.path)
+	if err != nil {
+		return nil, err
+	}
+
+	paths := make([]string, 0, len(files))
+
+	for _, file := range files {
+		if !file.IsDir() && strings.HasSuffix(file.Name(), _fileExt) {
+			paths = append(paths, filepath.Join(d.path, file.Name()))
+		}
+	}
+
+	sort.Strings(paths)
+
+	return paths, nil
+}
+
+// Read returns entries of the file.
+func Read(path string) ([]*domain.Entry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	defer file.Close()
+
+	var (
+		reader = bufio.NewReader(file)
+		list   = make([]*domain.Entry, 0)
+	)
+
+	for number := 1; ; number++ {
+		line, err := reader.ReadBytes('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return nil, err
+		}
+
+		if len(bytes.TrimSpace(line)) == 0 {
+			if err != nil {
+				return list, nil
+			}
+
+			continue
+		}
+
+		entry, err := domain.UnmarshalRecord(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", number, err)
+		}
+
+		list = append(list, entry)
+	}
+}
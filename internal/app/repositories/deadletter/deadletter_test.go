@@ -0,0 +1,46 @@
+package deadletter
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/loghole/collector/internal/app/domain"
+)
+
+func TestDir(t *testing.T) {
+	dir, err := NewDir(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	list := []*domain.Entry{
+		{Time: time.Date(2021, 7, 6, 12, 0, 0, 0, time.UTC), Message: "first", Params: []byte(`{"message":"first"}`)},
+		{Time: time.Date(2021, 7, 6, 12, 0, 1, 0, time.UTC), Message: "second", StringKey: []string{"a"}, StringVal: []string{"b"}},
+	}
+
+	first, err := dir.Write(list)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	second, err := dir.Write(list[1:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := dir.Files()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, []string{first, second}, files)
+
+	result, err := Read(first)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, list, result)
+}
@@ -0,0 +1,241 @@
+// Package fanout writes every entry to several storages. Each storage has its own writer with a queue,
+// batching and retries, so a slow or failing storage doesn't delay writing to the others.
+package fanout
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/loghole/tracing"
+	"github.com/loghole/tracing/tracelog"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/loghole/collector/internal/app/domain"
+)
+
+var ErrNoTargets = errors.New("no fan-out storages")
+
+// Writer queues entries for writing to one storage, see writer.Writer.
+type Writer interface {
+	Ping(ctx context.Context) error
+	StoreEntryList(ctx context.Context, list []*domain.Entry) error
+	Stats() domain.WriterStats
+	Run(ctx context.Context) error
+	Stop()
+}
+
+// Target is a storage of the fan-out. Failures of optional targets are logged and counted
+// in their stats, but don't fail requests and commits of entries.
+type Target struct {
+	Name     string
+	Optional bool
+	Writer   Writer
+}
+
+type Storage struct {
+	targets []*Target
+	logger  tracelog.Logger
+}
+
+func NewStorage(targets []*Target, logger tracelog.Logger) (*Storage, error) {
+	if len(targets) == 0 {
+		return nil, ErrNoTargets
+	}
+
+	return &Storage{targets: targets, logger: logger}, nil
+}
+
+// Ping checks every storage, only failures of required storages are returned.
+func (s *Storage) Ping(ctx context.Context) error {
+	defer tracing.ChildSpan(&ctx).Finish()
+
+	for _, target := range s.targets {
+		err := target.Writer.Ping(ctx)
+
+		switch {
+		case err == nil:
+		case target.Optional:
+			s.logger.Warnf(ctx, "ping optional storage %s: %v", target.Name, err)
+		default:
+			return fmt.Errorf("%s: %w", target.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// StoreEntryList queues copies of entries to every storage. The entries are committed after they were written
+// to all required storages, the first error of a required storage fails the commit and is returned.
+// Entries queued to other storages stay there, so a repeated request may write them twice.
+func (s *Storage) StoreEntryList(ctx context.Context, list []*domain.Entry) error {
+	defer tracing.ChildSpan(&ctx).Finish()
+
+	var (
+		result = newFanIn(list, s.required())
+		first  error
+	)
+
+	for _, target := range s.targets {
+		copies := make(domain.EntryList, 0, len(list))
+
+		for _, entry := range list {
+			copies = append(copies, entry.Copy())
+		}
+
+		var commit *domain.Commit
+
+		if !target.Optional {
+			commit = domain.NewCommit(result.done)
+			commit.Attach(copies)
+		}
+
+		err := target.Writer.StoreEntryList(ctx, copies)
+
+		switch {
+		case err == nil:
+		case target.Optional:
+			s.logger.Warnf(ctx, "store %d entries to optional storage %s: %v", len(list), target.Name, err)
+		case first == nil:
+			first = fmt.Errorf("%s: %w", target.Name, err)
+		}
+
+		if commit != nil {
+			commit.Close(nil)
+		}
+	}
+
+	return first
+}
+
+// Stats returns the sum of stats of all storages and the stats of every storage. Last error and lag
+// are taken from required storages.
+func (s *Storage) Stats() domain.WriterStats {
+	var stats domain.WriterStats
+
+	for _, target := range s.targets {
+		backend := domain.BackendStats{
+			Name:        target.Name,
+			Optional:    target.Optional,
+			WriterStats: target.Writer.Stats(),
+		}
+
+		stats.QueueLength += backend.QueueLength
+		stats.QueueCapacity += backend.QueueCapacity
+		stats.Rejected += backend.Rejected
+		stats.DroppedOldest += backend.DroppedOldest
+		stats.DroppedNewest += backend.DroppedNewest
+		stats.Failed += backend.Failed
+		stats.WALSize += backend.WALSize
+
+		if !target.Optional && backend.LastError != "" {
+			if stats.LastError == "" {
+				stats.LastError = target.Name + ": " + backend.LastError
+			}
+
+			if backend.Lag > stats.Lag {
+				stats.Lag = backend.Lag
+			}
+		}
+
+		stats.Backends = append(stats.Backends, backend)
+	}
+
+	return stats
+}
+
+// Run runs writers of all storages until Stop.
+func (s *Storage) Run(ctx context.Context) error {
+	var group errgroup.Group
+
+	for _, target := range s.targets {
+		target := target
+
+		group.Go(func() error {
+			if err := target.Writer.Run(ctx); err != nil {
+				return fmt.Errorf("%s: %w", target.Name, err)
+			}
+
+			return nil
+		})
+	}
+
+	return group.Wait()
+}
+
+// Stop stops writers of all storages at once and waits until they have written the queued entries.
+func (s *Storage) Stop() {
+	var wg sync.WaitGroup
+
+	for _, target := range s.targets {
+		wg.Add(1)
+
+		go func(target *Target) {
+			defer wg.Done()
+
+			target.Writer.Stop()
+		}(target)
+	}
+
+	wg.Wait()
+}
+
+func (s *Storage) required() int {
+	var count int
+
+	for _, target := range s.targets {
+		if !target.Optional {
+			count++
+		}
+	}
+
+	return count
+}
+
+// fanIn commits entries once their copies were written to every required storage.
+type fanIn struct {
+	mu      sync.Mutex
+	list    []*domain.Entry
+	pending int
+	err     error
+}
+
+func newFanIn(list []*domain.Entry, pending int) *fanIn {
+	f := &fanIn{list: list, pending: pending}
+
+	// Entries are committed at once if all storages are optional.
+	if pending == 0 {
+		f.commit(nil)
+	}
+
+	return f
+}
+
+func (f *fanIn) done(err error) {
+	f.mu.Lock()
+
+	if f.err == nil {
+		f.err = err
+	}
+
+	f.pending--
+
+	if f.pending > 0 {
+		f.mu.Unlock()
+
+		return
+	}
+
+	err = f.err
+
+	f.mu.Unlock()
+
+	f.commit(err)
+}
+
+func (f *fanIn) commit(err error) {
+	for _, entry := range f.list {
+		entry.Committed(err)
+	}
+}
@@ -0,0 +1,156 @@
+package fanout
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/loghole/tracing/tracelog"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+
+	"github.com/loghole/collector/internal/app/domain"
+)
+
+type writerMock struct {
+	err     error
+	stats   domain.WriterStats
+	entries []*domain.Entry
+}
+
+func (w *writerMock) Ping(ctx context.Context) error { return w.err }
+
+func (w *writerMock) StoreEntryList(ctx context.Context, list []*domain.Entry) error {
+	w.entries = append(w.entries, list...)
+
+	return w.err
+}
+
+func (w *writerMock) Stats() domain.WriterStats     { return w.stats }
+func (w *writerMock) Run(ctx context.Context) error { return nil }
+func (w *writerMock) Stop()                         {}
+
+// commit commits stored entries with the error.
+func (w *writerMock) commit(err error) {
+	for _, entry := range w.entries {
+		entry.Committed(err)
+	}
+}
+
+func TestStorage_StoreEntryList(t *testing.T) {
+	var (
+		errInsert  = errors.New("insert failed")
+		errArchive = errors.New("archive is down")
+	)
+
+	tests := []struct {
+		name          string
+		primaryErr    error
+		secondaryErr  error
+		archiveErr    error
+		wantErr       error
+		wantCommitErr error
+	}{
+		{
+			name: "Pass",
+		},
+		{
+			name:       "OptionalErrorPass",
+			archiveErr: errArchive,
+		},
+		{
+			name:          "CommitError",
+			secondaryErr:  errInsert,
+			wantCommitErr: errInsert,
+		},
+		{
+			name:          "StoreError",
+			primaryErr:    domain.ErrQueueFull,
+			wantErr:       domain.ErrQueueFull,
+			wantCommitErr: domain.ErrQueueFull,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				primary   = &writerMock{err: tt.primaryErr}
+				secondary = &writerMock{}
+				archive   = &writerMock{err: tt.archiveErr}
+			)
+
+			storage, err := NewStorage([]*Target{
+				{Name: "clickhouse", Writer: primary},
+				{Name: "kafka", Writer: secondary},
+				{Name: "s3", Optional: true, Writer: archive},
+			}, tracelog.NewTraceLogger(zap.NewNop().Sugar()))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var (
+				committed []error
+				commit    = domain.NewCommit(func(err error) { committed = append(committed, err) })
+				list      = domain.EntryList{{Message: "1"}, {Message: "2"}}
+			)
+
+			commit.Attach(list)
+			commit.Close(nil)
+
+			err = storage.StoreEntryList(context.Background(), list)
+			assert.ErrorIs(t, err, tt.wantErr)
+
+			for _, w := range []*writerMock{primary, secondary, archive} {
+				if assert.Len(t, w.entries, 2) {
+					assert.NotSame(t, list[0], w.entries[0])
+					assert.Equal(t, "2", w.entries[1].Message)
+				}
+			}
+
+			// The optional storage doesn't hold the commit.
+			primary.commit(tt.primaryErr)
+			assert.Empty(t, committed)
+
+			secondary.commit(tt.secondaryErr)
+			assert.Equal(t, []error{tt.wantCommitErr}, committed)
+
+			archive.commit(errArchive)
+			assert.Len(t, committed, 1)
+		})
+	}
+}
+
+func TestStorage_Stats(t *testing.T) {
+	storage, err := NewStorage([]*Target{
+		{Name: "clickhouse", Writer: &writerMock{stats: domain.WriterStats{QueueLength: 2, QueueCapacity: 10}}},
+		{Name: "s3", Optional: true, Writer: &writerMock{stats: domain.WriterStats{
+			QueueLength:   10,
+			QueueCapacity: 10,
+			DroppedNewest: 5,
+			LastError:     "connection refused",
+			Lag:           time.Minute,
+		}}},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stats := storage.Stats()
+
+	assert.Equal(t, 12, stats.QueueLength)
+	assert.Equal(t, 20, stats.QueueCapacity)
+	assert.Equal(t, uint64(5), stats.DroppedNewest)
+	assert.Empty(t, stats.LastError)
+	assert.Zero(t, stats.Lag)
+
+	if assert.Len(t, stats.Backends, 2) {
+		assert.Equal(t, "s3", stats.Backends[1].Name)
+		assert.True(t, stats.Backends[1].Optional)
+		assert.Equal(t, "connection refused", stats.Backends[1].LastError)
+	}
+}
+
+func TestNewStorage_NoTargets(t *testing.T) {
+	_, err := NewStorage(nil, nil)
+	assert.ErrorIs(t, err, ErrNoTargets)
+}
@@ -0,0 +1,527 @@
+// Package filesink is a storage backend writing entries as newline delimited json records to segment files
+// in a directory per namespace and source. Segments are rotated by size and age, closed segments are
+// compressed in background and the oldest of them are removed when the directory exceeds the retention size.
+package filesink
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/loghole/tracing/tracelog"
+
+	"github.com/loghole/collector/internal/app/domain"
+	"github.com/loghole/collector/internal/app/repositories/writer"
+	"github.com/loghole/collector/pkg/fileutil"
+)
+
+// Compression of closed segments.
+const (
+	CompressionNone = fileutil.CompressionNone
+	CompressionGzip = fileutil.CompressionGzip
+	CompressionZstd = fileutil.CompressionZstd
+)
+
+const (
+	_segmentExt    = ".ndjson"
+	_timeLayout    = "20060102T150405.000000000"
+	_checkInterval = time.Second
+	_dirPerm       = 0o755
+	_filePerm      = 0o644
+)
+
+var ErrInvalidCompression = fileutil.ErrInvalidCompression
+
+type Config struct {
+	Dir string
+	// MaxSize and MaxAge rotate the segment, zero disables the limit.
+	MaxSize int64
+	MaxAge  time.Duration
+	// Compression of closed segments, see Compression constants.
+	Compression string
+	// RetentionSize limits the size of the directory, zero disables the limit.
+	RetentionSize int64
+}
+
+type Sink struct {
+	seq uint64
+
+	config *Config
+	ext    string
+	logger tracelog.Logger
+
+	mu       sync.Mutex
+	segments map[string]*segment
+	// closed are segments waiting for compression by the rotate loop.
+	closed []string
+
+	rotated chan struct{}
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+// segment is an active file of namespace and source.
+type segment struct {
+	key    string
+	file   *os.File
+	path   string
+	size   int64
+	opened time.Time
+}
+
+// Open starts the sink, segments left by the previous run are compressed at once
+// and files of interrupted compression are removed.
+func Open(config *Config, logger tracelog.Logger) (*Sink, error) {
+	ext, err := fileutil.CompressedExt(config.Compression)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(config.Dir, _dirPerm); err != nil {
+		return nil, fmt.Errorf("create dir: %w", err)
+	}
+
+	s := &Sink{
+		config:   config,
+		ext:      ext,
+		logger:   logger,
+		segments: make(map[string]*segment),
+		rotated:  make(chan struct{}, 1),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+
+	tmp, err := s.files(func(path string) bool { return strings.HasSuffix(path, fileutil.TmpExt) })
+	if err != nil {
+		return nil, err
+	}
+
+	for _, path := range tmp {
+		if err := os.Remove(path); err != nil {
+			return nil, err
+		}
+	}
+
+	left, err := s.files(func(path string) bool { return strings.HasSuffix(path, _segmentExt) })
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.finish(left); err != nil {
+		return nil, err
+	}
+
+	if err := s.applyRetention(); err != nil {
+		return nil, err
+	}
+
+	go s.rotateLoop()
+
+	return s, nil
+}
+
+func (s *Sink) Ping(ctx context.Context) error {
+	_, err := os.Stat(s.config.Dir)
+
+	return err
+}
+
+// InsertEntryList appends entries to segments of their namespace and source, the segments are synced to disk.
+// Entries are written all or none, so a retried list is not written twice.
+func (s *Sink) InsertEntryList(ctx context.Context, list []*domain.Entry) error {
+	var (
+		keys    = make([]string, 0)
+		buffers = make(map[string]*bytes.Buffer)
+	)
+
+	for _, entry := range list {
+		data, err := entry.MarshalRecord()
+		if err != nil {
+			return &writer.RowError{Err: fmt.Errorf("marshal entry: %w", err)}
+		}
+
+		key := filepath.Join(fileutil.PathName(entry.Namespace), fileutil.PathName(entry.Source))
+
+		buf, ok := buffers[key]
+		if !ok {
+			buf = &bytes.Buffer{}
+			buffers[key] = buf
+			keys = append(keys, key)
+		}
+
+		buf.Write(data)
+		buf.WriteByte('\n')
+	}
+
+	return s.write(ctx, keys, buffers)
+}
+
+// Close closes and compresses active segments.
+func (s *Sink) Close() error {
+	close(s.stop)
+	<-s.done
+
+	s.mu.Lock()
+
+	var err error
+
+	for key, seg := range s.segments {
+		if closeErr := s.closeSegment(key, seg); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}
+
+	s.mu.Unlock()
+
+	if finishErr := s.finish(s.takeClosed()); err == nil {
+		err = finishErr
+	}
+
+	return err
+}
+
+func (s *Sink) write(ctx context.Context, keys []string, buffers map[string]*bytes.Buffer) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var (
+		now   = time.Now()
+		segs  = make([]*segment, 0, len(keys))
+		sizes = make([]int64, 0, len(keys))
+	)
+
+	for _, key := range keys {
+		seg, err := s.activeSegment(key, now)
+		if err != nil {
+			return err
+		}
+
+		segs, sizes = append(segs, seg), append(sizes, seg.size)
+	}
+
+	if err := writeSegments(segs, keys, buffers); err != nil {
+		s.rollback(ctx, segs, sizes)
+
+		return err
+	}
+
+	for _, seg := range segs {
+		if s.config.MaxSize <= 0 || seg.size < s.config.MaxSize {
+			continue
+		}
+
+		// Entries are already synced, the failed close must not fail the insert.
+		if err := s.closeSegment(seg.key, seg); err != nil {
+			s.logger.Errorf(ctx, "rotate segment: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// writeSegments writes buffers to segments and syncs them.
+func writeSegments(segs []*segment, keys []string, buffers map[string]*bytes.Buffer) error {
+	for idx, seg := range segs {
+		n, err := seg.file.Write(buffers[keys[idx]].Bytes())
+		seg.size += int64(n)
+
+		if err != nil {
+			return fmt.Errorf("write %s: %w", seg.path, err)
+		}
+	}
+
+	for _, seg := range segs {
+		if err := seg.file.Sync(); err != nil {
+			return fmt.Errorf("sync %s: %w", seg.path, err)
+		}
+	}
+
+	return nil
+}
+
+// rollback truncates segments to their sizes before the failed write. A segment that can't be
+// truncated is closed, so the next entries don't follow its torn line.
+func (s *Sink) rollback(ctx context.Context, segs []*segment, sizes []int64) {
+	for idx, seg := range segs {
+		if seg.size == sizes[idx] {
+			continue
+		}
+
+		if err := seg.file.Truncate(sizes[idx]); err != nil {
+			s.logger.Errorf(ctx, "truncate %s: %v", seg.path, err)
+
+			if err := s.closeSegment(seg.key, seg); err != nil {
+				s.logger.Errorf(ctx, "rotate segment: %v", err)
+			}
+
+			continue
+		}
+
+		seg.size = sizes[idx]
+	}
+}
+
+// activeSegment returns the segment of the key, the expired segment is replaced with a new one.
+func (s *Sink) activeSegment(key string, now time.Time) (*segment, error) {
+	seg, ok := s.segments[key]
+	if ok && !s.expired(seg, now) {
+		return seg, nil
+	}
+
+	if ok {
+		if err := s.closeSegment(key, seg); err != nil {
+			return nil, err
+		}
+	}
+
+	return s.openSegment(key)
+}
+
+func (s *Sink) expired(seg *segment, now time.Time) bool {
+	return s.config.MaxAge > 0 && now.Sub(seg.opened) >= s.config.MaxAge
+}
+
+func (s *Sink) openSegment(key string) (*segment, error) {
+	dir := filepath.Join(s.config.Dir, key)
+
+	if err := os.MkdirAll(dir, _dirPerm); err != nil {
+		return nil, fmt.Errorf("create dir: %w", err)
+	}
+
+	now := time.Now()
+	name := fmt.Sprintf("%s-%d%s", now.UTC().Format(_timeLayout), atomic.AddUint64(&s.seq, 1), _segmentExt)
+	path := filepath.Join(dir, name)
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE|os.O_EXCL, _filePerm)
+	if err != nil {
+		return nil, err
+	}
+
+	seg := &segment{key: key, file: file, path: path, opened: now}
+	s.segments[key] = seg
+
+	return seg, nil
+}
+
+// closeSegment closes the segment and passes it to the rotate loop for compression.
+func (s *Sink) closeSegment(key string, seg *segment) error {
+	delete(s.segments, key)
+
+	s.closed = append(s.closed, seg.path)
+
+	select {
+	case s.rotated <- struct{}{}:
+	default:
+	}
+
+	if err := seg.file.Close(); err != nil {
+		return fmt.Errorf("close %s: %w", seg.path, err)
+	}
+
+	return nil
+}
+
+func (s *Sink) rotateLoop() {
+	defer close(s.done)
+
+	ticker := time.NewTicker(_checkInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stop:
+			return
+		case now := <-ticker.C:
+			s.rotateExpired(now)
+		case <-s.rotated:
+		}
+
+		if err := s.finish(s.takeClosed()); err != nil {
+			s.logger.Errorf(context.Background(), "finish rotated segments: %v", err)
+		}
+	}
+}
+
+// rotateExpired closes segments older than max age.
+func (s *Sink) rotateExpired(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for key, seg := range s.segments {
+		if !s.expired(seg, now) {
+			continue
+		}
+
+		if err := s.closeSegment(key, seg); err != nil {
+			s.logger.Errorf(context.Background(), "rotate segment: %v", err)
+		}
+	}
+}
+
+// takeClosed returns segments waiting for compression.
+func (s *Sink) takeClosed() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	closed := s.closed
+	s.closed = nil
+
+	return closed
+}
+
+// finish compresses closed segments and applies the retention size.
+func (s *Sink) finish(closed []string) error {
+	if len(closed) == 0 {
+		return nil
+	}
+
+	var err error
+
+	for _, path := range closed {
+		if compressErr := s.compress(path); compressErr != nil && err == nil {
+			err = compressErr
+		}
+	}
+
+	if retentionErr := s.applyRetention(); err == nil {
+		err = retentionErr
+	}
+
+	return err
+}
+
+// compress replaces the closed segment with its compressed copy.
+func (s *Sink) compress(path string) error {
+	if s.ext == "" {
+		return nil
+	}
+
+	if err := fileutil.CompressFile(path, path+s.ext, s.config.Compression); err != nil {
+		// The segment was removed by the retention.
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+
+		return err
+	}
+
+	return os.Remove(path)
+}
+
+// applyRetention removes the oldest closed segments until the directory fits the retention size.
+func (s *Sink) applyRetention() error {
+	if s.config.RetentionSize <= 0 {
+		return nil
+	}
+
+	var (
+		total  int64
+		closed = make([]fileInfo, 0)
+	)
+
+	err := filepath.WalkDir(s.config.Dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return nil
+			}
+
+			return err
+		}
+
+		total += info.Size()
+
+		if isSegment(path) {
+			closed = append(closed, fileInfo{path: path, name: d.Name(), size: info.Size()})
+		}
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("walk dir: %w", err)
+	}
+
+	// Segment names start with the open time.
+	sort.Slice(closed, func(i, j int) bool { return closed[i].name < closed[j].name })
+
+	for _, file := range closed {
+		if total <= s.config.RetentionSize {
+			break
+		}
+
+		removed, err := s.removeClosed(file.path)
+		if err != nil {
+			return err
+		}
+
+		if removed {
+			total -= file.size
+		}
+	}
+
+	return nil
+}
+
+// removeClosed removes the segment unless it is active. Segments are checked under the lock at removal,
+// so a segment opened after the directory was walked is not removed.
+func (s *Sink) removeClosed(path string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, seg := range s.segments {
+		if seg.path == path {
+			return false, nil
+		}
+	}
+
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return false, err
+	}
+
+	return true, nil
+}
+
+type fileInfo struct {
+	path string
+	name string
+	size int64
+}
+
+func (s *Sink) files(match func(path string) bool) ([]string, error) {
+	paths := make([]string, 0)
+
+	err := filepath.WalkDir(s.config.Dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+
+		if match(path) {
+			paths = append(paths, path)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("walk dir: %w", err)
+	}
+
+	return paths, nil
+}
+
+func isSegment(path string) bool {
+	return strings.HasSuffix(path, _segmentExt) ||
+		strings.HasSuffix(path, _segmentExt+fileutil.GzipExt) ||
+		strings.HasSuffix(path, _segmentExt+fileutil.ZstdExt)
+}
@@ -0,0 +1,303 @@
+package filesink
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/loghole/tracing/tracelog"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+
+	"github.com/loghole/collector/internal/app/domain"
+	"github.com/loghole/collector/pkg/fileutil"
+)
+
+func TestSink_InsertEntryList(t *testing.T) {
+	tests := []struct {
+		name        string
+		compression string
+		expectedExt string
+	}{
+		{
+			name:        "NonePass",
+			compression: CompressionNone,
+			expectedExt: _segmentExt,
+		},
+		{
+			name:        "GzipPass",
+			compression: CompressionGzip,
+			expectedExt: _segmentExt + fileutil.GzipExt,
+		},
+		{
+			name:        "ZstdPass",
+			compression: CompressionZstd,
+			expectedExt: _segmentExt + fileutil.ZstdExt,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+
+			// Records are 64 and 79 bytes, segments are rotated after the second one.
+			sink, err := Open(&Config{Dir: dir, MaxSize: 120, Compression: tt.compression}, testLogger())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, message := range []string{"1", "2", "3"} {
+				err := sink.InsertEntryList(context.Background(), []*domain.Entry{
+					{Namespace: "app", Source: "api", Message: message},
+					{Namespace: "", Source: "../etc", Message: message},
+				})
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			assert.NoError(t, sink.Close())
+
+			expected := map[string][]string{
+				"app/api":  {"1", "2", "3"},
+				"_/.._etc": {"1", "2", "3"},
+			}
+
+			actual := make(map[string][]string)
+
+			for key := range expected {
+				files, err := filepath.Glob(filepath.Join(dir, key, "*"))
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				assert.Len(t, files, 2)
+
+				for _, file := range files {
+					assert.True(t, strings.HasSuffix(file, tt.expectedExt), file)
+
+					actual[key] = append(actual[key], readMessages(t, file, tt.compression)...)
+				}
+			}
+
+			assert.Equal(t, expected, actual)
+		})
+	}
+}
+
+func TestSink_applyRetention(t *testing.T) {
+	dir := t.TempDir()
+
+	for _, name := range []string{
+		"app/api/20221018T100000.000000000-1.ndjson.gz",
+		"app/web/20221018T110000.000000000-2.ndjson.gz",
+		"app/api/20221018T120000.000000000-3.ndjson.gz",
+	} {
+		path := filepath.Join(dir, name)
+
+		if err := os.MkdirAll(filepath.Dir(path), _dirPerm); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := os.WriteFile(path, make([]byte, 100), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	sink, err := Open(&Config{Dir: dir, Compression: CompressionGzip, RetentionSize: 250}, testLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer sink.Close()
+
+	files, err := sink.files(isSegment)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, []string{
+		filepath.Join(dir, "app/api/20221018T120000.000000000-3.ndjson.gz"),
+		filepath.Join(dir, "app/web/20221018T110000.000000000-2.ndjson.gz"),
+	}, files)
+}
+
+func TestSink_removeClosed(t *testing.T) {
+	dir := t.TempDir()
+
+	sink, err := Open(&Config{Dir: dir, Compression: CompressionGzip}, testLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer sink.Close()
+
+	err = sink.InsertEntryList(context.Background(), []*domain.Entry{{Namespace: "app", Message: "message"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	closed := filepath.Join(dir, "app/20221018T100000.000000000-1.ndjson.gz")
+
+	if err := os.WriteFile(closed, make([]byte, 100), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// The segment opened after the directory was walked is kept.
+	assert.Len(t, sink.segments, 1)
+
+	for _, seg := range sink.segments {
+		removed, err := sink.removeClosed(seg.path)
+		assert.NoError(t, err)
+		assert.False(t, removed)
+		assert.FileExists(t, seg.path)
+	}
+
+	removed, err := sink.removeClosed(closed)
+	assert.NoError(t, err)
+	assert.True(t, removed)
+	assert.NoFileExists(t, closed)
+}
+
+func TestSink_rotateExpired(t *testing.T) {
+	sink, err := Open(&Config{Dir: t.TempDir(), MaxAge: time.Minute, Compression: CompressionGzip}, testLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer sink.Close()
+
+	err = sink.InsertEntryList(context.Background(), []*domain.Entry{{Namespace: "app", Message: "message"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sink.rotateExpired(time.Now())
+	assert.Len(t, sink.segments, 1)
+
+	sink.rotateExpired(time.Now().Add(time.Minute))
+	assert.Empty(t, sink.segments)
+}
+
+func TestSink_InsertEntryList_Compress(t *testing.T) {
+	dir := t.TempDir()
+
+	sink, err := Open(&Config{Dir: dir, MaxSize: 1, Compression: CompressionGzip}, testLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer sink.Close()
+
+	err = sink.InsertEntryList(context.Background(), []*domain.Entry{{Namespace: "app", Message: "message"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Rotated segment is compressed by the rotate loop.
+	assert.Eventually(t, func() bool {
+		files, err := filepath.Glob(filepath.Join(dir, "app", "_", "*"+_segmentExt+fileutil.GzipExt))
+
+		return err == nil && len(files) == 1
+	}, time.Second, time.Millisecond)
+}
+
+func TestSink_InsertEntryList_Rollback(t *testing.T) {
+	dir := t.TempDir()
+
+	sink, err := Open(&Config{Dir: dir}, testLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer sink.Close()
+
+	list := []*domain.Entry{
+		{Namespace: "app", Source: "api", Message: "1"},
+		{Namespace: "app", Source: "web", Message: "1"},
+	}
+
+	if err := sink.InsertEntryList(context.Background(), list); err != nil {
+		t.Fatal(err)
+	}
+
+	// Writing to the second segment fails after the first one was written.
+	assert.NoError(t, sink.segments[filepath.Join("app", "web")].file.Close())
+	assert.Error(t, sink.InsertEntryList(context.Background(), list))
+
+	seg := sink.segments[filepath.Join("app", "api")]
+
+	info, err := os.Stat(seg.path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, seg.size, info.Size())
+	assert.Equal(t, []string{"1"}, readMessages(t, seg.path, CompressionNone))
+
+	// Next entries follow the written ones.
+	assert.NoError(t, sink.InsertEntryList(context.Background(), list[:1]))
+	assert.Equal(t, []string{"1", "1"}, readMessages(t, seg.path, CompressionNone))
+}
+
+func TestOpen_InvalidCompression(t *testing.T) {
+	_, err := Open(&Config{Dir: t.TempDir(), Compression: "lz4"}, testLogger())
+	assert.ErrorIs(t, err, ErrInvalidCompression)
+}
+
+func readMessages(t *testing.T, path, compression string) []string {
+	t.Helper()
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer file.Close()
+
+	var reader io.Reader = file
+
+	switch compression {
+	case CompressionGzip:
+		if reader, err = gzip.NewReader(file); err != nil {
+			t.Fatal(err)
+		}
+	case CompressionZstd:
+		decoder, err := zstd.NewReader(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		defer decoder.Close()
+
+		reader = decoder
+	}
+
+	messages := make([]string, 0)
+	scanner := bufio.NewScanner(reader)
+
+	for scanner.Scan() {
+		entry, err := domain.UnmarshalRecord(scanner.Bytes())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		messages = append(messages, entry.Message)
+	}
+
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	return messages
+}
+
+func testLogger() tracelog.Logger {
+	return tracelog.NewTraceLogger(zap.NewNop().Sugar())
+}
@@ -0,0 +1,181 @@
+// Package kafkasink is a storage backend publishing entries to a Kafka topic. Every entry is a message
+// with the json record of the entry, messages are keyed by namespace and source, so entries of one source
+// keep their order in one partition.
+package kafkasink
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+
+	"github.com/loghole/collector/internal/app/domain"
+	"github.com/loghole/collector/internal/app/repositories/writer"
+)
+
+// Compression codecs of messages.
+const (
+	CompressionNone   = "none"
+	CompressionGzip   = "gzip"
+	CompressionSnappy = "snappy"
+	CompressionLz4    = "lz4"
+	CompressionZstd   = "zstd"
+)
+
+// Acknowledgements required from brokers.
+const (
+	AcksNone = "none"
+	AcksOne  = "one"
+	AcksAll  = "all"
+)
+
+var (
+	ErrInvalidCompression = errors.New("invalid compression")
+	ErrInvalidAcks        = errors.New("invalid required acks")
+	ErrNoBrokers          = errors.New("no brokers")
+)
+
+type Config struct {
+	Brokers []string
+	Topic   string
+	// Compression of messages, see Compression constants.
+	Compression string
+	// RequiredAcks from brokers, see Acks constants.
+	RequiredAcks string
+	// BatchSize and BatchTimeout limit messages of one produce request to a partition.
+	BatchSize    int
+	BatchTimeout time.Duration
+	WriteTimeout time.Duration
+}
+
+// Producer publishes messages to the topic, see kafka.Writer.
+type Producer interface {
+	WriteMessages(ctx context.Context, messages ...kafka.Message) error
+	Close() error
+}
+
+type Sink struct {
+	brokers  []string
+	producer Producer
+}
+
+// Open returns sink publishing messages with the kafka writer, messages are partitioned by hash of the key.
+func Open(config *Config) (*Sink, error) {
+	if len(config.Brokers) == 0 {
+		return nil, ErrNoBrokers
+	}
+
+	codec, err := compressionCodec(config.Compression)
+	if err != nil {
+		return nil, err
+	}
+
+	acks, err := requiredAcks(config.RequiredAcks)
+	if err != nil {
+		return nil, err
+	}
+
+	producer := &kafka.Writer{
+		Addr:         kafka.TCP(config.Brokers...),
+		Topic:        config.Topic,
+		Balancer:     &kafka.Hash{},
+		BatchSize:    config.BatchSize,
+		BatchTimeout: config.BatchTimeout,
+		WriteTimeout: config.WriteTimeout,
+		RequiredAcks: acks,
+		Compression:  codec,
+	}
+
+	return NewSink(config.Brokers, producer), nil
+}
+
+func NewSink(brokers []string, producer Producer) *Sink {
+	return &Sink{brokers: brokers, producer: producer}
+}
+
+// Ping connects to the first available broker.
+func (s *Sink) Ping(ctx context.Context) error {
+	err := ErrNoBrokers
+
+	for _, broker := range s.brokers {
+		var conn *kafka.Conn
+
+		if conn, err = kafka.DialContext(ctx, "tcp", broker); err == nil {
+			return conn.Close()
+		}
+	}
+
+	return err
+}
+
+// InsertEntryList publishes entries, messages rejected by the broker as too large fail with writer.RowError.
+func (s *Sink) InsertEntryList(ctx context.Context, list []*domain.Entry) error {
+	messages := make([]kafka.Message, 0, len(list))
+
+	for _, entry := range list {
+		data, err := entry.MarshalRecord()
+		if err != nil {
+			return &writer.RowError{Err: fmt.Errorf("marshal entry: %w", err)}
+		}
+
+		messages = append(messages, kafka.Message{
+			Key:     MessageKey(entry),
+			Value:   data,
+			Time:    entry.Time,
+			Headers: []kafka.Header{{Key: domain.RecordFormatHeader, Value: []byte(domain.RecordFormat)}},
+		})
+	}
+
+	if err := s.producer.WriteMessages(ctx, messages...); err != nil {
+		var tooLarge kafka.MessageTooLargeError
+
+		if errors.As(err, &tooLarge) {
+			return &writer.RowError{Err: err}
+		}
+
+		return fmt.Errorf("write messages: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Sink) Close() error {
+	return s.producer.Close()
+}
+
+// MessageKey returns key of the entry message, entries of one namespace and source have the same key.
+func MessageKey(entry *domain.Entry) []byte {
+	return []byte(entry.Namespace + "/" + entry.Source)
+}
+
+func compressionCodec(compression string) (kafka.Compression, error) {
+	switch compression {
+	case CompressionNone, "":
+		return 0, nil
+	case CompressionGzip:
+		return kafka.Gzip, nil
+	case CompressionSnappy:
+		return kafka.Snappy, nil
+	case CompressionLz4:
+		return kafka.Lz4, nil
+	case CompressionZstd:
+		return kafka.Zstd, nil
+	default:
+		return 0, fmt.Errorf("%w: %q", ErrInvalidCompression, compression)
+	}
+}
+
+func requiredAcks(acks string) (kafka.RequiredAcks, error) {
+	switch acks {
+	case AcksNone:
+		return kafka.RequireNone, nil
+	case AcksOne:
+		return kafka.RequireOne, nil
+	case AcksAll, "":
+		return kafka.RequireAll, nil
+	default:
+		return 0, fmt.Errorf("%w: %q", ErrInvalidAcks, acks)
+	}
+}
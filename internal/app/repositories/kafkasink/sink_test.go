@@ -0,0 +1,106 @@
+package kafkasink
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/loghole/collector/internal/app/domain"
+	"github.com/loghole/collector/internal/app/repositories/writer"
+)
+
+type producerMock struct {
+	err      error
+	messages []kafka.Message
+}
+
+func (p *producerMock) WriteMessages(ctx context.Context, messages ...kafka.Message) error {
+	if p.err != nil {
+		return p.err
+	}
+
+	p.messages = append(p.messages, messages...)
+
+	return nil
+}
+
+func (p *producerMock) Close() error { return nil }
+
+func TestSink_InsertEntryList(t *testing.T) {
+	tests := []struct {
+		name        string
+		err         error
+		wantErr     bool
+		wantRowErr  bool
+		expectedLen int
+	}{
+		{
+			name:        "Pass",
+			expectedLen: 2,
+		},
+		{
+			name:       "MessageTooLargeError",
+			err:        kafka.MessageTooLargeError{},
+			wantErr:    true,
+			wantRowErr: true,
+		},
+		{
+			name:    "BrokerError",
+			err:     errors.New("connection refused"),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				producer = &producerMock{err: tt.err}
+				sink     = NewSink(nil, producer)
+				ts       = time.Date(2022, 10, 18, 9, 30, 0, 0, time.UTC)
+			)
+
+			err := sink.InsertEntryList(context.Background(), []*domain.Entry{
+				{Time: ts, Namespace: "app", Source: "api", Message: "first"},
+				{Time: ts, Namespace: "app", Source: "web", Message: "second"},
+			})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("InsertEntryList() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			var rowErr *writer.RowError
+
+			assert.Equal(t, tt.wantRowErr, errors.As(err, &rowErr))
+
+			if !assert.Len(t, producer.messages, tt.expectedLen) || tt.expectedLen == 0 {
+				return
+			}
+
+			assert.Equal(t, "app/api", string(producer.messages[0].Key))
+			assert.Equal(t, "app/web", string(producer.messages[1].Key))
+			assert.Equal(t, []kafka.Header{{Key: domain.RecordFormatHeader, Value: []byte(domain.RecordFormat)}},
+				producer.messages[0].Headers)
+
+			entry, err := domain.UnmarshalRecord(producer.messages[1].Value)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assert.Equal(t, "second", entry.Message)
+			assert.True(t, ts.Equal(entry.Time))
+		})
+	}
+}
+
+func TestOpen(t *testing.T) {
+	_, err := Open(&Config{})
+	assert.ErrorIs(t, err, ErrNoBrokers)
+
+	_, err = Open(&Config{Brokers: []string{"kafka:9092"}, Compression: "brotli"})
+	assert.ErrorIs(t, err, ErrInvalidCompression)
+
+	_, err = Open(&Config{Brokers: []string{"kafka:9092"}, RequiredAcks: "two"})
+	assert.ErrorIs(t, err, ErrInvalidAcks)
+}
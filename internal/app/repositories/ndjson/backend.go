@@ -0,0 +1,82 @@
+// Package ndjson is a storage backend writing entries as newline delimited json records,
+// the format of the dead letter files.
+package ndjson
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/loghole/collector/internal/app/domain"
+	"github.com/loghole/collector/internal/app/repositories/writer"
+)
+
+const (
+	_dirPerm  = 0o755
+	_filePerm = 0o644
+)
+
+type Backend struct {
+	mu     sync.Mutex
+	out    io.Writer
+	closer io.Closer
+}
+
+// NewBackend returns backend writing to out, out is not closed by the backend.
+func NewBackend(out io.Writer) *Backend {
+	return &Backend{out: out}
+}
+
+// OpenFile returns backend appending entries to the file.
+func OpenFile(path string) (*Backend, error) {
+	if err := os.MkdirAll(filepath.Dir(path), _dirPerm); err != nil {
+		return nil, fmt.Errorf("create dir: %w", err)
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, _filePerm)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Backend{out: file, closer: file}, nil
+}
+
+func (b *Backend) Ping(ctx context.Context) error {
+	return nil
+}
+
+// InsertEntryList writes the batch with a single write, so batches of parallel workers are not mixed.
+func (b *Backend) InsertEntryList(ctx context.Context, list []*domain.Entry) error {
+	var buf bytes.Buffer
+
+	for _, entry := range list {
+		data, err := entry.MarshalRecord()
+		if err != nil {
+			return &writer.RowError{Err: fmt.Errorf("marshal entry: %w", err)}
+		}
+
+		buf.Write(data)
+		buf.WriteByte('\n')
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, err := b.out.Write(buf.Bytes()); err != nil {
+		return fmt.Errorf("write: %w", err)
+	}
+
+	return nil
+}
+
+func (b *Backend) Close() error {
+	if b.closer == nil {
+		return nil
+	}
+
+	return b.closer.Close()
+}
@@ -0,0 +1,56 @@
+package ndjson
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/loghole/collector/internal/app/domain"
+	"github.com/loghole/collector/internal/app/repositories/writer"
+)
+
+func TestBackend_InsertEntryList(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs", "entries.ndjson")
+
+	backend, err := OpenFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, message := range []string{"first", "second"} {
+		if err := backend.InsertEntryList(context.Background(), []*domain.Entry{{Message: message}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err = backend.InsertEntryList(context.Background(), []*domain.Entry{{Params: json.RawMessage("{")}})
+
+	var target *writer.RowError
+
+	assert.True(t, errors.As(err, &target))
+	assert.NoError(t, backend.Close())
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	messages := make([]string, 0)
+
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		entry, err := domain.UnmarshalRecord([]byte(line))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		messages = append(messages, entry.Message)
+	}
+
+	assert.Equal(t, []string{"first", "second"}, messages)
+}
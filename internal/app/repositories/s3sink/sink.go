@@ -0,0 +1,645 @@
+// Package s3sink is a storage backend archiving entries to an S3 compatible object storage. Entries are
+// appended to the local spool in an object per partition by namespace, date and hour. An object is closed
+// when it reaches the size or age limit, closed objects are compressed and uploaded in background
+// and removed after the upload.
+package s3sink
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/loghole/tracing/tracelog"
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+
+	"github.com/loghole/collector/internal/app/domain"
+	"github.com/loghole/collector/internal/app/repositories/writer"
+	"github.com/loghole/collector/pkg/fileutil"
+)
+
+// Compression of objects.
+const (
+	CompressionNone = fileutil.CompressionNone
+	CompressionGzip = fileutil.CompressionGzip
+	CompressionZstd = fileutil.CompressionZstd
+)
+
+const (
+	_objectExt    = ".ndjson"
+	_openExt      = ".open"
+	_closedExt    = ".closed"
+	_timeLayout   = "20060102T150405.000000000"
+	_contentType  = "application/x-ndjson"
+	_dirPerm      = 0o755
+	_filePerm     = 0o644
+	_uploadPeriod = time.Second
+)
+
+var (
+	ErrBucketNotFound     = errors.New("bucket not found")
+	ErrSpoolFull          = errors.New("spool is full")
+	ErrInvalidCompression = fileutil.ErrInvalidCompression
+)
+
+type Config struct {
+	Endpoint  string
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	UseSSL    bool
+	// Prefix of object keys, objects are stored as prefix/namespace=/date=/hour=/name.
+	Prefix string
+	// Compression of objects, see Compression constants.
+	Compression string
+	// PartSize of multipart upload, smaller objects are uploaded with a single request.
+	PartSize uint64
+	// MaxObjectSize of uncompressed entries and MaxObjectAge close the object, zero disables the limit.
+	MaxObjectSize int64
+	MaxObjectAge  time.Duration
+	// SpoolDir keeps objects until they are uploaded.
+	SpoolDir string
+	// SpoolMaxSize fails inserts while the spool is larger, zero disables the limit.
+	SpoolMaxSize int64
+	// RetryInterval between uploads after a failure.
+	RetryInterval time.Duration
+}
+
+// ObjectStorage uploads objects, see minio.Client.
+type ObjectStorage interface {
+	BucketExists(ctx context.Context, bucket string) (bool, error)
+	PutObject(
+		ctx context.Context,
+		bucket, key string,
+		reader io.Reader,
+		size int64,
+		opts minio.PutObjectOptions,
+	) (minio.UploadInfo, error)
+}
+
+type Sink struct {
+	seq  uint64
+	size int64
+
+	config   *Config
+	ext      string
+	instance string
+	storage  ObjectStorage
+	logger   tracelog.Logger
+
+	mu      sync.Mutex
+	objects map[string]*object
+
+	notify chan struct{}
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// object is an open spool file collecting entries of a partition.
+type object struct {
+	partition string
+	file      *os.File
+	path      string
+	size      int64
+	opened    time.Time
+}
+
+// Open connects to the object storage and starts uploading of spooled objects.
+func Open(config *Config, logger tracelog.Logger) (*Sink, error) {
+	client, err := minio.New(config.Endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(config.AccessKey, config.SecretKey, ""),
+		Secure: config.UseSSL,
+		Region: config.Region,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("init client: %w", err)
+	}
+
+	return NewSink(config, client, logger)
+}
+
+// NewSink returns sink uploading objects to the storage. Objects left open by the previous run are closed.
+func NewSink(config *Config, storage ObjectStorage, logger tracelog.Logger) (*Sink, error) {
+	ext, err := fileutil.CompressedExt(config.Compression)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(config.SpoolDir, _dirPerm); err != nil {
+		return nil, fmt.Errorf("create spool dir: %w", err)
+	}
+
+	s := &Sink{
+		config:   config,
+		ext:      _objectExt + ext,
+		instance: uuid.New().String()[:8],
+		storage:  storage,
+		logger:   logger,
+		objects:  make(map[string]*object),
+		notify:   make(chan struct{}, 1),
+		done:     make(chan struct{}),
+	}
+
+	if err := s.loadSpool(); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	s.cancel = cancel
+
+	go s.uploadLoop(ctx)
+
+	return s, nil
+}
+
+func (s *Sink) Ping(ctx context.Context) error {
+	ok, err := s.storage.BucketExists(ctx, s.config.Bucket)
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrBucketNotFound, s.config.Bucket)
+	}
+
+	return nil
+}
+
+// InsertEntryList appends entries to the open objects of their partitions, the entries are uploaded later.
+// Entries are written all or none, so a retried list is not spooled twice.
+func (s *Sink) InsertEntryList(ctx context.Context, list []*domain.Entry) error {
+	if size := atomic.LoadInt64(&s.size); s.config.SpoolMaxSize > 0 && size >= s.config.SpoolMaxSize {
+		return fmt.Errorf("%w: %d bytes", ErrSpoolFull, size)
+	}
+
+	var (
+		keys    = make([]string, 0)
+		buffers = make(map[string]*bytes.Buffer)
+	)
+
+	for _, entry := range list {
+		data, err := entry.MarshalRecord()
+		if err != nil {
+			return &writer.RowError{Err: fmt.Errorf("marshal entry: %w", err)}
+		}
+
+		key := s.partition(entry)
+
+		buf, ok := buffers[key]
+		if !ok {
+			buf = &bytes.Buffer{}
+			buffers[key] = buf
+			keys = append(keys, key)
+		}
+
+		buf.Write(data)
+		buf.WriteByte('\n')
+	}
+
+	return s.write(ctx, keys, buffers)
+}
+
+// Close stops uploading and closes open objects, objects left in the spool are uploaded after the next start.
+func (s *Sink) Close() error {
+	s.cancel()
+	<-s.done
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var err error
+
+	for _, obj := range s.objects {
+		if closeErr := s.closeObject(obj); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}
+
+	return err
+}
+
+// partition returns the time partitioned key prefix of the entry.
+func (s *Sink) partition(entry *domain.Entry) string {
+	t := entry.Time.UTC()
+
+	return path.Join(
+		"namespace="+fileutil.PathName(entry.Namespace),
+		"date="+t.Format("2006-01-02"),
+		"hour="+t.Format("15"),
+	)
+}
+
+// objectPath returns the spool path of a new object of the partition without extension.
+func (s *Sink) objectPath(partition string) string {
+	name := fmt.Sprintf("%s-%s-%d",
+		time.Now().UTC().Format(_timeLayout), s.instance, atomic.AddUint64(&s.seq, 1))
+
+	return filepath.Join(s.config.SpoolDir, filepath.FromSlash(path.Join(s.config.Prefix, partition, name)))
+}
+
+func (s *Sink) write(ctx context.Context, keys []string, buffers map[string]*bytes.Buffer) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var (
+		now   = time.Now()
+		objs  = make([]*object, 0, len(keys))
+		sizes = make([]int64, 0, len(keys))
+	)
+
+	for _, key := range keys {
+		obj, err := s.activeObject(key, now)
+		if err != nil {
+			return err
+		}
+
+		objs, sizes = append(objs, obj), append(sizes, obj.size)
+	}
+
+	if err := s.writeObjects(objs, keys, buffers); err != nil {
+		s.rollback(ctx, objs, sizes)
+
+		return err
+	}
+
+	for _, obj := range objs {
+		if s.config.MaxObjectSize <= 0 || obj.size < s.config.MaxObjectSize {
+			continue
+		}
+
+		// Entries are already synced, the failed close must not fail the insert.
+		if err := s.closeObject(obj); err != nil {
+			s.logger.Errorf(ctx, "close spooled object: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// writeObjects writes buffers to objects and syncs them.
+func (s *Sink) writeObjects(objs []*object, keys []string, buffers map[string]*bytes.Buffer) error {
+	for idx, obj := range objs {
+		n, err := obj.file.Write(buffers[keys[idx]].Bytes())
+		obj.size += int64(n)
+
+		atomic.AddInt64(&s.size, int64(n))
+
+		if err != nil {
+			return fmt.Errorf("write spool: %w", err)
+		}
+	}
+
+	for _, obj := range objs {
+		if err := obj.file.Sync(); err != nil {
+			return fmt.Errorf("sync spool: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// rollback truncates objects to their sizes before the failed write. An object that can't be
+// truncated is closed, so the next entries don't follow its torn line.
+func (s *Sink) rollback(ctx context.Context, objs []*object, sizes []int64) {
+	for idx, obj := range objs {
+		if obj.size == sizes[idx] {
+			continue
+		}
+
+		if err := obj.file.Truncate(sizes[idx]); err != nil {
+			s.logger.Errorf(ctx, "truncate spooled object: %v", err)
+
+			if err := s.closeObject(obj); err != nil {
+				s.logger.Errorf(ctx, "close spooled object: %v", err)
+			}
+
+			continue
+		}
+
+		atomic.AddInt64(&s.size, sizes[idx]-obj.size)
+		obj.size = sizes[idx]
+	}
+}
+
+// activeObject returns the open object of the partition, the expired object is replaced with a new one.
+func (s *Sink) activeObject(partition string, now time.Time) (*object, error) {
+	obj, ok := s.objects[partition]
+	if ok && !s.expired(obj, now) {
+		return obj, nil
+	}
+
+	if ok {
+		if err := s.closeObject(obj); err != nil {
+			return nil, err
+		}
+	}
+
+	filePath := s.objectPath(partition)
+
+	if err := os.MkdirAll(filepath.Dir(filePath), _dirPerm); err != nil {
+		return nil, fmt.Errorf("create spool dir: %w", err)
+	}
+
+	file, err := os.OpenFile(filePath+_openExt, os.O_WRONLY|os.O_APPEND|os.O_CREATE|os.O_EXCL, _filePerm)
+	if err != nil {
+		return nil, fmt.Errorf("open spool: %w", err)
+	}
+
+	obj = &object{partition: partition, file: file, path: filePath, opened: now}
+	s.objects[partition] = obj
+
+	return obj, nil
+}
+
+func (s *Sink) expired(obj *object, now time.Time) bool {
+	return s.config.MaxObjectAge > 0 && now.Sub(obj.opened) >= s.config.MaxObjectAge
+}
+
+// closeObject closes the object and passes it to the upload loop.
+func (s *Sink) closeObject(obj *object) error {
+	delete(s.objects, obj.partition)
+
+	if err := obj.file.Close(); err != nil {
+		return fmt.Errorf("close %s: %w", obj.path, err)
+	}
+
+	// Object of the failed write is empty.
+	if obj.size == 0 {
+		return os.Remove(obj.path + _openExt)
+	}
+
+	if err := os.Rename(obj.path+_openExt, obj.path+_closedExt); err != nil {
+		return err
+	}
+
+	select {
+	case s.notify <- struct{}{}:
+	default:
+	}
+
+	return nil
+}
+
+// closeExpired closes objects older than max age.
+func (s *Sink) closeExpired(ctx context.Context, now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, obj := range s.objects {
+		if !s.expired(obj, now) {
+			continue
+		}
+
+		if err := s.closeObject(obj); err != nil {
+			s.logger.Errorf(ctx, "close spooled object: %v", err)
+		}
+	}
+}
+
+func (s *Sink) uploadLoop(ctx context.Context) {
+	defer close(s.done)
+
+	var (
+		timer  = time.NewTimer(0)
+		notify = s.notify
+	)
+
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-notify:
+		case <-timer.C:
+		}
+
+		delay := _uploadPeriod
+		notify = s.notify
+
+		s.closeExpired(ctx, time.Now())
+
+		if err := s.compress(); err != nil {
+			s.logger.Errorf(ctx, "compress spooled objects: %v", err)
+		}
+
+		// New objects don't trigger the upload until the retry interval after a failure.
+		if err := s.upload(ctx); err != nil && ctx.Err() == nil {
+			s.logger.Errorf(ctx, "upload spooled objects: %v", err)
+
+			delay, notify = s.config.RetryInterval, nil
+		}
+
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+
+		timer.Reset(delay)
+	}
+}
+
+// compress replaces closed objects with their compressed copies ready for upload.
+func (s *Sink) compress() error {
+	files, err := s.spooled(_closedExt)
+	if err != nil {
+		return err
+	}
+
+	var (
+		failed  int
+		lastErr error
+	)
+
+	for _, filePath := range files {
+		if err := s.compressFile(strings.TrimSuffix(filePath, _closedExt)); err != nil {
+			failed, lastErr = failed+1, err
+		}
+	}
+
+	if lastErr != nil {
+		return fmt.Errorf("%d objects failed: %w", failed, lastErr)
+	}
+
+	return nil
+}
+
+func (s *Sink) compressFile(filePath string) error {
+	if s.ext == _objectExt {
+		return os.Rename(filePath+_closedExt, filePath+s.ext)
+	}
+
+	closed, err := os.Stat(filePath + _closedExt)
+	if err != nil {
+		return err
+	}
+
+	if err := fileutil.CompressFile(filePath+_closedExt, filePath+s.ext, s.config.Compression); err != nil {
+		return err
+	}
+
+	compressed, err := os.Stat(filePath + s.ext)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(filePath + _closedExt); err != nil {
+		return err
+	}
+
+	atomic.AddInt64(&s.size, compressed.Size()-closed.Size())
+
+	return nil
+}
+
+// upload sends spooled objects from the oldest, a failed object doesn't stop uploading of the next ones.
+func (s *Sink) upload(ctx context.Context) error {
+	files, err := s.spooled(s.ext)
+	if err != nil {
+		return err
+	}
+
+	var (
+		failed  int
+		lastErr error
+	)
+
+	for _, filePath := range files {
+		if err := s.uploadFile(ctx, filePath); err != nil {
+			if ctx.Err() != nil {
+				return err
+			}
+
+			failed, lastErr = failed+1, err
+
+			continue
+		}
+
+		s.mu.Lock()
+		s.removeEmptyDirs(filepath.Dir(filePath))
+		s.mu.Unlock()
+	}
+
+	if lastErr != nil {
+		return fmt.Errorf("%d objects failed: %w", failed, lastErr)
+	}
+
+	return nil
+}
+
+// uploadFile puts the object to the storage and removes it from the spool.
+func (s *Sink) uploadFile(ctx context.Context, filePath string) error {
+	rel, err := filepath.Rel(s.config.SpoolDir, filePath)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+
+	key := filepath.ToSlash(rel)
+
+	_, err = s.storage.PutObject(ctx, s.config.Bucket, key, file, info.Size(), minio.PutObjectOptions{
+		ContentType: _contentType,
+		PartSize:    s.config.PartSize,
+	})
+	if err != nil {
+		return fmt.Errorf("put %s: %w", key, err)
+	}
+
+	if err := os.Remove(filePath); err != nil {
+		return err
+	}
+
+	atomic.AddInt64(&s.size, -info.Size())
+
+	return nil
+}
+
+// spooled returns files of the spool with the extension in the order they were opened.
+func (s *Sink) spooled(ext string) ([]string, error) {
+	files := make([]string, 0)
+
+	err := filepath.WalkDir(s.config.SpoolDir, func(filePath string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+
+		if strings.HasSuffix(filePath, ext) {
+			files = append(files, filePath)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("walk spool: %w", err)
+	}
+
+	// Object names start with the open time.
+	sort.Slice(files, func(i, j int) bool { return filepath.Base(files[i]) < filepath.Base(files[j]) })
+
+	return files, nil
+}
+
+// loadSpool removes files which compression was interrupted, closes objects left open
+// and counts the spool size.
+func (s *Sink) loadSpool() error {
+	return filepath.WalkDir(s.config.SpoolDir, func(filePath string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+
+		if strings.HasSuffix(filePath, fileutil.TmpExt) {
+			return os.Remove(filePath)
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		s.size += info.Size()
+
+		if strings.HasSuffix(filePath, _openExt) {
+			return os.Rename(filePath, strings.TrimSuffix(filePath, _openExt)+_closedExt)
+		}
+
+		return nil
+	})
+}
+
+// removeEmptyDirs removes partition directories of the spool after their objects were uploaded.
+// It must be called with s.mu held, so that a directory isn't removed before an object is opened in it.
+func (s *Sink) removeEmptyDirs(dir string) {
+	for dir != filepath.Clean(s.config.SpoolDir) && strings.HasPrefix(dir, filepath.Clean(s.config.SpoolDir)) {
+		if err := os.Remove(dir); err != nil {
+			return
+		}
+
+		dir = filepath.Dir(dir)
+	}
+}
@@ -0,0 +1,322 @@
+package s3sink
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/loghole/tracing/tracelog"
+	"github.com/minio/minio-go/v7"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+
+	"github.com/loghole/collector/internal/app/domain"
+)
+
+type storageMock struct {
+	mu      sync.Mutex
+	err     error
+	reject  string
+	objects map[string][]byte
+}
+
+func (s *storageMock) BucketExists(ctx context.Context, bucket string) (bool, error) {
+	return bucket == "logs", nil
+}
+
+func (s *storageMock) PutObject(
+	ctx context.Context,
+	bucket, key string,
+	reader io.Reader,
+	size int64,
+	opts minio.PutObjectOptions,
+) (minio.UploadInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.err != nil {
+		return minio.UploadInfo{}, s.err
+	}
+
+	if s.reject != "" && strings.Contains(key, s.reject) {
+		return minio.UploadInfo{}, errors.New("access denied")
+	}
+
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return minio.UploadInfo{}, err
+	}
+
+	s.objects[key] = data
+
+	return minio.UploadInfo{Bucket: bucket, Key: key, Size: size}, nil
+}
+
+func (s *storageMock) keys() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	keys := make([]string, 0, len(s.objects))
+
+	for key := range s.objects {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
+
+func (s *storageMock) object(key string) []byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.objects[key]
+}
+
+func (s *storageMock) setErr(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.err = err
+}
+
+func TestSink_InsertEntryList(t *testing.T) {
+	var (
+		spoolDir = t.TempDir()
+		storage  = &storageMock{objects: make(map[string][]byte), err: errors.New("connection refused")}
+		ts       = time.Date(2022, 10, 18, 9, 30, 0, 0, time.UTC)
+	)
+
+	sink, err := NewSink(&Config{
+		Bucket:        "logs",
+		Prefix:        "archive",
+		Compression:   CompressionGzip,
+		MaxObjectSize: 1,
+		SpoolDir:      spoolDir,
+		RetryInterval: 10 * time.Millisecond,
+	}, storage, testLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer sink.Close()
+
+	assert.NoError(t, sink.Ping(context.Background()))
+
+	err = sink.InsertEntryList(context.Background(), []*domain.Entry{
+		{Time: ts, Namespace: "app", Message: "first"},
+		{Time: ts.Add(time.Hour), Namespace: "app", Message: "second"},
+		{Time: ts, Namespace: "app", Message: "third"},
+		{Time: ts, Namespace: "../db", Message: "fourth"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Objects are kept in the spool while uploads fail.
+	time.Sleep(30 * time.Millisecond)
+	assert.Empty(t, storage.keys())
+	assert.Len(t, spooledFiles(t, spoolDir), 3)
+
+	storage.setErr(nil)
+
+	assert.Eventually(t, func() bool { return len(storage.keys()) == 3 }, time.Second, 10*time.Millisecond)
+	assert.Empty(t, spooledFiles(t, spoolDir))
+
+	keys := storage.keys()
+
+	for idx, pattern := range []string{
+		`^archive/namespace=.._db/date=2022-10-18/hour=09/\d{8}T\d{6}\.\d{9}-\w{8}-\d+\.ndjson\.gz$`,
+		`^archive/namespace=app/date=2022-10-18/hour=09/`,
+		`^archive/namespace=app/date=2022-10-18/hour=10/`,
+	} {
+		assert.Regexp(t, regexp.MustCompile(pattern), keys[idx])
+	}
+
+	assert.Equal(t, []string{"first", "third"}, readMessages(t, storage.object(keys[1])))
+}
+
+func TestSink_InsertEntryList_Accumulate(t *testing.T) {
+	var (
+		spoolDir = t.TempDir()
+		storage  = &storageMock{objects: make(map[string][]byte)}
+		ts       = time.Date(2022, 10, 18, 9, 30, 0, 0, time.UTC)
+		config   = &Config{Bucket: "logs", Compression: CompressionGzip, MaxObjectSize: 1 << 20, SpoolDir: spoolDir}
+	)
+
+	sink, err := NewSink(config, storage, testLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, message := range []string{"first", "second"} {
+		err := sink.InsertEntryList(context.Background(), []*domain.Entry{{Time: ts, Namespace: "app", Message: message}})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Open object is not uploaded.
+	time.Sleep(30 * time.Millisecond)
+	assert.Empty(t, storage.keys())
+	assert.NoError(t, sink.Close())
+
+	// Object closed on shutdown is uploaded after the next start.
+	sink, err = NewSink(config, storage, testLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer sink.Close()
+
+	assert.Eventually(t, func() bool { return len(storage.keys()) == 1 }, time.Second, 10*time.Millisecond)
+	assert.Equal(t, []string{"first", "second"}, readMessages(t, storage.object(storage.keys()[0])))
+}
+
+func TestSink_InsertEntryList_Rollback(t *testing.T) {
+	var (
+		ts   = time.Date(2022, 10, 18, 9, 30, 0, 0, time.UTC)
+		list = []*domain.Entry{
+			{Time: ts, Namespace: "app", Message: "first"},
+			{Time: ts, Namespace: "db", Message: "first"},
+		}
+	)
+
+	sink, err := NewSink(&Config{Bucket: "logs", Compression: CompressionGzip, SpoolDir: t.TempDir()},
+		&storageMock{objects: make(map[string][]byte)}, testLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer sink.Close()
+
+	if err := sink.InsertEntryList(context.Background(), list); err != nil {
+		t.Fatal(err)
+	}
+
+	size := atomic.LoadInt64(&sink.size)
+
+	// Writing to the second partition fails after the first one was written.
+	assert.NoError(t, sink.objects[sink.partition(list[1])].file.Close())
+	assert.Error(t, sink.InsertEntryList(context.Background(), list))
+
+	obj := sink.objects[sink.partition(list[0])]
+
+	info, err := os.Stat(obj.path + _openExt)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, obj.size, info.Size())
+	assert.Equal(t, size, atomic.LoadInt64(&sink.size))
+}
+
+func TestSink_InsertEntryList_SpoolFull(t *testing.T) {
+	sink, err := NewSink(&Config{Bucket: "logs", SpoolDir: t.TempDir(), SpoolMaxSize: 10},
+		&storageMock{objects: make(map[string][]byte)}, testLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer sink.Close()
+
+	assert.NoError(t, sink.InsertEntryList(context.Background(), []*domain.Entry{{Message: "first"}}))
+	assert.ErrorIs(t, sink.InsertEntryList(context.Background(), []*domain.Entry{{Message: "second"}}), ErrSpoolFull)
+}
+
+func TestSink_upload(t *testing.T) {
+	var (
+		spoolDir = t.TempDir()
+		storage  = &storageMock{objects: make(map[string][]byte), reject: "namespace=denied"}
+		ts       = time.Date(2022, 10, 18, 9, 30, 0, 0, time.UTC)
+	)
+
+	sink, err := NewSink(&Config{
+		Bucket:        "logs",
+		Compression:   CompressionGzip,
+		MaxObjectSize: 1,
+		SpoolDir:      spoolDir,
+		RetryInterval: 10 * time.Millisecond,
+	}, storage, testLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer sink.Close()
+
+	// Rejected object is older than the next one.
+	for _, namespace := range []string{"denied", "app"} {
+		err := sink.InsertEntryList(context.Background(), []*domain.Entry{{Time: ts, Namespace: namespace, Message: "message"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	assert.Eventually(t, func() bool { return len(storage.keys()) == 1 }, time.Second, 10*time.Millisecond)
+	assert.Regexp(t, regexp.MustCompile(`^namespace=app/`), storage.keys()[0])
+	assert.Len(t, spooledFiles(t, spoolDir), 1)
+}
+
+func TestNewSink_InvalidCompression(t *testing.T) {
+	_, err := NewSink(&Config{SpoolDir: t.TempDir(), Compression: "lz4"}, &storageMock{}, nil)
+	assert.ErrorIs(t, err, ErrInvalidCompression)
+}
+
+func spooledFiles(t *testing.T, dir string) []string {
+	t.Helper()
+
+	files := make([]string, 0)
+
+	err := filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			files = append(files, path)
+		}
+
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return files
+}
+
+func readMessages(t *testing.T, data []byte) []string {
+	t.Helper()
+
+	reader, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	messages := make([]string, 0)
+	scanner := bufio.NewScanner(reader)
+
+	for scanner.Scan() {
+		entry, err := domain.UnmarshalRecord(scanner.Bytes())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		messages = append(messages, entry.Message)
+	}
+
+	return messages
+}
+
+func testLogger() tracelog.Logger {
+	return tracelog.NewTraceLogger(zap.NewNop().Sugar())
+}
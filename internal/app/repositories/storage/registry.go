@@ -0,0 +1,75 @@
+// Package storage opens the storage backend of the entry writer by its type.
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/loghole/collector/internal/app/repositories/writer"
+)
+
+// Storage types.
+const (
+	TypeClickhouse   = "clickhouse"
+	TypeFile         = "file"
+	TypeKafka        = "kafka"
+	TypeRotatingFile = "rotating_file"
+	TypeS3           = "s3"
+	TypeStdout       = "stdout"
+	// TypeFanout writes to every storage of `storage.fanout.backends`, it is not a registered backend.
+	TypeFanout = "fanout"
+)
+
+var ErrUnknownType = errors.New("unknown storage type")
+
+// Backend is an opened storage, it is closed after the writer was stopped.
+type Backend interface {
+	writer.Backend
+	Close() error
+}
+
+// Factory opens the backend with its own configuration.
+type Factory func() (Backend, error)
+
+type Registry struct {
+	factories map[string]Factory
+}
+
+func NewRegistry() *Registry {
+	return &Registry{factories: make(map[string]Factory)}
+}
+
+// Register adds the backend type, a factory of the same type is replaced.
+func (r *Registry) Register(name string, factory Factory) {
+	r.factories[name] = factory
+}
+
+// Open returns backend of the registered type.
+func (r *Registry) Open(name string) (Backend, error) {
+	factory, ok := r.factories[name]
+	if !ok {
+		return nil, fmt.Errorf("%w %q, expected one of: %s", ErrUnknownType, name, strings.Join(r.Types(), ", "))
+	}
+
+	backend, err := factory()
+	if err != nil {
+		return nil, fmt.Errorf("open %s storage: %w", name, err)
+	}
+
+	return backend, nil
+}
+
+// Types returns registered backend types in alphabetical order.
+func (r *Registry) Types() []string {
+	types := make([]string, 0, len(r.factories))
+
+	for name := range r.factories {
+		types = append(types, name)
+	}
+
+	sort.Strings(types)
+
+	return types
+}
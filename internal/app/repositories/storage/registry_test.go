@@ -0,0 +1,26 @@
+package storage
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/loghole/collector/internal/app/repositories/ndjson"
+)
+
+func TestRegistry_Open(t *testing.T) {
+	registry := NewRegistry()
+
+	registry.Register(TypeStdout, func() (Backend, error) {
+		return ndjson.NewBackend(&bytes.Buffer{}), nil
+	})
+
+	backend, err := registry.Open(TypeStdout)
+	assert.NoError(t, err)
+	assert.NotNil(t, backend)
+
+	_, err = registry.Open("mysql")
+	assert.ErrorIs(t, err, ErrUnknownType)
+	assert.EqualError(t, err, `unknown storage type "mysql", expected one of: stdout`)
+}
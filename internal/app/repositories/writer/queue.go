@@ -0,0 +1,167 @@
+package writer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/loghole/collector/internal/app/domain"
+)
+
+// Queue overflow policies.
+const (
+	// PolicyBlock waits for free space until the overflow timeout or the request cancellation.
+	PolicyBlock = "block"
+	// PolicyReject fails the request at once.
+	PolicyReject = "reject"
+	// PolicyDropOldest drops the oldest queued entries to make room for new ones.
+	PolicyDropOldest = "drop_oldest"
+	// PolicyDropNewest drops new entries, the request succeeds.
+	PolicyDropNewest = "drop_newest"
+)
+
+var ErrInvalidPolicy = errors.New("invalid overflow policy")
+
+// queueItem is a queued entry with its index in the write-ahead log, the index is zero if the log is disabled.
+type queueItem struct {
+	entry *domain.Entry
+	index uint64
+}
+
+type queueStats struct {
+	rejected      uint64
+	droppedOldest uint64
+	droppedNewest uint64
+	failed        uint64
+
+	// mu guards the result of the last insert.
+	mu        sync.Mutex
+	lastErr   error
+	lastWrite time.Time
+}
+
+func (s *queueStats) snapshot(length, capacity int) domain.WriterStats {
+	stats := domain.WriterStats{
+		QueueLength:   length,
+		QueueCapacity: capacity,
+		Rejected:      atomic.LoadUint64(&s.rejected),
+		DroppedOldest: atomic.LoadUint64(&s.droppedOldest),
+		DroppedNewest: atomic.LoadUint64(&s.droppedNewest),
+		Failed:        atomic.LoadUint64(&s.failed),
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.lastErr != nil {
+		stats.LastError = s.lastErr.Error()
+		stats.Lag = time.Since(s.lastWrite)
+	}
+
+	return stats
+}
+
+// inserted records the result of an insert, rejected rows don't make the storage unhealthy.
+func (s *queueStats) inserted(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch {
+	case err == nil:
+		s.lastErr, s.lastWrite = nil, time.Now()
+	case !isRowError(err):
+		s.lastErr = err
+	}
+}
+
+func validatePolicy(policy string, capacity int) error {
+	switch policy {
+	case PolicyBlock, PolicyReject, PolicyDropNewest:
+		return nil
+	case PolicyDropOldest:
+		if capacity < 1 {
+			return fmt.Errorf("%w: %s requires positive capacity", ErrInvalidPolicy, policy)
+		}
+
+		return nil
+	default:
+		return fmt.Errorf("%w: %q", ErrInvalidPolicy, policy)
+	}
+}
+
+func (w *Writer) enqueue(ctx context.Context, item queueItem, timeout <-chan time.Time) error {
+	switch w.policy {
+	case PolicyReject:
+		select {
+		case w.queue <- item:
+			return nil
+		default:
+			return w.queueFull()
+		}
+	case PolicyDropNewest:
+		select {
+		case w.queue <- item:
+		default:
+			atomic.AddUint64(&w.stats.droppedNewest, 1)
+			w.release(ctx, []queueItem{item}, domain.ErrEntryDropped)
+		}
+
+		return nil
+	case PolicyDropOldest:
+		for {
+			select {
+			case w.queue <- item:
+				return nil
+			default:
+			}
+
+			select {
+			case oldest := <-w.queue:
+				atomic.AddUint64(&w.stats.droppedOldest, 1)
+				w.release(ctx, []queueItem{oldest}, domain.ErrEntryDropped)
+			default:
+			}
+		}
+	default:
+		select {
+		case w.queue <- item:
+			return nil
+		case <-timeout:
+			return w.queueFull()
+		case <-w.stop:
+			return domain.ErrWriterStopped
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func (w *Writer) queueFull() error {
+	atomic.AddUint64(&w.stats.rejected, 1)
+
+	return &domain.QueueFullError{RetryAfter: w.period}
+}
+
+// rejectList commits entries that were not queued.
+func (w *Writer) rejectList(list []*domain.Entry, err error) error {
+	for _, entry := range list {
+		entry.Committed(err)
+	}
+
+	return err
+}
+
+// release commits items and removes them from the write-ahead log.
+func (w *Writer) release(ctx context.Context, items []queueItem, err error) {
+	w.commit(items, err)
+	w.ack(ctx, items)
+}
+
+func (w *Writer) commit(items []queueItem, err error) {
+	for _, item := range items {
+		item.entry.Committed(err)
+	}
+}
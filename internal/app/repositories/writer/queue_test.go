@@ -0,0 +1,158 @@
+package writer
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/loghole/collector/internal/app/domain"
+)
+
+func TestWriter_StoreEntryList(t *testing.T) {
+	tests := []struct {
+		name          string
+		policy        string
+		wantErr       error
+		expectedQueue []string
+		expectedStats domain.WriterStats
+	}{
+		{
+			name:          "BlockTimeoutError",
+			policy:        PolicyBlock,
+			wantErr:       domain.ErrQueueFull,
+			expectedQueue: []string{"1", "2", "3"},
+			expectedStats: domain.WriterStats{QueueLength: 3, QueueCapacity: 3, Rejected: 1},
+		},
+		{
+			name:          "RejectError",
+			policy:        PolicyReject,
+			wantErr:       domain.ErrQueueFull,
+			expectedQueue: []string{"1", "2"},
+			expectedStats: domain.WriterStats{QueueLength: 2, QueueCapacity: 3, Rejected: 1},
+		},
+		{
+			name:          "DropOldestPass",
+			policy:        PolicyDropOldest,
+			expectedQueue: []string{"3", "4", "5"},
+			expectedStats: domain.WriterStats{QueueLength: 3, QueueCapacity: 3, DroppedOldest: 2},
+		},
+		{
+			name:          "DropNewestPass",
+			policy:        PolicyDropNewest,
+			expectedQueue: []string{"1", "2", "3"},
+			expectedStats: domain.WriterStats{QueueLength: 3, QueueCapacity: 3, DroppedNewest: 2},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w, err := New(nil, nil, &Config{
+				Capacity:        3,
+				Period:          time.Second,
+				OverflowPolicy:  tt.policy,
+				OverflowTimeout: time.Millisecond,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var (
+				committed []error
+				commit    = domain.NewCommit(func(err error) { committed = append(committed, err) })
+				first     = entryList("1", "2")
+				second    = entryList("3", "4", "5")
+			)
+
+			commit.Attach(first)
+			commit.Attach(second)
+			commit.Close(nil)
+
+			if err := w.StoreEntryList(context.Background(), first); err != nil {
+				t.Fatal(err)
+			}
+
+			err = w.StoreEntryList(context.Background(), second)
+			assert.ErrorIs(t, err, tt.wantErr)
+			assert.Equal(t, tt.expectedStats, w.Stats())
+
+			close(w.queue)
+
+			queue := make([]string, 0)
+
+			for item := range w.queue {
+				queue = append(queue, item.entry.Message)
+				item.entry.Committed(nil)
+			}
+
+			assert.Equal(t, tt.expectedQueue, queue)
+
+			// Dropped and rejected entries fail the commit.
+			if assert.Len(t, committed, 1) {
+				assert.Error(t, committed[0])
+			}
+		})
+	}
+}
+
+func TestWriter_StoreEntryList_RejectWhole(t *testing.T) {
+	w, err := New(nil, nil, &Config{Capacity: 10, Period: time.Second, OverflowPolicy: PolicyReject})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			_ = w.StoreEntryList(context.Background(), entryList("1", "2", "3"))
+		}()
+	}
+
+	wg.Wait()
+
+	// Concurrent lists are queued whole, the rest of the queue is too small for a list.
+	assert.Equal(t, domain.WriterStats{QueueLength: 9, QueueCapacity: 10, Rejected: 17}, w.Stats())
+}
+
+func TestQueueStats_inserted(t *testing.T) {
+	errInsert := errors.New("connection refused")
+
+	stats := &queueStats{lastWrite: time.Now().Add(-time.Minute)}
+
+	stats.inserted(&RowError{Err: errors.New("unexpected type")})
+	assert.Empty(t, stats.snapshot(0, 0).LastError)
+
+	stats.inserted(errInsert)
+
+	snapshot := stats.snapshot(0, 0)
+	assert.Equal(t, errInsert.Error(), snapshot.LastError)
+	assert.GreaterOrEqual(t, snapshot.Lag, time.Minute)
+
+	stats.inserted(nil)
+	assert.Equal(t, domain.WriterStats{}, stats.snapshot(0, 0))
+}
+
+func TestNew(t *testing.T) {
+	_, err := New(nil, nil, &Config{OverflowPolicy: "unknown"})
+	assert.ErrorIs(t, err, ErrInvalidPolicy)
+
+	_, err = New(nil, nil, &Config{OverflowPolicy: PolicyDropOldest})
+	assert.ErrorIs(t, err, ErrInvalidPolicy)
+}
+
+func entryList(messages ...string) domain.EntryList {
+	list := make(domain.EntryList, 0, len(messages))
+
+	for _, message := range messages {
+		list = append(list, &domain.Entry{Message: message})
+	}
+
+	return list
+}
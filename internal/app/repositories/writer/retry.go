@@ -0,0 +1,193 @@
+package writer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync/atomic"
+	"time"
+
+	"github.com/loghole/collector/internal/app/domain"
+)
+
+// DeadLetterStorage keeps entries that could not be written, see deadletter.Dir.
+type DeadLetterStorage interface {
+	Write(list []*domain.Entry) (string, error)
+}
+
+// RetryConfig of failed inserts, zero MaxAttempts and MaxAge disable the limits.
+type RetryConfig struct {
+	MaxAttempts     int
+	MaxAge          time.Duration
+	InitialInterval time.Duration
+	MaxInterval     time.Duration
+}
+
+// backoff returns jittered exponential delay before the next attempt.
+func (c *RetryConfig) backoff(attempt int) time.Duration {
+	delay := c.InitialInterval
+
+	for i := 1; i < attempt && delay < c.MaxInterval; i++ {
+		delay *= 2
+	}
+
+	if delay > c.MaxInterval {
+		delay = c.MaxInterval
+	}
+
+	if delay <= 0 {
+		return 0
+	}
+
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1)) //nolint:gosec // need pseudo random
+}
+
+// allow reports whether the next attempt is allowed after the delay.
+func (c *RetryConfig) allow(attempt int, started time.Time, delay time.Duration) bool {
+	if c.MaxAttempts > 0 && attempt >= c.MaxAttempts {
+		return false
+	}
+
+	return c.MaxAge <= 0 || time.Since(started)+delay < c.MaxAge
+}
+
+// RowError is returned by backends if the batch failed because of invalid entries.
+type RowError struct {
+	Err error
+}
+
+func (e *RowError) Error() string { return e.Err.Error() }
+func (e *RowError) Unwrap() error { return e.Err }
+
+// Is makes entries of invalid rows committed with domain.ErrEntryRejected.
+func (e *RowError) Is(target error) bool { return target == domain.ErrEntryRejected }
+
+func isRowError(err error) bool {
+	var target *RowError
+
+	return errors.As(err, &target)
+}
+
+// WriteEntryList inserts entries synchronously, it returns entries that could not be written and the last error.
+func (w *Writer) WriteEntryList(ctx context.Context, list []*domain.Entry) ([]*domain.Entry, error) {
+	items := make([]queueItem, 0, len(list))
+
+	for _, entry := range list {
+		items = append(items, queueItem{entry: entry})
+	}
+
+	failed, err := w.insert(ctx, items)
+
+	return itemEntries(failed), err
+}
+
+// insert writes items retrying failed inserts. Invalid rows are isolated by splitting the batch,
+// it returns items that could not be written and the last error. Items whose retries were interrupted
+// by Stop are left in the write-ahead log if it is enabled, they are written on the next start.
+func (w *Writer) insert(ctx context.Context, items []queueItem) ([]queueItem, error) {
+	err := w.insertRetry(ctx, items)
+
+	switch {
+	case err == nil:
+		w.release(ctx, items, nil)
+
+		return nil, nil
+	case len(items) > 1 && isRowError(err):
+		half := len(items) / 2
+
+		failed, err := w.insert(ctx, items[:half])
+
+		tail, tailErr := w.insert(ctx, items[half:])
+		if tailErr != nil {
+			err = tailErr
+		}
+
+		return append(failed, tail...), err
+	case w.wal != nil && errors.Is(err, domain.ErrWriterStopped):
+		w.logger.Warnf(ctx, "%d entries were left in wal: %v", len(items), err)
+		w.commit(items, err)
+
+		return nil, nil
+	default:
+		return items, err
+	}
+}
+
+func (w *Writer) insertRetry(ctx context.Context, items []queueItem) error {
+	var (
+		entries = itemEntries(items)
+		started = time.Now()
+	)
+
+	for attempt := 1; ; attempt++ {
+		err := w.backend.InsertEntryList(ctx, entries)
+
+		w.stats.inserted(err)
+
+		if err == nil || isRowError(err) {
+			return err
+		}
+
+		delay := w.retry.backoff(attempt)
+
+		if !w.retry.allow(attempt, started, delay) {
+			return err
+		}
+
+		w.logger.Warnf(ctx, "insert %d entries, attempt %d failed: %v", len(entries), attempt, err)
+
+		timer := time.NewTimer(delay)
+
+		select {
+		case <-timer.C:
+		case <-w.stop:
+			timer.Stop()
+
+			return fmt.Errorf("%w: %v", domain.ErrWriterStopped, err)
+		}
+	}
+}
+
+// deadLetter stores items that could not be written. Without the dead letter storage they are parked
+// in the write-ahead log if it is enabled and are lost otherwise.
+func (w *Writer) deadLetter(ctx context.Context, items []queueItem, err error) {
+	atomic.AddUint64(&w.stats.failed, uint64(len(items)))
+
+	if w.deadLetters == nil {
+		w.logger.Errorf(ctx, "%d entries were not written: %v", len(items), err)
+		w.parkItems(ctx, items, err)
+
+		return
+	}
+
+	path, writeErr := w.deadLetters.Write(itemEntries(items))
+	if writeErr != nil {
+		w.logger.Errorf(ctx, "%d entries were not written: %v, write dead letters: %v", len(items), err, writeErr)
+		w.parkItems(ctx, items, err)
+
+		return
+	}
+
+	w.logger.Errorf(ctx, "%d entries were moved to %s: %v", len(items), path, err)
+	w.release(ctx, items, fmt.Errorf("%w: %s: %v", domain.ErrDeadLettered, path, err))
+}
+
+// parkItems commits items with the insert error and parks them in the write-ahead log.
+func (w *Writer) parkItems(ctx context.Context, items []queueItem, err error) {
+	w.commit(items, err)
+
+	if parkErr := w.park(ctx, items); parkErr != nil {
+		w.logger.Errorf(ctx, "%d entries were left in wal: %v", len(items), parkErr)
+	}
+}
+
+func itemEntries(items []queueItem) []*domain.Entry {
+	entries := make([]*domain.Entry, 0, len(items))
+
+	for _, item := range items {
+		entries = append(entries, item.entry)
+	}
+
+	return entries
+}
@@ -0,0 +1,141 @@
+package writer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/loghole/tracing/tracelog"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+
+	"github.com/loghole/collector/internal/app/domain"
+	"github.com/loghole/collector/pkg/wal"
+)
+
+type backendMock struct {
+	err     error
+	inserts int32
+}
+
+func (b *backendMock) Ping(ctx context.Context) error { return nil }
+
+func (b *backendMock) InsertEntryList(ctx context.Context, list []*domain.Entry) error {
+	atomic.AddInt32(&b.inserts, 1)
+
+	return b.err
+}
+
+func TestRetryConfig_backoff(t *testing.T) {
+	config := &RetryConfig{InitialInterval: time.Second, MaxInterval: 10 * time.Second}
+
+	tests := []struct {
+		attempt int
+		min     time.Duration
+		max     time.Duration
+	}{
+		{attempt: 1, min: 500 * time.Millisecond, max: time.Second},
+		{attempt: 2, min: time.Second, max: 2 * time.Second},
+		{attempt: 4, min: 4 * time.Second, max: 8 * time.Second},
+		{attempt: 10, min: 5 * time.Second, max: 10 * time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.attempt), func(t *testing.T) {
+			for i := 0; i < 100; i++ {
+				delay := config.backoff(tt.attempt)
+
+				assert.GreaterOrEqual(t, delay, tt.min)
+				assert.LessOrEqual(t, delay, tt.max)
+			}
+		})
+	}
+}
+
+func TestRetryConfig_allow(t *testing.T) {
+	config := &RetryConfig{MaxAttempts: 3, MaxAge: time.Minute}
+
+	assert.True(t, config.allow(2, time.Now(), time.Second))
+	assert.False(t, config.allow(3, time.Now(), time.Second))
+	assert.False(t, config.allow(1, time.Now().Add(-time.Minute), time.Second))
+	assert.True(t, (&RetryConfig{}).allow(100, time.Now().Add(-time.Hour), time.Second))
+}
+
+func TestIsRowError(t *testing.T) {
+	assert.True(t, isRowError(fmt.Errorf("transaction: %w", &RowError{Err: errors.New("unexpected type")})))
+	assert.False(t, isRowError(errors.New("broken pipe")))
+	assert.ErrorIs(t, fmt.Errorf("transaction: %w", &RowError{Err: errors.New("unexpected type")}), domain.ErrEntryRejected)
+}
+
+func TestWriter_Stop_InterruptedRetry(t *testing.T) {
+	dir := t.TempDir()
+
+	log, err := wal.Open(&wal.Config{Dir: dir, SyncPolicy: wal.SyncNever})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		backend = &backendMock{err: errors.New("connection refused")}
+		logger  = tracelog.NewTraceLogger(zap.NewNop().Sugar())
+		done    = make(chan error, 1)
+	)
+
+	w, err := New(backend, logger, &Config{
+		Capacity:       10,
+		Period:         time.Hour,
+		MaxBatchRows:   2,
+		OverflowPolicy: PolicyBlock,
+		WAL:            log,
+		Retry:          RetryConfig{InitialInterval: time.Hour, MaxInterval: time.Hour},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go func() { done <- w.Run(context.Background()) }()
+
+	var (
+		committed = make(chan error, 1)
+		commit    = domain.NewCommit(func(err error) { committed <- err })
+		list      = entryList("first", "second")
+	)
+
+	commit.Attach(list)
+	commit.Close(nil)
+
+	assert.NoError(t, w.StoreEntryList(context.Background(), list))
+
+	assert.Eventually(t, func() bool { return atomic.LoadInt32(&backend.inserts) == 1 }, time.Second, time.Millisecond)
+
+	w.Stop()
+
+	assert.NoError(t, <-done)
+	assert.ErrorIs(t, <-committed, domain.ErrWriterStopped)
+	assert.NoError(t, log.Close())
+
+	// Interrupted entries are written on the next start.
+	log, err = wal.Open(&wal.Config{Dir: dir, SyncPolicy: wal.SyncNever})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer log.Close()
+
+	messages := make([]string, 0)
+
+	assert.NoError(t, log.Replay(func(index uint64, data []byte) error {
+		entry, err := domain.UnmarshalRecord(data)
+		if err != nil {
+			return err
+		}
+
+		messages = append(messages, entry.Message)
+
+		return nil
+	}))
+
+	assert.Equal(t, []string{"first", "second"}, messages)
+}
@@ -0,0 +1,144 @@
+package writer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/loghole/collector/internal/app/domain"
+	"github.com/loghole/collector/pkg/wal"
+)
+
+const _replayBatchSize = 1000
+
+// appendLog writes entries to the write-ahead log and returns them with their indexes.
+func (w *Writer) appendLog(list []*domain.Entry) ([]queueItem, error) {
+	items := make([]queueItem, 0, len(list))
+
+	if w.wal == nil {
+		for _, entry := range list {
+			items = append(items, queueItem{entry: entry})
+		}
+
+		return items, nil
+	}
+
+	records := make([][]byte, 0, len(list))
+
+	for _, entry := range list {
+		data, err := entry.MarshalRecord()
+		if err != nil {
+			return nil, fmt.Errorf("marshal entry: %w", err)
+		}
+
+		records = append(records, data)
+	}
+
+	first, _, err := w.wal.Write(records)
+	if err != nil {
+		if errors.Is(err, wal.ErrFull) {
+			return nil, w.queueFull()
+		}
+
+		if errors.Is(err, wal.ErrTooLarge) {
+			return nil, fmt.Errorf("%w: %v", domain.ErrListTooLarge, err)
+		}
+
+		return nil, fmt.Errorf("write wal: %w", err)
+	}
+
+	for idx, entry := range list {
+		items = append(items, queueItem{entry: entry, index: first + uint64(idx)})
+	}
+
+	return items, nil
+}
+
+func (w *Writer) ack(ctx context.Context, items []queueItem) {
+	if w.wal == nil {
+		return
+	}
+
+	indexes := make([]uint64, 0, len(items))
+
+	for _, item := range items {
+		indexes = append(indexes, item.index)
+	}
+
+	if err := w.wal.Ack(indexes...); err != nil {
+		w.logger.Errorf(ctx, "ack wal: %v", err)
+	}
+}
+
+// park moves items that could not be written out of the write-ahead log, they are written on the next start
+// and don't hold the log from removing the items written after them.
+func (w *Writer) park(ctx context.Context, items []queueItem) error {
+	if w.wal == nil {
+		return nil
+	}
+
+	var (
+		indexes = make([]uint64, 0, len(items))
+		records = make([][]byte, 0, len(items))
+	)
+
+	for _, item := range items {
+		data, err := item.entry.MarshalRecord()
+		if err != nil {
+			return fmt.Errorf("marshal entry: %w", err)
+		}
+
+		indexes = append(indexes, item.index)
+		records = append(records, data)
+	}
+
+	if err := w.wal.Park(indexes, records); err != nil {
+		return fmt.Errorf("park wal: %w", err)
+	}
+
+	return nil
+}
+
+// replay writes entries that were left in the write-ahead log by the previous run.
+func (w *Writer) replay(ctx context.Context) {
+	if w.wal == nil {
+		return
+	}
+
+	var (
+		batch = make([]queueItem, 0, _replayBatchSize)
+		count int
+	)
+
+	err := w.wal.Replay(func(index uint64, data []byte) error {
+		entry, err := domain.UnmarshalRecord(data)
+		if err != nil {
+			w.logger.Errorf(ctx, "unmarshal wal record %d: %v", index, err)
+			w.ack(ctx, []queueItem{{index: index}})
+
+			return nil
+		}
+
+		batch = append(batch, queueItem{entry: entry, index: index})
+		count++
+
+		if len(batch) == _replayBatchSize {
+			w.flush(ctx, batch)
+
+			batch = make([]queueItem, 0, _replayBatchSize)
+		}
+
+		return nil
+	})
+	if err != nil {
+		w.logger.Errorf(ctx, "replay wal: %v", err)
+	}
+
+	if len(batch) > 0 {
+		w.flush(ctx, batch)
+	}
+
+	if count > 0 {
+		w.logger.Infof(ctx, "replayed %d entries from wal", count)
+	}
+}
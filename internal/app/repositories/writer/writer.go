@@ -0,0 +1,311 @@
+// Package writer queues entries and writes them to a storage backend in batches. It applies the queue
+// overflow policy, keeps queued entries in the write-ahead log, retries failed batches and moves entries
+// that could not be written to the dead letter storage.
+package writer
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/loghole/tracing"
+	"github.com/loghole/tracing/tracelog"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/loghole/collector/internal/app/domain"
+)
+
+// Backend writes batches of entries to a storage. Batches with invalid entries
+// should fail with RowError, such batches are split instead of being retried.
+type Backend interface {
+	Ping(ctx context.Context) error
+	InsertEntryList(ctx context.Context, list []*domain.Entry) error
+}
+
+type Config struct {
+	Capacity int
+	Period   time.Duration
+	// Workers insert batches in parallel, every worker collects its own batch from the shared queue.
+	Workers int
+	// MaxBatchRows and MaxBatchBytes flush the batch before the period, zero disables the limit.
+	MaxBatchRows  int
+	MaxBatchBytes int
+	// MinInterval between flushes limits the rate of small inserts.
+	MinInterval time.Duration
+	// OverflowPolicy is applied when the queue is full, see Policy constants.
+	OverflowPolicy string
+	// OverflowTimeout limits waiting of the block policy, zero waits until the request is canceled.
+	OverflowTimeout time.Duration
+	// WAL keeps queued entries on disk until they are written, nil disables it.
+	WAL   WriteAheadLog
+	Retry RetryConfig
+	// DeadLetters keeps entries that could not be written after all retries, nil disables it.
+	DeadLetters DeadLetterStorage
+}
+
+// WriteAheadLog keeps records on disk until they are acknowledged, see wal.WAL.
+type WriteAheadLog interface {
+	Write(records [][]byte) (first, last uint64, err error)
+	Ack(indexes ...uint64) error
+	Park(indexes []uint64, records [][]byte) error
+	Replay(fn func(index uint64, data []byte) error) error
+	Size() int64
+}
+
+type Writer struct {
+	stats queueStats
+
+	backend Backend
+	logger  tracelog.Logger
+
+	workers       int
+	period        time.Duration
+	maxBatchRows  int
+	maxBatchBytes int
+	minInterval   time.Duration
+
+	queue   chan queueItem
+	policy  string
+	timeout time.Duration
+	wal     WriteAheadLog
+
+	retry       RetryConfig
+	deadLetters DeadLetterStorage
+	stop        chan struct{}
+	stopOnce    sync.Once
+
+	// reserveMu makes the free space check and sending of the reject policy atomic.
+	reserveMu sync.Mutex
+	// mu guards sending to the queue, the queue is closed under the write lock.
+	mu      sync.RWMutex
+	closed  bool
+	running sync.WaitGroup
+}
+
+func New(
+	backend Backend,
+	logger tracelog.Logger,
+	config *Config,
+) (*Writer, error) {
+	if err := validatePolicy(config.OverflowPolicy, config.Capacity); err != nil {
+		return nil, err
+	}
+
+	workers := config.Workers
+	if workers < 1 {
+		workers = 1
+	}
+
+	return &Writer{
+		stats:         queueStats{lastWrite: time.Now()},
+		backend:       backend,
+		logger:        logger,
+		workers:       workers,
+		period:        config.Period,
+		maxBatchRows:  config.MaxBatchRows,
+		maxBatchBytes: config.MaxBatchBytes,
+		minInterval:   config.MinInterval,
+		queue:         make(chan queueItem, config.Capacity),
+		policy:        config.OverflowPolicy,
+		timeout:       config.OverflowTimeout,
+		wal:           config.WAL,
+		retry:         config.Retry,
+		stop:          make(chan struct{}),
+		deadLetters:   config.DeadLetters,
+	}, nil
+}
+
+func (w *Writer) Ping(ctx context.Context) error {
+	defer tracing.ChildSpan(&ctx).Finish()
+
+	return w.backend.Ping(ctx)
+}
+
+// Run replays entries left in the write-ahead log and runs writer workers until Stop.
+func (w *Writer) Run(ctx context.Context) error {
+	w.mu.Lock()
+
+	if !w.closed {
+		w.running.Add(1)
+		defer w.running.Done()
+	}
+
+	w.mu.Unlock()
+
+	w.replay(ctx)
+
+	return w.runWorkers(ctx, w.flush)
+}
+
+// Stop rejects new entries, interrupts retries of failed inserts and waits
+// until every worker has written the queued entries and its batch.
+func (w *Writer) Stop() {
+	w.stopOnce.Do(func() {
+		// Unblock requests waiting for free space in the queue before taking the lock.
+		close(w.stop)
+
+		w.mu.Lock()
+		defer w.mu.Unlock()
+
+		w.closed = true
+		close(w.queue)
+	})
+
+	w.running.Wait()
+}
+
+// StoreEntryList queues entries for writing, entries are appended to the write-ahead log first if it is enabled.
+// If the queue is full entries are handled by the overflow policy, entries that were not queued are committed
+// with the returned error. With the reject policy the list is queued whole or not at all, unless it is longer
+// than the queue capacity: such list is queued while there is free space and the rest is rejected.
+func (w *Writer) StoreEntryList(ctx context.Context, list []*domain.Entry) (err error) {
+	defer tracing.ChildSpan(&ctx).Finish()
+
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	if w.closed {
+		return w.rejectList(list, domain.ErrWriterStopped)
+	}
+
+	if w.policy == PolicyReject && len(list) <= cap(w.queue) {
+		// Workers only take items from the queue, free space can't shrink until the list is queued.
+		w.reserveMu.Lock()
+		defer w.reserveMu.Unlock()
+
+		if cap(w.queue)-len(w.queue) < len(list) {
+			return w.rejectList(list, w.queueFull())
+		}
+	}
+
+	items, err := w.appendLog(list)
+	if err != nil {
+		return w.rejectList(list, err)
+	}
+
+	var timeout <-chan time.Time
+
+	if w.policy == PolicyBlock && w.timeout > 0 {
+		timer := time.NewTimer(w.timeout)
+		defer timer.Stop()
+
+		timeout = timer.C
+	}
+
+	for idx, item := range items {
+		if err := w.enqueue(ctx, item, timeout); err != nil {
+			w.release(ctx, items[idx:], err)
+
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Stats returns the queue state and overflow counters.
+func (w *Writer) Stats() domain.WriterStats {
+	stats := w.stats.snapshot(len(w.queue), cap(w.queue))
+
+	if w.wal != nil {
+		stats.WALSize = w.wal.Size()
+	}
+
+	return stats
+}
+
+// runWorkers runs storeEntryChan in every worker until the queue is closed.
+func (w *Writer) runWorkers(ctx context.Context, flush func(ctx context.Context, items []queueItem)) error {
+	var group errgroup.Group
+
+	for i := 0; i < w.workers; i++ {
+		group.Go(func() error {
+			return w.storeEntryChan(ctx, flush)
+		})
+	}
+
+	return group.Wait()
+}
+
+// storeEntryChan collects queued entries to batches. A batch is flushed after the period since the previous
+// flush or at once when it reaches max rows or max bytes, but not earlier than min interval after the previous flush.
+// The queue is not read while a full batch waits for min interval.
+func (w *Writer) storeEntryChan(ctx context.Context, flush func(ctx context.Context, items []queueItem)) error {
+	var (
+		queue = w.queue
+		timer = time.NewTimer(w.period)
+		last  = time.Now()
+
+		cache = make([]queueItem, 0)
+		size  int
+	)
+
+	defer timer.Stop()
+
+	flushCache := func() {
+		if len(cache) > 0 {
+			flush(ctx, cache)
+
+			cache, size, last = make([]queueItem, 0, len(cache)), 0, time.Now()
+		}
+
+		queue = w.queue
+
+		resetTimer(timer, w.period)
+	}
+
+	for {
+		select {
+		case <-timer.C:
+			flushCache()
+		case item, ok := <-queue:
+			if !ok {
+				if len(cache) > 0 {
+					flush(ctx, cache)
+				}
+
+				return nil
+			}
+
+			cache = append(cache, item)
+			size += item.entry.Size()
+
+			if !w.batchFull(len(cache), size) {
+				continue
+			}
+
+			if wait := w.minInterval - time.Since(last); wait > 0 {
+				queue = nil
+
+				resetTimer(timer, wait)
+
+				continue
+			}
+
+			flushCache()
+		}
+	}
+}
+
+func (w *Writer) batchFull(rows, size int) bool {
+	return (w.maxBatchRows > 0 && rows >= w.maxBatchRows) || (w.maxBatchBytes > 0 && size >= w.maxBatchBytes)
+}
+
+// resetTimer resets timer that may be fired and not drained.
+func resetTimer(timer *time.Timer, d time.Duration) {
+	if !timer.Stop() {
+		select {
+		case <-timer.C:
+		default:
+		}
+	}
+
+	timer.Reset(d)
+}
+
+// flush inserts entries, entries that could not be written are moved to the dead letter storage.
+func (w *Writer) flush(ctx context.Context, items []queueItem) {
+	if failed, err := w.insert(ctx, items); len(failed) > 0 {
+		w.deadLetter(ctx, failed, err)
+	}
+}
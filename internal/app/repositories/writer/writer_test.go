@@ -0,0 +1,157 @@
+package writer
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/loghole/collector/internal/app/domain"
+)
+
+func TestWriter_storeEntryChan(t *testing.T) {
+	tests := []struct {
+		name          string
+		config        *Config
+		entries       int
+		wait          time.Duration
+		expectedSizes []int
+		minGap        time.Duration
+	}{
+		{
+			name:          "MaxRowsPass",
+			config:        &Config{Capacity: 10, Period: time.Hour, MaxBatchRows: 2},
+			entries:       5,
+			expectedSizes: []int{2, 2, 1},
+		},
+		{
+			name:          "MaxBytesPass",
+			config:        &Config{Capacity: 10, Period: time.Hour, MaxBatchBytes: 100},
+			entries:       5,
+			expectedSizes: []int{3, 2},
+		},
+		{
+			name:          "MinIntervalPass",
+			config:        &Config{Capacity: 10, Period: time.Hour, MaxBatchRows: 1, MinInterval: 30 * time.Millisecond},
+			entries:       3,
+			expectedSizes: []int{1, 1, 1},
+			minGap:        30 * time.Millisecond,
+		},
+		{
+			name:          "PeriodPass",
+			config:        &Config{Capacity: 10, Period: 10 * time.Millisecond},
+			entries:       3,
+			wait:          50 * time.Millisecond,
+			expectedSizes: []int{3},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.config.OverflowPolicy = PolicyBlock
+
+			w, err := New(nil, nil, tt.config)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var (
+				mu    sync.Mutex
+				sizes = make([]int, 0)
+				times = make([]time.Time, 0)
+				done  = make(chan struct{})
+			)
+
+			go func() {
+				defer close(done)
+
+				_ = w.storeEntryChan(context.Background(), func(ctx context.Context, items []queueItem) {
+					mu.Lock()
+					defer mu.Unlock()
+
+					sizes = append(sizes, len(items))
+					times = append(times, time.Now())
+				})
+			}()
+
+			for i := 0; i < tt.entries; i++ {
+				// Every entry is 39 bytes.
+				w.queue <- queueItem{entry: &domain.Entry{Message: "message"}}
+			}
+
+			time.Sleep(tt.wait)
+			w.Stop()
+			<-done
+
+			assert.Equal(t, tt.expectedSizes, sizes)
+
+			for i := 1; i < len(times); i++ {
+				assert.GreaterOrEqual(t, times[i].Sub(times[i-1]), tt.minGap)
+			}
+		})
+	}
+}
+
+func TestWriter_Stop(t *testing.T) {
+	w, err := New(nil, nil, &Config{
+		Capacity:       10,
+		Period:         time.Hour,
+		Workers:        3,
+		MaxBatchRows:   4,
+		OverflowPolicy: PolicyBlock,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		accepted uint64
+		written  uint64
+		done     = make(chan struct{})
+		senders  sync.WaitGroup
+	)
+
+	go func() {
+		defer close(done)
+
+		_ = w.runWorkers(context.Background(), func(ctx context.Context, items []queueItem) {
+			atomic.AddUint64(&written, uint64(len(items)))
+		})
+	}()
+
+	// Requests keep coming while the writer is stopped.
+	for i := 0; i < 5; i++ {
+		senders.Add(1)
+
+		go func() {
+			defer senders.Done()
+
+			for {
+				err := w.StoreEntryList(context.Background(), []*domain.Entry{{Message: "message"}})
+
+				switch {
+				case err == nil:
+					atomic.AddUint64(&accepted, 1)
+				case errors.Is(err, domain.ErrWriterStopped):
+					return
+				default:
+					t.Error(err)
+
+					return
+				}
+			}
+		}()
+	}
+
+	time.Sleep(10 * time.Millisecond)
+	w.Stop()
+	senders.Wait()
+	<-done
+
+	assert.NotZero(t, accepted)
+	assert.Equal(t, accepted, written)
+	assert.ErrorIs(t, w.StoreEntryList(context.Background(), []*domain.Entry{{}}), domain.ErrWriterStopped)
+}
@@ -0,0 +1,127 @@
+package ack
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+var ErrTooManyPending = errors.New("too many pending acks")
+
+// Registry keeps indexer acknowledgement ids per data channel.
+// An ack becomes true after all entries of the request were committed to the storage,
+// it is removed after it was reported as true or when its channel is idle longer than ttl.
+type Registry struct {
+	mu         sync.Mutex
+	channels   map[string]*channel
+	maxPending int
+	ttl        time.Duration
+	cleanedAt  time.Time
+}
+
+type channel struct {
+	nextID    uint64
+	acks      map[uint64]bool
+	updatedAt time.Time
+}
+
+func NewRegistry(maxPending int, ttl time.Duration) *Registry {
+	return &Registry{
+		channels:   make(map[string]*channel),
+		maxPending: maxPending,
+		ttl:        ttl,
+		cleanedAt:  time.Now(),
+	}
+}
+
+// Register returns new ack id for the channel.
+func (r *Registry) Register(channelID string) (uint64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+
+	if now.Sub(r.cleanedAt) > r.ttl {
+		r.cleanup(now)
+	}
+
+	ch, ok := r.channels[channelID]
+	if !ok {
+		ch = &channel{acks: make(map[uint64]bool)}
+		r.channels[channelID] = ch
+	}
+
+	if len(ch.acks) >= r.maxPending {
+		return 0, ErrTooManyPending
+	}
+
+	id := ch.nextID
+
+	ch.nextID++
+	ch.acks[id] = false
+	ch.updatedAt = now
+
+	return id, nil
+}
+
+// Commit sets ack result, failed acks are forgotten and will be reported as false.
+func (r *Registry) Commit(channelID string, id uint64, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	ch, ok := r.channels[channelID]
+	if !ok {
+		return
+	}
+
+	if _, ok := ch.acks[id]; !ok {
+		return
+	}
+
+	if err != nil {
+		delete(ch.acks, id)
+
+		return
+	}
+
+	ch.acks[id] = true
+}
+
+// Query returns status of the acks, acks reported as true are removed.
+func (r *Registry) Query(channelID string, ids []uint64) map[uint64]bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	result := make(map[uint64]bool, len(ids))
+
+	ch, ok := r.channels[channelID]
+	if !ok {
+		for _, id := range ids {
+			result[id] = false
+		}
+
+		return result
+	}
+
+	ch.updatedAt = time.Now()
+
+	for _, id := range ids {
+		result[id] = ch.acks[id]
+
+		if result[id] {
+			delete(ch.acks, id)
+		}
+	}
+
+	return result
+}
+
+func (r *Registry) cleanup(now time.Time) {
+	for id, ch := range r.channels {
+		if now.Sub(ch.updatedAt) > r.ttl {
+			delete(r.channels, id)
+		}
+	}
+
+	r.cleanedAt = now
+}
@@ -0,0 +1,110 @@
+package ack
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	_channel      = "0ce8e7a6-2f37-4b13-b2ea-3e0a4a8c2b55"
+	_otherChannel = "b5bcd1d0-5b0e-4a47-9f07-6e0b0c8d3f1a"
+)
+
+func TestRegistry_MaxPending(t *testing.T) {
+	registry := NewRegistry(2, time.Minute)
+
+	first, err := registry.Register(_channel)
+	assert.NoError(t, err)
+
+	second, err := registry.Register(_channel)
+	assert.NoError(t, err)
+	assert.NotEqual(t, first, second)
+
+	_, err = registry.Register(_channel)
+	assert.ErrorIs(t, err, ErrTooManyPending)
+
+	// Other channels have their own limit.
+	_, err = registry.Register(_otherChannel)
+	assert.NoError(t, err)
+
+	// Committed ack is pending until it was reported.
+	registry.Commit(_channel, first, nil)
+
+	_, err = registry.Register(_channel)
+	assert.ErrorIs(t, err, ErrTooManyPending)
+
+	assert.Equal(t, map[uint64]bool{first: true, second: false}, registry.Query(_channel, []uint64{first, second}))
+
+	third, err := registry.Register(_channel)
+	assert.NoError(t, err)
+	assert.NotEqual(t, first, third)
+}
+
+func TestRegistry_Commit(t *testing.T) {
+	tests := []struct {
+		name        string
+		channel     string
+		err         error
+		expectedRes map[uint64]bool
+		expectedErr error
+	}{
+		{
+			name:        "Pass",
+			channel:     _channel,
+			expectedRes: map[uint64]bool{0: true, 1: false},
+		},
+		{
+			name:        "FailedCommit",
+			channel:     _channel,
+			err:         errors.New("insert failed"),
+			expectedRes: map[uint64]bool{0: false, 1: false},
+		},
+		{
+			name:        "ChannelMismatch",
+			channel:     _otherChannel,
+			expectedRes: map[uint64]bool{0: false, 1: false},
+			expectedErr: ErrTooManyPending,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			registry := NewRegistry(1, time.Minute)
+
+			id, err := registry.Register(_channel)
+			assert.NoError(t, err)
+
+			registry.Commit(tt.channel, id, tt.err)
+			registry.Commit(_channel, id+1, nil)
+
+			assert.Equal(t, tt.expectedRes, registry.Query(_channel, []uint64{id, id + 1}))
+
+			// Reported and failed acks are forgotten and free their place.
+			_, err = registry.Register(_channel)
+			assert.ErrorIs(t, err, tt.expectedErr)
+		})
+	}
+}
+
+func TestRegistry_TTL(t *testing.T) {
+	registry := NewRegistry(1, 20*time.Millisecond)
+
+	idle, err := registry.Register(_channel)
+	assert.NoError(t, err)
+
+	registry.Commit(_channel, idle, nil)
+
+	time.Sleep(30 * time.Millisecond)
+
+	// Idle channels are removed by the next registration.
+	_, err = registry.Register(_otherChannel)
+	assert.NoError(t, err)
+
+	assert.Equal(t, map[uint64]bool{idle: false}, registry.Query(_channel, []uint64{idle}))
+
+	id, err := registry.Register(_channel)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(0), id)
+}
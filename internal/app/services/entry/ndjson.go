@@ -0,0 +1,153 @@
+package entry
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+
+	"github.com/lissteron/simplerr"
+	"github.com/loghole/tracing"
+
+	"github.com/loghole/collector/internal/app/codes"
+	"github.com/loghole/collector/internal/app/domain"
+)
+
+const (
+	_ndjsonBatchSize   = 1000
+	_ndjsonMaxLineSize = 10 << 20
+	_ndjsonReaderSize  = 64 << 10
+	_ndjsonMaxFailures = 100
+)
+
+var ErrLineTooLong = errors.New("line is too long")
+
+// StoreNDJSON reads newline delimited json objects and stores them in batches while the body is read.
+// Invalid lines are rejected and reported by line number, reading stops on the first store error.
+// If the body can't be read to the end, for example it exceeds the size limit, the lines read before
+// are stored and the line where reading stopped is reported as a failure, so the client can resend
+// the rest of the body only.
+func (s *Service) StoreNDJSON(ctx context.Context, remoteIP string, r io.Reader) (*domain.StoreResult, error) {
+	defer tracing.ChildSpan(&ctx).Finish()
+
+	var (
+		result = &domain.StoreResult{}
+		reader = bufio.NewReaderSize(r, _ndjsonReaderSize)
+		batch  = make(domain.EntryList, 0, _ndjsonBatchSize)
+	)
+
+	for number := 1; ; number++ {
+		line, err := readLine(reader, _ndjsonMaxLineSize)
+
+		switch {
+		case errors.Is(err, io.EOF):
+			return result, s.storeBatch(ctx, remoteIP, batch, result)
+		case errors.Is(err, ErrLineTooLong):
+			result.Reject(number, entryErrorCode(err), err, _ndjsonMaxFailures)
+
+			continue
+		case err != nil:
+			s.logger.Errorf(ctx, "read body failed: %v", err)
+
+			if err := s.storeBatch(ctx, remoteIP, batch, result); err != nil {
+				return result, err
+			}
+
+			result.Reject(number, codes.SystemError, err, 0)
+
+			return result, simplerr.WrapWithCode(err, simplerr.InternalCode(codes.SystemError), "read body failed")
+		}
+
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+
+		entry, err := parseLine(line)
+		if err != nil {
+			result.Reject(number, entryErrorCode(err), err, _ndjsonMaxFailures)
+
+			continue
+		}
+
+		if batch = append(batch, entry); len(batch) < _ndjsonBatchSize {
+			continue
+		}
+
+		if err := s.storeBatch(ctx, remoteIP, batch, result); err != nil {
+			return result, err
+		}
+
+		batch = make(domain.EntryList, 0, _ndjsonBatchSize)
+	}
+}
+
+func (s *Service) storeBatch(
+	ctx context.Context,
+	remoteIP string,
+	batch domain.EntryList,
+	result *domain.StoreResult,
+) error {
+	if len(batch) == 0 {
+		return nil
+	}
+
+	if err := s.StoreEntryList(ctx, remoteIP, batch); err != nil {
+		return err
+	}
+
+	result.Accepted += len(batch)
+
+	return nil
+}
+
+func parseLine(line []byte) (*domain.Entry, error) {
+	// Entry keeps the data as params, line buffer is reused by the reader.
+	data := append([]byte(nil), bytes.TrimSpace(line)...)
+
+	if data[0] != '{' {
+		return nil, domain.ErrObjectExpected
+	}
+
+	entry := &domain.Entry{}
+
+	if err := entry.UnmarshalJSON(data); err != nil {
+		return nil, err
+	}
+
+	return entry, nil
+}
+
+// readLine reads line without the delimiter. Line longer than max size is skipped and ErrLineTooLong is returned.
+func readLine(reader *bufio.Reader, maxSize int) ([]byte, error) {
+	var (
+		line    []byte
+		tooLong bool
+	)
+
+	for {
+		chunk, err := reader.ReadSlice('\n')
+
+		if len(line)+len(chunk) > maxSize+1 {
+			tooLong, line = true, nil
+		} else if !tooLong {
+			line = append(line, chunk...)
+		}
+
+		if errors.Is(err, bufio.ErrBufferFull) {
+			continue
+		}
+
+		if err != nil && (!errors.Is(err, io.EOF) || (len(line) == 0 && !tooLong)) {
+			return nil, err
+		}
+
+		break
+	}
+
+	if tooLong {
+		return nil, ErrLineTooLong
+	}
+
+	return bytes.TrimSuffix(line, []byte{'\n'}), nil
+}
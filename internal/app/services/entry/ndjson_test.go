@@ -0,0 +1,112 @@
+package entry
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/loghole/tracing/tracelog"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+
+	"github.com/loghole/collector/internal/app/codes"
+	"github.com/loghole/collector/internal/app/domain"
+)
+
+type storageMock struct {
+	lists []domain.EntryList
+	// commit receives stored lists if it is set.
+	commit chan domain.EntryList
+}
+
+func (s *storageMock) Ping(ctx context.Context) error {
+	return nil
+}
+
+func (s *storageMock) Stats() domain.WriterStats {
+	return domain.WriterStats{}
+}
+
+func (s *storageMock) StoreEntryList(ctx context.Context, list []*domain.Entry) error {
+	s.lists = append(s.lists, list)
+
+	if s.commit != nil {
+		s.commit <- list
+	}
+
+	return nil
+}
+
+func TestReadLine(t *testing.T) {
+	reader := bufio.NewReaderSize(strings.NewReader("abc\r\n"+strings.Repeat("x", 40)+"\n\nlast"), 16)
+
+	var (
+		lines = make([]string, 0)
+		errs  = make([]error, 0)
+	)
+
+	for {
+		line, err := readLine(reader, 20)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		lines, errs = append(lines, string(line)), append(errs, err)
+	}
+
+	assert.Equal(t, []string{"abc\r", "", "", "last"}, lines)
+	assert.Equal(t, []error{nil, ErrLineTooLong, nil, nil}, errs)
+}
+
+func TestService_StoreNDJSON(t *testing.T) {
+	var (
+		storage = &storageMock{}
+		service = NewService(storage, nil)
+		body    = strings.Join([]string{
+			`{"message":"first","level":"info"}`,
+			``,
+			`not json`,
+			`[1, 2]`,
+			`{"message":"second"}`,
+		}, "\n")
+	)
+
+	result, err := service.StoreNDJSON(context.Background(), "127.0.0.1", strings.NewReader(body))
+	assert.NoError(t, err)
+
+	assert.Equal(t, 2, result.Accepted)
+	assert.Equal(t, 2, result.Rejected)
+	assert.Equal(t, []int{3, 4}, []int{result.Failures[0].Number, result.Failures[1].Number})
+
+	assert.Len(t, storage.lists, 1)
+	assert.Equal(t, "second", storage.lists[0][1].Message)
+	assert.Equal(t, "127.0.0.1", storage.lists[0][1].RemoteIP)
+}
+
+func TestService_StoreNDJSON_ReadError(t *testing.T) {
+	var (
+		errRead = errors.New("request body too large")
+		storage = &storageMock{}
+		service = NewService(storage, tracelog.NewTraceLogger(zap.NewNop().Sugar()))
+		body    = io.MultiReader(
+			strings.NewReader("{\"message\":\"first\"}\nnot json\n{\"message\":\"second\"}\n{\"message\":"),
+			iotest.ErrReader(errRead),
+		)
+	)
+
+	result, err := service.StoreNDJSON(context.Background(), "127.0.0.1", body)
+	assert.ErrorIs(t, err, errRead)
+
+	// Lines read before the error are stored, the line where reading stopped is reported.
+	assert.Equal(t, 2, result.Accepted)
+	assert.Equal(t, 2, result.Rejected)
+	assert.Equal(t, []int{2, 4}, []int{result.Failures[0].Number, result.Failures[1].Number})
+	assert.Equal(t, codes.SystemError, result.Failures[1].Code)
+
+	assert.Len(t, storage.lists, 1)
+	assert.Len(t, storage.lists[0], 2)
+}
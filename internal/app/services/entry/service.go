@@ -2,6 +2,7 @@ package entry
 
 import (
 	"context"
+	"errors"
 
 	"github.com/lissteron/simplerr"
 	"github.com/loghole/tracing"
@@ -11,9 +12,12 @@ import (
 	"github.com/loghole/collector/internal/app/domain"
 )
 
+var ErrInvalidEntries = errors.New("invalid entries")
+
 type Storage interface {
 	Ping(ctx context.Context) error
 	StoreEntryList(ctx context.Context, list []*domain.Entry) (err error)
+	Stats() domain.WriterStats
 }
 
 type Service struct {
@@ -40,7 +44,15 @@ func (s *Service) Ping(ctx context.Context) error {
 	return nil
 }
 
-func (s *Service) StoreItem(ctx context.Context, remoteIP string, data []byte) (err error) {
+// Stats returns the writer queue state and overflow counters.
+func (s *Service) Stats(ctx context.Context) domain.WriterStats {
+	defer tracing.ChildSpan(&ctx).Finish()
+
+	return s.storage.Stats()
+}
+
+// StoreItem stores json object of entry, in sync mode it returns after the entry was written.
+func (s *Service) StoreItem(ctx context.Context, remoteIP string, data []byte, sync bool) (err error) {
 	defer tracing.ChildSpan(&ctx).Finish()
 
 	entry, err := s.parseEntryItem(ctx, data)
@@ -52,17 +64,18 @@ func (s *Service) StoreItem(ctx context.Context, remoteIP string, data []byte) (
 
 	entry.SetRemoteIP(remoteIP)
 
-	err = s.storage.StoreEntryList(ctx, []*domain.Entry{entry})
+	err = s.store(ctx, domain.EntryList{entry}, sync)
 	if err != nil {
 		s.logger.Errorf(ctx, "store entry list failed: %v", err)
 
-		return simplerr.WrapWithCode(err, simplerr.InternalCode(codes.DatabaseError), "store failed")
+		return storeError(err)
 	}
 
 	return nil
 }
 
-func (s *Service) StoreList(ctx context.Context, remoteIP string, data []byte) (err error) {
+// StoreList stores json array of entries, in sync mode it returns after the entries were written.
+func (s *Service) StoreList(ctx context.Context, remoteIP string, data []byte, sync bool) (err error) {
 	defer tracing.ChildSpan(&ctx).Finish()
 
 	list, err := s.parseEntryList(ctx, data)
@@ -74,16 +87,116 @@ func (s *Service) StoreList(ctx context.Context, remoteIP string, data []byte) (
 
 	list.SetRemoteIP(remoteIP)
 
-	err = s.storage.StoreEntryList(ctx, list)
+	err = s.store(ctx, list, sync)
 	if err != nil {
 		s.logger.Errorf(ctx, "store entry list failed: %v", err)
 
-		return simplerr.WrapWithCode(err, simplerr.InternalCode(codes.DatabaseError), "store failed")
+		return storeError(err)
 	}
 
 	return nil
 }
 
+// StoreListResult stores json array of entries like StoreList, rejected elements are reported in the result
+// instead of being dropped. In strict mode nothing is stored if any element is rejected.
+func (s *Service) StoreListResult(
+	ctx context.Context,
+	remoteIP string,
+	data []byte,
+	strict bool,
+	sync bool,
+) (*domain.StoreResult, error) {
+	defer tracing.ChildSpan(&ctx).Finish()
+
+	var (
+		result = &domain.StoreResult{}
+		list   = make(domain.EntryList, 0)
+	)
+
+	err := domain.EachEntry(data, func(index int, entry *domain.Entry, err error) {
+		if err != nil {
+			result.Reject(index, entryErrorCode(err), err, 0)
+
+			return
+		}
+
+		list = append(list, entry)
+	})
+	if err != nil {
+		s.logger.Errorf(ctx, "parse entry list failed: %v", err)
+
+		return nil, simplerr.WrapWithCode(err, simplerr.InvalidArgumentCode(codes.UnmarshalError), "parse json failed")
+	}
+
+	if strict && result.Rejected > 0 {
+		return result, simplerr.WrapWithCode(ErrInvalidEntries, simplerr.InvalidArgumentCode(codes.ValidationError),
+			"list has invalid entries")
+	}
+
+	if len(list) > 0 {
+		if err := s.storeEntryList(ctx, remoteIP, list, sync); err != nil {
+			return result, err
+		}
+	}
+
+	result.Accepted = len(list)
+
+	return result, nil
+}
+
+func (s *Service) StoreEntryList(ctx context.Context, remoteIP string, list domain.EntryList) (err error) {
+	defer tracing.ChildSpan(&ctx).Finish()
+
+	return s.storeEntryList(ctx, remoteIP, list, false)
+}
+
+// StoreEntryListSync stores parsed entries and returns after they were written.
+func (s *Service) StoreEntryListSync(ctx context.Context, remoteIP string, list domain.EntryList) error {
+	defer tracing.ChildSpan(&ctx).Finish()
+
+	return s.storeEntryList(ctx, remoteIP, list, true)
+}
+
+func (s *Service) storeEntryList(ctx context.Context, remoteIP string, list domain.EntryList, sync bool) error {
+	list.SetRemoteIP(remoteIP)
+
+	if err := s.store(ctx, list, sync); err != nil {
+		s.logger.Errorf(ctx, "store entry list failed: %v", err)
+
+		return storeError(err)
+	}
+
+	return nil
+}
+
+// store queues entries for writing. In sync mode it waits until every entry was written
+// and returns the first write error.
+func (s *Service) store(ctx context.Context, list domain.EntryList, sync bool) error {
+	if !sync {
+		return s.storage.StoreEntryList(ctx, list)
+	}
+
+	var (
+		done   = make(chan error, 1)
+		commit = domain.NewCommit(func(err error) { done <- err })
+	)
+
+	commit.Attach(list)
+
+	if err := s.storage.StoreEntryList(ctx, list); err != nil {
+		return err
+	}
+
+	commit.Close(nil)
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
 func (s *Service) parseEntryItem(ctx context.Context, data []byte) (*domain.Entry, error) {
 	defer tracing.ChildSpan(&ctx).Finish()
 
@@ -107,3 +220,27 @@ func (s *Service) parseEntryList(ctx context.Context, data []byte) (domain.Entry
 
 	return list, nil
 }
+
+func entryErrorCode(err error) int {
+	if errors.Is(err, domain.ErrObjectExpected) || errors.Is(err, ErrLineTooLong) {
+		return codes.ValidationError
+	}
+
+	return codes.UnmarshalError
+}
+
+func storeError(err error) error {
+	if errors.Is(err, domain.ErrQueueFull) {
+		return simplerr.WrapWithCode(err, codes.UnavailableCode(codes.QueueFullError), "writer queue is full")
+	}
+
+	if errors.Is(err, domain.ErrListTooLarge) {
+		return simplerr.WrapWithCode(err, codes.TooLargeCode(codes.ValidationError), "entry list is too large")
+	}
+
+	if errors.Is(err, domain.ErrWriterStopped) {
+		return simplerr.WrapWithCode(err, codes.UnavailableCode(codes.SystemError), "writer is stopped")
+	}
+
+	return simplerr.WrapWithCode(err, simplerr.InternalCode(codes.DatabaseError), "store failed")
+}
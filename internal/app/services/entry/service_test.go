@@ -0,0 +1,115 @@
+package entry
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/loghole/tracing/tracelog"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+
+	"github.com/loghole/collector/internal/app/codes"
+	"github.com/loghole/collector/internal/app/domain"
+)
+
+func TestService_StoreListResult(t *testing.T) {
+	const data = `[{"message":"first"},"text",{"time":1},{"message":"second"}]`
+
+	tests := []struct {
+		name             string
+		strict           bool
+		wantErr          bool
+		expectedAccepted int
+		expectedStored   int
+	}{
+		{
+			name:             "ReportPass",
+			expectedAccepted: 2,
+			expectedStored:   1,
+		},
+		{
+			name:    "StrictError",
+			strict:  true,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				storage = &storageMock{}
+				service = NewService(storage, nil)
+			)
+
+			result, err := service.StoreListResult(context.Background(), "127.0.0.1", []byte(data), tt.strict, false)
+			if (err != nil) != tt.wantErr {
+				t.Error(err)
+			}
+
+			assert.Equal(t, tt.expectedAccepted, result.Accepted)
+			assert.Equal(t, 2, result.Rejected)
+			assert.Len(t, storage.lists, tt.expectedStored)
+
+			assert.Equal(t, 1, result.Failures[0].Number)
+			assert.Equal(t, codes.ValidationError, result.Failures[0].Code)
+			assert.Equal(t, 2, result.Failures[1].Number)
+			assert.Equal(t, codes.UnmarshalError, result.Failures[1].Code)
+		})
+	}
+}
+
+func TestService_StoreList_Sync(t *testing.T) {
+	errInsert := errors.New("insert failed")
+
+	tests := []struct {
+		name      string
+		sync      bool
+		commitErr error
+		wantErr   error
+	}{
+		{
+			name:      "AsyncPass",
+			commitErr: errInsert,
+		},
+		{
+			name: "SyncPass",
+			sync: true,
+		},
+		{
+			name:      "SyncError",
+			sync:      true,
+			commitErr: errInsert,
+			wantErr:   errInsert,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				storage = &storageMock{commit: make(chan domain.EntryList, 1)}
+				service = NewService(storage, tracelog.NewTraceLogger(zap.NewNop().Sugar()))
+				done    = make(chan error)
+			)
+
+			go func() {
+				done <- service.StoreList(context.Background(), "127.0.0.1", []byte(`[{"message":"first"}]`), tt.sync)
+			}()
+
+			list := <-storage.commit
+
+			if tt.sync {
+				select {
+				case err := <-done:
+					t.Fatalf("returned before commit: %v", err)
+				case <-time.After(10 * time.Millisecond):
+				}
+			}
+
+			for _, entry := range list {
+				entry.Committed(tt.commitErr)
+			}
+
+			assert.ErrorIs(t, <-done, tt.wantErr)
+		})
+	}
+}
@@ -0,0 +1,106 @@
+package fileutil
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Compression of files.
+const (
+	CompressionNone = "none"
+	CompressionGzip = "gzip"
+	CompressionZstd = "zstd"
+)
+
+// Extensions of compressed files.
+const (
+	GzipExt = ".gz"
+	ZstdExt = ".zst"
+)
+
+var ErrInvalidCompression = errors.New("invalid compression")
+
+// CompressedExt returns the file extension of the compression, it is empty without compression.
+func CompressedExt(compression string) (string, error) {
+	switch compression {
+	case CompressionNone, "":
+		return "", nil
+	case CompressionGzip:
+		return GzipExt, nil
+	case CompressionZstd:
+		return ZstdExt, nil
+	default:
+		return "", fmt.Errorf("%w: %q", ErrInvalidCompression, compression)
+	}
+}
+
+// Compress writes compressed src to dst, without compression src is copied as is.
+func Compress(dst io.Writer, src io.Reader, compression string) error {
+	var encoder io.WriteCloser
+
+	switch compression {
+	case CompressionNone, "":
+		_, err := io.Copy(dst, src)
+
+		return err
+	case CompressionGzip:
+		encoder = gzip.NewWriter(dst)
+	case CompressionZstd:
+		zstdEncoder, err := zstd.NewWriter(dst, zstd.WithEncoderConcurrency(1))
+		if err != nil {
+			return err
+		}
+
+		encoder = zstdEncoder
+	default:
+		return fmt.Errorf("%w: %q", ErrInvalidCompression, compression)
+	}
+
+	if _, err := io.Copy(encoder, src); err != nil {
+		encoder.Close()
+
+		return err
+	}
+
+	return encoder.Close()
+}
+
+// CompressFile writes compressed copy of src to dst, dst appears after it was written completely.
+// Source file is kept.
+func CompressFile(src, dst, compression string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+
+	defer in.Close()
+
+	out, err := os.OpenFile(dst+TmpExt, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, _filePerm)
+	if err != nil {
+		return err
+	}
+
+	if err := Compress(out, in, compression); err != nil {
+		out.Close()
+		os.Remove(out.Name())
+
+		return fmt.Errorf("compress %s: %w", src, err)
+	}
+
+	if err := out.Sync(); err != nil {
+		out.Close()
+
+		return err
+	}
+
+	if err := out.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(dst+TmpExt, dst)
+}
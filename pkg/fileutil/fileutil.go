@@ -0,0 +1,56 @@
+// Package fileutil contains helpers shared by storages writing files: compression, file names
+// safe to build from entry fields and synced writes.
+package fileutil
+
+import (
+	"os"
+	"strings"
+)
+
+// TmpExt is the extension of files being written, they are renamed after they were written completely.
+const TmpExt = ".tmp"
+
+const (
+	_emptyName = "_"
+	_filePerm  = 0o644
+)
+
+// PathName returns value safe to use as a directory name or a key part.
+func PathName(value string) string {
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_', r == '.':
+			return r
+		default:
+			return '_'
+		}
+	}, value)
+
+	if name == "" || strings.Trim(name, ".") == "" {
+		return _emptyName
+	}
+
+	return name
+}
+
+// WriteFile writes data to the file and syncs it to disk.
+func WriteFile(path string, data []byte) error {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, _filePerm)
+	if err != nil {
+		return err
+	}
+
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+
+		return err
+	}
+
+	if err := file.Sync(); err != nil {
+		file.Close()
+
+		return err
+	}
+
+	return file.Close()
+}
@@ -0,0 +1,70 @@
+package fileutil
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPathName(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected string
+	}{
+		{value: "app-1.prod", expected: "app-1.prod"},
+		{value: "../etc/passwd", expected: ".._etc_passwd"},
+		{value: "..", expected: "_"},
+		{value: "", expected: "_"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			assert.Equal(t, tt.expected, PathName(tt.value))
+		})
+	}
+}
+
+func TestCompress(t *testing.T) {
+	tests := []struct {
+		compression string
+		decode      func(r io.Reader) (io.Reader, error)
+	}{
+		{
+			compression: CompressionNone,
+			decode:      func(r io.Reader) (io.Reader, error) { return r, nil },
+		},
+		{
+			compression: CompressionGzip,
+			decode:      func(r io.Reader) (io.Reader, error) { return gzip.NewReader(r) },
+		},
+		{
+			compression: CompressionZstd,
+			decode:      func(r io.Reader) (io.Reader, error) { return zstd.NewReader(r) },
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.compression, func(t *testing.T) {
+			var buf bytes.Buffer
+
+			if err := Compress(&buf, strings.NewReader("message\n"), tt.compression); err != nil {
+				t.Fatal(err)
+			}
+
+			reader, err := tt.decode(&buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			data, err := io.ReadAll(reader)
+
+			assert.NoError(t, err)
+			assert.Equal(t, "message\n", string(data))
+		})
+	}
+
+	assert.ErrorIs(t, Compress(io.Discard, strings.NewReader(""), "lz4"), ErrInvalidCompression)
+}
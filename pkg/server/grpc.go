@@ -0,0 +1,97 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	_ "google.golang.org/grpc/encoding/gzip" // nolint:gci // register gzip compressor
+)
+
+var ErrTLSKeyPair = errors.New("both tls cert and key are required")
+
+type GRPCConfig struct {
+	Addr           string
+	MaxRecvMsgSize int
+	TLSCertFile    string
+	TLSKeyFile     string
+}
+
+type GRPC struct {
+	config *GRPCConfig
+	server *grpc.Server
+}
+
+func NewGRPC(config *GRPCConfig, opts ...grpc.ServerOption) (*GRPC, error) {
+	if config.MaxRecvMsgSize > 0 {
+		opts = append(opts, grpc.MaxRecvMsgSize(config.MaxRecvMsgSize))
+	}
+
+	if (config.TLSCertFile == "") != (config.TLSKeyFile == "") {
+		return nil, ErrTLSKeyPair
+	}
+
+	if config.TLSCertFile != "" {
+		creds, err := credentials.NewServerTLSFromFile(config.TLSCertFile, config.TLSKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("load tls credentials: %w", err)
+		}
+
+		opts = append(opts, grpc.Creds(creds))
+	}
+
+	server := &GRPC{
+		config: config,
+		server: grpc.NewServer(opts...),
+	}
+
+	return server, nil
+}
+
+func (g *GRPC) ListenAndServe() error {
+	listener, err := net.Listen("tcp", g.config.Addr)
+	if err != nil {
+		return err
+	}
+
+	if err := g.server.Serve(listener); err != nil && !errors.Is(err, grpc.ErrServerStopped) {
+		return err
+	}
+
+	return nil
+}
+
+func (g *GRPC) Server() *grpc.Server {
+	return g.server
+}
+
+// Addr returns server address, it is empty if the server is disabled.
+func (g *GRPC) Addr() string {
+	if g.config.Addr == "" {
+		return ""
+	}
+
+	return fmt.Sprintf("grpc://%s", g.config.Addr)
+}
+
+// Shutdown stops the server gracefully, pending rpcs are canceled after ctx is done.
+func (g *GRPC) Shutdown(ctx context.Context) error {
+	done := make(chan struct{})
+
+	go func() {
+		g.server.GracefulStop()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		g.server.Stop()
+
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,251 @@
+package server
+
+import (
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+
+	"golang.org/x/sync/errgroup"
+)
+
+const _maxPacketSize = 65535
+
+// StreamConfig of raw network listeners, listener is disabled if its address is empty.
+type StreamConfig struct {
+	UDPAddr     string
+	TCPAddr     string
+	TLSAddr     string
+	TLSCertFile string
+	TLSKeyFile  string
+}
+
+// StreamHandler handles UDP packets and TCP or TLS connections.
+type StreamHandler interface {
+	ServePacket(addr net.Addr, data []byte)
+	// ServeConn reads conn until it is closed, the connection is closed on shutdown.
+	ServeConn(conn net.Conn)
+}
+
+// Stream serves raw UDP, TCP and TLS listeners.
+type Stream struct {
+	config  *StreamConfig
+	handler StreamHandler
+
+	mu        sync.Mutex
+	closed    bool
+	listeners []io.Closer
+	conns     map[net.Conn]struct{}
+	wg        sync.WaitGroup
+}
+
+func NewStream(config *StreamConfig, handler StreamHandler) *Stream {
+	return &Stream{
+		config:  config,
+		handler: handler,
+		conns:   make(map[net.Conn]struct{}),
+	}
+}
+
+// Addr returns addresses of enabled listeners, it is empty if all listeners are disabled.
+func (s *Stream) Addr() string {
+	addrs := make([]string, 0)
+
+	for _, listener := range []struct{ network, addr string }{
+		{"udp", s.config.UDPAddr},
+		{"tcp", s.config.TCPAddr},
+		{"tls", s.config.TLSAddr},
+	} {
+		if listener.addr != "" {
+			addrs = append(addrs, fmt.Sprintf("%s://%s", listener.network, listener.addr))
+		}
+	}
+
+	return strings.Join(addrs, ", ")
+}
+
+// ListenAndServe starts enabled listeners and blocks until they are stopped.
+func (s *Stream) ListenAndServe() error {
+	var group errgroup.Group
+
+	if s.config.UDPAddr != "" {
+		conn, err := net.ListenPacket("udp", s.config.UDPAddr)
+		if err != nil {
+			return s.closeOnError(err)
+		}
+
+		if s.track(conn) {
+			group.Go(func() error { return s.serveUDP(conn) })
+		}
+	}
+
+	if s.config.TCPAddr != "" {
+		listener, err := net.Listen("tcp", s.config.TCPAddr)
+		if err != nil {
+			return s.closeOnError(err)
+		}
+
+		if s.track(listener) {
+			group.Go(func() error { return s.serveTCP(listener) })
+		}
+	}
+
+	if s.config.TLSAddr != "" {
+		listener, err := s.listenTLS()
+		if err != nil {
+			return s.closeOnError(err)
+		}
+
+		if s.track(listener) {
+			group.Go(func() error { return s.serveTCP(listener) })
+		}
+	}
+
+	return group.Wait()
+}
+
+// Shutdown closes listeners and connections and waits for packet and connection handlers to return.
+func (s *Stream) Shutdown() error {
+	s.mu.Lock()
+
+	s.closed = true
+
+	var err error
+
+	for _, listener := range s.listeners {
+		if closeErr := listener.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}
+
+	for conn := range s.conns {
+		_ = conn.Close()
+	}
+
+	s.mu.Unlock()
+
+	s.wg.Wait()
+
+	return err
+}
+
+// IsClosed reports whether shutdown was called, handlers use it to skip errors of closed connections.
+func (s *Stream) IsClosed() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.closed
+}
+
+func (s *Stream) listenTLS() (net.Listener, error) {
+	cert, err := tls.LoadX509KeyPair(s.config.TLSCertFile, s.config.TLSKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("load tls certificate: %w", err)
+	}
+
+	return tls.Listen("tcp", s.config.TLSAddr, &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	})
+}
+
+func (s *Stream) serveUDP(conn net.PacketConn) error {
+	defer s.wg.Done()
+
+	buf := make([]byte, _maxPacketSize)
+
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			if s.IsClosed() {
+				return nil
+			}
+
+			return err
+		}
+
+		s.handler.ServePacket(addr, buf[:n])
+	}
+}
+
+func (s *Stream) serveTCP(listener net.Listener) error {
+	defer s.wg.Done()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if s.IsClosed() {
+				return nil
+			}
+
+			return err
+		}
+
+		if !s.track(conn) {
+			return nil
+		}
+
+		go s.serveConn(conn)
+	}
+}
+
+func (s *Stream) serveConn(conn net.Conn) {
+	defer s.wg.Done()
+	defer s.untrack(conn)
+
+	s.handler.ServeConn(conn)
+}
+
+// track registers listener or connection to be closed on shutdown and adds its serving loop
+// to the wait group, so shutdown waits for packets and connections being handled.
+// Returns false if stream is closed.
+func (s *Stream) track(closer io.Closer) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		_ = closer.Close()
+
+		return false
+	}
+
+	if conn, ok := closer.(net.Conn); ok {
+		s.conns[conn] = struct{}{}
+	} else {
+		s.listeners = append(s.listeners, closer)
+	}
+
+	s.wg.Add(1)
+
+	return true
+}
+
+func (s *Stream) untrack(conn net.Conn) {
+	s.mu.Lock()
+	delete(s.conns, conn)
+	s.mu.Unlock()
+
+	_ = conn.Close()
+}
+
+func (s *Stream) closeOnError(err error) error {
+	_ = s.Shutdown()
+
+	return err
+}
+
+// RemoteIP returns host of the remote address.
+func RemoteIP(addr net.Addr) string {
+	if addr == nil {
+		return ""
+	}
+
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return addr.String()
+	}
+
+	return host
+}
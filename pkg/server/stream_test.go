@@ -0,0 +1,77 @@
+package server
+
+import (
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type handlerMock struct {
+	received chan struct{}
+	release  chan struct{}
+	served   int32
+}
+
+func (h *handlerMock) ServePacket(addr net.Addr, data []byte) {
+	h.received <- struct{}{}
+	<-h.release
+	atomic.AddInt32(&h.served, 1)
+}
+
+func (h *handlerMock) ServeConn(conn net.Conn) {}
+
+func TestStream_ShutdownWaitsForPackets(t *testing.T) {
+	var (
+		handler = &handlerMock{received: make(chan struct{}), release: make(chan struct{})}
+		stream  = NewStream(&StreamConfig{UDPAddr: "127.0.0.1:0"}, handler)
+		done    = make(chan error, 1)
+	)
+
+	go func() { done <- stream.ListenAndServe() }()
+
+	var addr net.Addr
+
+	assert.Eventually(t, func() bool {
+		stream.mu.Lock()
+		defer stream.mu.Unlock()
+
+		// UDP connection is tracked as net.Conn.
+		for conn := range stream.conns {
+			addr = conn.LocalAddr()
+		}
+
+		return addr != nil
+	}, time.Second, time.Millisecond)
+
+	conn, err := net.Dial("udp", addr.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte("message")); err != nil {
+		t.Fatal(err)
+	}
+
+	<-handler.received
+
+	shutdown := make(chan error, 1)
+
+	go func() { shutdown <- stream.Shutdown() }()
+
+	select {
+	case <-shutdown:
+		t.Fatal("shutdown returned while packet is handled")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(handler.release)
+
+	assert.NoError(t, <-shutdown)
+	assert.NoError(t, <-done)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&handler.served))
+}
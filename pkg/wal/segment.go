@@ -0,0 +1,139 @@
+package wal
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	_segmentExt     = ".wal"
+	_headerSize     = 8
+	_maxRecordSize  = 256 << 20
+	_segmentNameFmt = "%020d" + _segmentExt
+)
+
+var _crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// segment is a file of records, the file is named by the index of its first record.
+type segment struct {
+	first uint64
+	size  int64
+}
+
+func (s *segment) path(dir string) string {
+	return filepath.Join(dir, fmt.Sprintf(_segmentNameFmt, s.first))
+}
+
+// listSegments returns segments of the directory sorted by the first index.
+func listSegments(dir string) ([]*segment, error) {
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	segments := make([]*segment, 0, len(files))
+
+	for _, file := range files {
+		if file.IsDir() || !strings.HasSuffix(file.Name(), _segmentExt) {
+			continue
+		}
+
+		first, err := strconv.ParseUint(strings.TrimSuffix(file.Name(), _segmentExt), 10, 64)
+		if err != nil {
+			continue
+		}
+
+		info, err := file.Info()
+		if err != nil {
+			return nil, err
+		}
+
+		segments = append(segments, &segment{first: first, size: info.Size()})
+	}
+
+	sort.Slice(segments, func(i, j int) bool { return segments[i].first < segments[j].first })
+
+	return segments, nil
+}
+
+// appendRecord encodes record as length, crc32 checksum and data.
+func appendRecord(buf, data []byte) []byte {
+	var header [_headerSize]byte
+
+	binary.BigEndian.PutUint32(header[:4], uint32(len(data)))
+	binary.BigEndian.PutUint32(header[4:], crc32.Checksum(data, _crcTable))
+
+	return append(append(buf, header[:]...), data...)
+}
+
+// readRecord returns io.EOF at the end of the segment and ErrCorrupted on a torn or damaged record.
+func readRecord(r *bufio.Reader) ([]byte, error) {
+	var header [_headerSize]byte
+
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, io.EOF
+		}
+
+		return nil, fmt.Errorf("%w: %v", ErrCorrupted, err)
+	}
+
+	size := binary.BigEndian.Uint32(header[:4])
+	if size > _maxRecordSize {
+		return nil, fmt.Errorf("%w: record size %d", ErrCorrupted, size)
+	}
+
+	data := make([]byte, size)
+
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrCorrupted, err)
+	}
+
+	if crc32.Checksum(data, _crcTable) != binary.BigEndian.Uint32(header[4:]) {
+		return nil, fmt.Errorf("%w: checksum mismatch", ErrCorrupted)
+	}
+
+	return data, nil
+}
+
+// scanSegment reads records of the segment until the end or the first damaged record, fn is called for
+// every record. It returns the number of valid records and their size.
+func scanSegment(path string, fn func(data []byte) error) (count uint64, size int64, err error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+
+	for {
+		data, err := readRecord(reader)
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				err = nil
+			}
+
+			return count, size, err
+		}
+
+		if fn != nil {
+			if err := fn(data); err != nil {
+				return count, size, err
+			}
+		}
+
+		count++
+		size += int64(_headerSize + len(data))
+	}
+}
@@ -0,0 +1,689 @@
+// Package wal implements a segmented write-ahead log of opaque records.
+//
+// Every record gets a sequential index. Records stay on disk until they are acknowledged,
+// the index below which everything was acknowledged is kept in the checkpoint file and
+// records after it are returned by Replay after restart. Records that can't be processed
+// now are parked: they are moved to a separate file, so the checkpoint can move past them,
+// and are returned to the log on the next Open.
+package wal
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/loghole/collector/pkg/fileutil"
+)
+
+// Sync policies.
+const (
+	// SyncAlways flushes every write to disk before it returns.
+	SyncAlways = "always"
+	// SyncInterval flushes written records to disk every sync interval.
+	SyncInterval = "interval"
+	// SyncNever leaves flushing to the operating system.
+	SyncNever = "never"
+)
+
+const (
+	_checkpointFile     = "checkpoint"
+	_parkedFile         = "parked"
+	_defaultSegmentSize = 64 << 20
+	_defaultSyncPeriod  = time.Second
+	_dirPerm            = 0o755
+	_filePerm           = 0o644
+)
+
+var (
+	ErrFull        = errors.New("wal is full")
+	ErrTooLarge    = errors.New("records exceed wal max size")
+	ErrClosed      = errors.New("wal is closed")
+	ErrCorrupted   = errors.New("corrupted record")
+	ErrInvalidSync = errors.New("invalid sync policy")
+
+	errStop = errors.New("stop")
+)
+
+type Config struct {
+	Dir string
+	// SegmentSize is a size of segment file after which the next segment is started.
+	SegmentSize int64
+	// MaxSize limits size of all segments and parked records, zero disables the limit.
+	MaxSize      int64
+	SyncPolicy   string
+	SyncInterval time.Duration
+}
+
+type WAL struct {
+	config *Config
+
+	mu       sync.Mutex
+	closed   bool
+	dirty    bool
+	file     *os.File
+	segments []*segment
+	size     int64
+	next     uint64
+	last     uint64
+
+	committed uint64
+	acked     map[uint64]struct{}
+
+	parked     *os.File
+	parkedSize int64
+
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+// Open opens the log in the directory, a damaged tail of the last segment left by a crash is truncated.
+func Open(config *Config) (*WAL, error) {
+	switch config.SyncPolicy {
+	case SyncAlways, SyncInterval, SyncNever:
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrInvalidSync, config.SyncPolicy)
+	}
+
+	if config.SegmentSize <= 0 {
+		config.SegmentSize = _defaultSegmentSize
+	}
+
+	if config.SyncInterval <= 0 {
+		config.SyncInterval = _defaultSyncPeriod
+	}
+
+	if err := os.MkdirAll(config.Dir, _dirPerm); err != nil {
+		return nil, fmt.Errorf("create dir: %w", err)
+	}
+
+	w := &WAL{
+		config: config,
+		acked:  make(map[uint64]struct{}),
+		done:   make(chan struct{}),
+	}
+
+	if err := w.load(); err != nil {
+		return nil, err
+	}
+
+	if config.SyncPolicy == SyncInterval {
+		w.wg.Add(1)
+
+		go w.syncLoop()
+	}
+
+	return w, nil
+}
+
+func (w *WAL) load() (err error) {
+	if w.committed, err = readCheckpoint(w.config.Dir); err != nil {
+		return fmt.Errorf("read checkpoint: %w", err)
+	}
+
+	if w.segments, err = listSegments(w.config.Dir); err != nil {
+		return fmt.Errorf("list segments: %w", err)
+	}
+
+	var reuse bool
+
+	w.next = w.committed + 1
+
+	if len(w.segments) > 0 {
+		active := w.segments[len(w.segments)-1]
+
+		count, size, err := scanSegment(active.path(w.config.Dir), nil)
+		if err != nil && !errors.Is(err, ErrCorrupted) {
+			return fmt.Errorf("scan segment: %w", err)
+		}
+
+		if size < active.size {
+			if err := os.Truncate(active.path(w.config.Dir), size); err != nil {
+				return fmt.Errorf("truncate segment: %w", err)
+			}
+
+			active.size = size
+		}
+
+		// Writing continues in the last segment unless the checkpoint is ahead of it.
+		if next := active.first + count; next >= w.next {
+			w.next, reuse = next, true
+		}
+	}
+
+	for _, segment := range w.segments {
+		w.size += segment.size
+	}
+
+	if err := w.openActive(reuse); err != nil {
+		return err
+	}
+
+	if err := w.restoreParked(); err != nil {
+		return fmt.Errorf("restore parked records: %w", err)
+	}
+
+	w.last = w.next - 1
+
+	return w.removeCommitted()
+}
+
+// restoreParked appends records parked by the previous run to the log, so they are replayed.
+// Records that don't fit into the max size of the log are left parked until the next Open.
+func (w *WAL) restoreParked() error {
+	path := filepath.Join(w.config.Dir, _parkedFile)
+
+	records := make([][]byte, 0)
+
+	_, _, err := scanSegment(path, func(data []byte) error {
+		records = append(records, data)
+
+		return nil
+	})
+
+	switch {
+	case os.IsNotExist(err):
+		return nil
+	case err != nil && !errors.Is(err, ErrCorrupted):
+		return err
+	}
+
+	restored := len(records)
+
+	if w.config.MaxSize > 0 && w.size+recordsSize(records) > w.config.MaxSize {
+		if err := w.releaseActive(); err != nil {
+			return err
+		}
+
+		size := w.size
+
+		for i, data := range records {
+			if size += recordsSize([][]byte{data}); size > w.config.MaxSize {
+				restored = i
+
+				break
+			}
+		}
+	}
+
+	if restored > 0 {
+		if _, _, err := w.append(records[:restored]); err != nil {
+			return err
+		}
+
+		if err := w.file.Sync(); err != nil {
+			return fmt.Errorf("sync segment: %w", err)
+		}
+	}
+
+	if restored == len(records) {
+		return os.Remove(path)
+	}
+
+	return w.rewriteParked(records[restored:])
+}
+
+// rewriteParked replaces the parked file with the records that were not restored.
+func (w *WAL) rewriteParked(records [][]byte) error {
+	path := filepath.Join(w.config.Dir, _parkedFile)
+
+	buf := make([]byte, 0, recordsSize(records))
+
+	for _, data := range records {
+		buf = appendRecord(buf, data)
+	}
+
+	if err := fileutil.WriteFile(path+".tmp", buf); err != nil {
+		return err
+	}
+
+	if err := os.Rename(path+".tmp", path); err != nil {
+		return err
+	}
+
+	w.parkedSize = int64(len(buf))
+
+	return nil
+}
+
+func (w *WAL) openActive(reuse bool) error {
+	if !reuse {
+		return w.startSegment()
+	}
+
+	file, err := os.OpenFile(w.segments[len(w.segments)-1].path(w.config.Dir), os.O_WRONLY|os.O_APPEND, _filePerm)
+	if err != nil {
+		return fmt.Errorf("open segment: %w", err)
+	}
+
+	w.file = file
+
+	return nil
+}
+
+func (w *WAL) startSegment() error {
+	if w.file != nil {
+		if err := w.file.Sync(); err != nil {
+			return fmt.Errorf("sync segment: %w", err)
+		}
+
+		if err := w.file.Close(); err != nil {
+			return fmt.Errorf("close segment: %w", err)
+		}
+
+		w.file = nil
+	}
+
+	active := &segment{first: w.next}
+
+	file, err := os.OpenFile(active.path(w.config.Dir), os.O_WRONLY|os.O_APPEND|os.O_CREATE|os.O_EXCL, _filePerm)
+	if err != nil {
+		return fmt.Errorf("create segment: %w", err)
+	}
+
+	w.file = file
+	w.segments = append(w.segments, active)
+
+	return nil
+}
+
+// Write appends records and returns the indexes of the first and the last of them.
+// Records larger than the max size of the log fail with ErrTooLarge, ErrFull means
+// they may fit after other records are acknowledged.
+func (w *WAL) Write(records [][]byte) (first, last uint64, err error) {
+	size := recordsSize(records)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return 0, 0, ErrClosed
+	}
+
+	if w.config.MaxSize > 0 && size > w.config.MaxSize {
+		return 0, 0, ErrTooLarge
+	}
+
+	if w.config.MaxSize > 0 && w.size+w.parkedSize+size > w.config.MaxSize {
+		if err := w.releaseActive(); err != nil {
+			return 0, 0, err
+		}
+
+		if w.size+w.parkedSize+size > w.config.MaxSize {
+			return 0, 0, ErrFull
+		}
+	}
+
+	if first, last, err = w.append(records); err != nil {
+		return 0, 0, err
+	}
+
+	switch w.config.SyncPolicy {
+	case SyncAlways:
+		if err := w.file.Sync(); err != nil {
+			return 0, 0, fmt.Errorf("sync segment: %w", err)
+		}
+	case SyncInterval:
+		w.dirty = true
+	}
+
+	return first, last, nil
+}
+
+// append writes records to the active segment, the next segment is started if the active one is full.
+func (w *WAL) append(records [][]byte) (first, last uint64, err error) {
+	size := recordsSize(records)
+
+	if active := w.segments[len(w.segments)-1]; active.size > 0 && active.size+size > w.config.SegmentSize {
+		if err := w.startSegment(); err != nil {
+			return 0, 0, err
+		}
+	}
+
+	buf := make([]byte, 0, size)
+
+	for _, data := range records {
+		buf = appendRecord(buf, data)
+	}
+
+	active := w.segments[len(w.segments)-1]
+
+	if _, err := w.file.Write(buf); err != nil {
+		// Drop the partial write, records must not be left without the index.
+		_ = w.file.Truncate(active.size)
+
+		return 0, 0, fmt.Errorf("write segment: %w", err)
+	}
+
+	active.size += size
+	w.size += size
+
+	first, last = w.next, w.next+uint64(len(records))-1
+	w.next += uint64(len(records))
+
+	return first, last, nil
+}
+
+// Park moves records that can't be processed now out of the log: their data is appended to the parked
+// file and the indexes are acknowledged, so that they don't hold the checkpoint and the segments.
+// Parked records are returned to the log on the next Open. They count toward the max size of the log,
+// ErrFull means the parked file has no room for the records, they are left in the log.
+func (w *WAL) Park(indexes []uint64, records [][]byte) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return ErrClosed
+	}
+
+	if w.parked == nil {
+		path := filepath.Join(w.config.Dir, _parkedFile)
+
+		file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, _filePerm)
+		if err != nil {
+			return fmt.Errorf("open parked file: %w", err)
+		}
+
+		info, err := file.Stat()
+		if err != nil {
+			file.Close()
+
+			return fmt.Errorf("stat parked file: %w", err)
+		}
+
+		w.parked, w.parkedSize = file, info.Size()
+	}
+
+	size := recordsSize(records)
+
+	if w.config.MaxSize > 0 && w.parkedSize+size > w.config.MaxSize {
+		return ErrFull
+	}
+
+	buf := make([]byte, 0, size)
+
+	for _, data := range records {
+		buf = appendRecord(buf, data)
+	}
+
+	if _, err := w.parked.Write(buf); err != nil {
+		// Drop the partial write, a torn record would hide the records parked after it.
+		_ = w.parked.Truncate(w.parkedSize)
+
+		return fmt.Errorf("write parked file: %w", err)
+	}
+
+	w.parkedSize += int64(len(buf))
+
+	if w.config.SyncPolicy != SyncNever {
+		if err := w.parked.Sync(); err != nil {
+			return fmt.Errorf("sync parked file: %w", err)
+		}
+	}
+
+	return w.ack(indexes)
+}
+
+// Ack acknowledges records, segments are removed after all their records were acknowledged.
+func (w *WAL) Ack(indexes ...uint64) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.ack(indexes)
+}
+
+func (w *WAL) ack(indexes []uint64) error {
+	for _, index := range indexes {
+		if index > w.committed {
+			w.acked[index] = struct{}{}
+		}
+	}
+
+	committed := w.committed
+
+	for {
+		if _, ok := w.acked[committed+1]; !ok {
+			break
+		}
+
+		delete(w.acked, committed+1)
+		committed++
+	}
+
+	if committed == w.committed {
+		return nil
+	}
+
+	w.committed = committed
+
+	if err := writeCheckpoint(w.config.Dir, committed, w.config.SyncPolicy != SyncNever); err != nil {
+		return fmt.Errorf("write checkpoint: %w", err)
+	}
+
+	return w.removeCommitted()
+}
+
+// Replay calls fn for every record that was written before Open and is not acknowledged.
+// Records of damaged segments that can't be read are acknowledged and reported in the error.
+func (w *WAL) Replay(fn func(index uint64, data []byte) error) error {
+	w.mu.Lock()
+
+	var (
+		committed = w.committed
+		last      = w.last
+		segments  = append([]*segment(nil), w.segments...)
+		damaged   []uint64
+		result    error
+	)
+
+	w.mu.Unlock()
+
+	for idx, segment := range segments {
+		if segment.first > last {
+			break
+		}
+
+		end := last
+		if idx+1 < len(segments) && segments[idx+1].first-1 < end {
+			end = segments[idx+1].first - 1
+		}
+
+		if end <= committed {
+			continue
+		}
+
+		index := segment.first
+
+		_, _, err := scanSegment(segment.path(w.config.Dir), func(data []byte) error {
+			defer func() { index++ }()
+
+			switch {
+			case index > end:
+				return errStop
+			case index <= committed:
+				return nil
+			default:
+				return fn(index, data)
+			}
+		})
+
+		switch {
+		case err == nil, errors.Is(err, errStop):
+		case errors.Is(err, ErrCorrupted):
+			// A record after the replayed range may be partially written by a concurrent Write.
+			if index > end {
+				continue
+			}
+
+			if result == nil {
+				result = fmt.Errorf("segment %d: %w", segment.first, err)
+			}
+
+			for ; index <= end; index++ {
+				if index > committed {
+					damaged = append(damaged, index)
+				}
+			}
+		case os.IsNotExist(err):
+			// Segment was acknowledged and removed during replay.
+		default:
+			return err
+		}
+	}
+
+	if len(damaged) > 0 {
+		if err := w.Ack(damaged...); err != nil {
+			return err
+		}
+	}
+
+	return result
+}
+
+// Size returns size of all segments and parked records.
+func (w *WAL) Size() int64 {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.size + w.parkedSize
+}
+
+func (w *WAL) Close() error {
+	w.mu.Lock()
+
+	if w.closed {
+		w.mu.Unlock()
+
+		return nil
+	}
+
+	w.closed = true
+
+	w.mu.Unlock()
+
+	close(w.done)
+	w.wg.Wait()
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.parked != nil {
+		if err := w.parked.Close(); err != nil {
+			return fmt.Errorf("close parked file: %w", err)
+		}
+	}
+
+	if err := w.file.Sync(); err != nil {
+		return fmt.Errorf("sync segment: %w", err)
+	}
+
+	return w.file.Close()
+}
+
+func (w *WAL) syncLoop() {
+	defer w.wg.Done()
+
+	ticker := time.NewTicker(w.config.SyncInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.done:
+			return
+		case <-ticker.C:
+			w.mu.Lock()
+
+			if w.dirty {
+				w.dirty = false
+				_ = w.file.Sync()
+			}
+
+			w.mu.Unlock()
+		}
+	}
+}
+
+// releaseActive starts the next segment if every record of the active one was acknowledged,
+// so that the active segment can be removed.
+func (w *WAL) releaseActive() error {
+	if w.committed+1 != w.next || w.segments[len(w.segments)-1].size == 0 {
+		return nil
+	}
+
+	if err := w.startSegment(); err != nil {
+		return err
+	}
+
+	return w.removeCommitted()
+}
+
+// removeCommitted removes segments before the active one if all their records were acknowledged.
+func (w *WAL) removeCommitted() error {
+	for len(w.segments) > 1 && w.segments[1].first-1 <= w.committed {
+		if err := os.Remove(w.segments[0].path(w.config.Dir)); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("remove segment: %w", err)
+		}
+
+		w.size -= w.segments[0].size
+		w.segments = w.segments[1:]
+	}
+
+	return nil
+}
+
+func recordsSize(records [][]byte) int64 {
+	var size int64
+
+	for _, data := range records {
+		size += int64(_headerSize + len(data))
+	}
+
+	return size
+}
+
+func readCheckpoint(dir string) (uint64, error) {
+	data, err := os.ReadFile(filepath.Join(dir, _checkpointFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+
+		return 0, err
+	}
+
+	return strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+}
+
+// writeCheckpoint replaces checkpoint file atomically.
+func writeCheckpoint(dir string, committed uint64, sync bool) error {
+	path := filepath.Join(dir, _checkpointFile)
+
+	file, err := os.Create(path + ".tmp")
+	if err != nil {
+		return err
+	}
+
+	if _, err := file.WriteString(strconv.FormatUint(committed, 10)); err != nil {
+		file.Close()
+
+		return err
+	}
+
+	if sync {
+		if err := file.Sync(); err != nil {
+			file.Close()
+
+			return err
+		}
+	}
+
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(path+".tmp", path)
+}
@@ -0,0 +1,217 @@
+package wal
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWAL(t *testing.T) {
+	config := &Config{Dir: t.TempDir(), SegmentSize: 32, SyncPolicy: SyncAlways}
+
+	w, err := Open(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, records := range [][][]byte{
+		{[]byte("first"), []byte("second")},
+		{[]byte("third")},
+		{[]byte("fourth"), []byte("fifth")},
+	} {
+		if _, _, err := w.Write(records); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	assert.Len(t, w.segments, 3)
+
+	// Out of order acknowledgement moves the checkpoint after the gap is filled.
+	assert.NoError(t, w.Ack(2, 4))
+	assert.Equal(t, uint64(0), w.committed)
+	assert.NoError(t, w.Ack(1))
+	assert.Equal(t, uint64(2), w.committed)
+	assert.Len(t, w.segments, 2)
+	assert.NoError(t, w.Close())
+
+	// A torn record of a crashed write is truncated.
+	file, err := os.OpenFile(filepath.Join(config.Dir, "00000000000000000004.wal"), os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := file.Write([]byte{0, 0, 0, 10, 1, 2}); err != nil {
+		t.Fatal(err)
+	}
+
+	file.Close()
+
+	w, err = Open(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer w.Close()
+
+	replayed := make(map[uint64]string)
+
+	err = w.Replay(func(index uint64, data []byte) error {
+		replayed[index] = string(data)
+
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Acknowledgements after the checkpoint are not persisted, records are replayed again.
+	assert.Equal(t, map[uint64]string{3: "third", 4: "fourth", 5: "fifth"}, replayed)
+
+	first, last, err := w.Write([][]byte{[]byte("sixth")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, uint64(6), first)
+	assert.Equal(t, uint64(6), last)
+}
+
+func TestWAL_MaxSize(t *testing.T) {
+	w, err := Open(&Config{Dir: t.TempDir(), MaxSize: 20, SyncPolicy: SyncNever})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer w.Close()
+
+	_, _, err = w.Write([][]byte{[]byte("0123456789")})
+	assert.NoError(t, err)
+
+	_, _, err = w.Write([][]byte{[]byte("0123456789")})
+	assert.ErrorIs(t, err, ErrFull)
+
+	assert.NoError(t, w.Ack(1))
+
+	_, _, err = w.Write([][]byte{[]byte("0123456789")})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(18), w.Size())
+}
+
+func TestWAL_TooLarge(t *testing.T) {
+	w, err := Open(&Config{Dir: t.TempDir(), MaxSize: 20, SyncPolicy: SyncNever})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer w.Close()
+
+	_, _, err = w.Write([][]byte{[]byte("0123456789"), []byte("0123456789")})
+	assert.ErrorIs(t, err, ErrTooLarge)
+	assert.Equal(t, int64(0), w.Size())
+}
+
+func TestWAL_Park(t *testing.T) {
+	dir := t.TempDir()
+
+	w, err := Open(&Config{Dir: dir, SegmentSize: 20, MaxSize: 60, SyncPolicy: SyncAlways})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The first record is never written, it would hold the checkpoint and every later segment.
+	_, _, err = w.Write([][]byte{[]byte("stalled")})
+	assert.NoError(t, err)
+
+	assert.NoError(t, w.Park([]uint64{1}, [][]byte{[]byte("stalled")}))
+
+	for i := 0; i < 10; i++ {
+		first, _, err := w.Write([][]byte{[]byte("0123456789")})
+		if !assert.NoError(t, err) {
+			break
+		}
+
+		assert.NoError(t, w.Ack(first))
+	}
+
+	// Parked record and the active segment are left.
+	assert.Equal(t, int64(2*_headerSize+len("stalled")+len("0123456789")), w.Size())
+	assert.Empty(t, w.acked)
+	assert.NoError(t, w.Close())
+
+	// Parked records are replayed after restart.
+	w, err = Open(&Config{Dir: dir, SegmentSize: 20, MaxSize: 60, SyncPolicy: SyncAlways})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer w.Close()
+
+	replayed := make(map[uint64]string)
+
+	assert.NoError(t, w.Replay(func(index uint64, data []byte) error {
+		replayed[index] = string(data)
+
+		return nil
+	}))
+
+	assert.Equal(t, map[uint64]string{12: "stalled"}, replayed)
+	assert.NoFileExists(t, filepath.Join(dir, _parkedFile))
+
+	assert.NoError(t, w.Ack(12))
+	assert.Equal(t, int64(_headerSize+len("stalled")), w.Size())
+}
+
+func TestWAL_ParkMaxSize(t *testing.T) {
+	dir := t.TempDir()
+
+	w, err := Open(&Config{Dir: dir, MaxSize: 40, SyncPolicy: SyncNever})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, data := range []string{"first", "second"} {
+		first, _, err := w.Write([][]byte{[]byte(data)})
+		if !assert.NoError(t, err) {
+			break
+		}
+
+		assert.NoError(t, w.Park([]uint64{first}, [][]byte{[]byte(data)}))
+	}
+
+	// Parked records count toward the max size.
+	_, _, err = w.Write([][]byte{[]byte("0123456789")})
+	assert.ErrorIs(t, err, ErrFull)
+	assert.Equal(t, int64(2*_headerSize+len("first")+len("second")), w.Size())
+	assert.NoError(t, w.Close())
+
+	// Parked records that don't fit into the smaller log are left parked.
+	w, err = Open(&Config{Dir: dir, MaxSize: 20, SyncPolicy: SyncNever})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer w.Close()
+
+	replayed := make(map[uint64]string)
+
+	assert.NoError(t, w.Replay(func(index uint64, data []byte) error {
+		replayed[index] = string(data)
+
+		return nil
+	}))
+
+	assert.Equal(t, map[uint64]string{3: "first"}, replayed)
+	assert.Equal(t, int64(2*_headerSize+len("first")+len("second")), w.Size())
+	assert.FileExists(t, filepath.Join(dir, _parkedFile))
+
+	// The parked file has no room for the record, it is left in the log.
+	assert.ErrorIs(t, w.Park([]uint64{3}, [][]byte{[]byte("first")}), ErrFull)
+	assert.Equal(t, uint64(2), w.committed)
+}
+
+func TestOpen_InvalidSync(t *testing.T) {
+	_, err := Open(&Config{Dir: t.TempDir(), SyncPolicy: "sometimes"})
+	assert.ErrorIs(t, err, ErrInvalidSync)
+}